@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"strings"
+
 	"github.com/policyscript/policyscript/token"
 	"github.com/policyscript/policyscript/util"
 )
@@ -41,20 +43,25 @@ func (p *Program) Range() *util.Range {
 
 /* --- Statements --- */
 
-// The HeadingStatement node.
+// The ExpressionStatement node.
 type ExpressionStatement struct {
 	Token token.Token
 	Expr  Expr
+	Doc   string // text of the comment directly above, if any
 }
 
-func (s *ExpressionStatement) statementNode()     {}
-func (s *ExpressionStatement) Range() *util.Range { return &s.Token.Range }
+func (s *ExpressionStatement) statementNode() {}
+func (s *ExpressionStatement) Range() *util.Range {
+	return &util.Range{Start: s.Token.Range.Start, End: s.Expr.Range().End}
+}
 
 // The HeadingStatement node.
 type HeadingStatement struct {
 	Token token.Token
 	Depth int
 	Value string
+	Label string // empty unless numbered, ex: "a"
+	Path  string // empty unless numbered, ex: "121(a)(1)"
 }
 
 func (s *HeadingStatement) statementNode()     {}
@@ -78,17 +85,28 @@ type CommentStatement struct {
 func (s *CommentStatement) statementNode()     {}
 func (s *CommentStatement) Range() *util.Range { return &s.Token.Range }
 
+// Text returns the lines of the comment without the spaces around them.
+func (s *CommentStatement) Text() string {
+	lines := strings.Split(s.Value, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
 // The BlockStatement node.
 type BlockStatement struct {
-	Token token.Token
-	Ident *Identifier // nil unless @define
-	end   token.Token
-	Stmts []Stmt
+	Token    token.Token
+	Ident    *Identifier // nil unless @define or @enum
+	End      token.Token
+	Stmts    []Stmt
+	Citation string // path of the enclosing heading, ex: "121(b)(1)"
+	Doc      string // text of the comment directly above, if any
 }
 
 func (s *BlockStatement) statementNode() {}
 func (s *BlockStatement) Range() *util.Range {
-	return &util.Range{Start: s.Token.Range.Start, End: s.end.Range.End}
+	return &util.Range{Start: s.Token.Range.Start, End: s.End.Range.End}
 }
 
 // The ScopeStatement node.
@@ -112,6 +130,7 @@ type IfStatement struct {
 	Token     token.Token
 	Condition Expr
 	Block     *ScopeStatement
+	Doc       string // text of the comment directly above, if any
 }
 
 func (s *IfStatement) statementNode() {}
@@ -124,6 +143,7 @@ type ElseStatement struct {
 	Token     token.Token
 	Condition Expr
 	Block     *ScopeStatement
+	Doc       string // text of the comment directly above, if any
 }
 
 func (s *ElseStatement) statementNode() {}
@@ -137,6 +157,7 @@ type ForStatement struct {
 	Ident *Identifier
 	Iter  Expr
 	Block *ScopeStatement
+	Doc   string // text of the comment directly above, if any
 }
 
 func (s *ForStatement) statementNode() {}
@@ -155,6 +176,18 @@ type Identifier struct {
 func (e *Identifier) expressionNode()    {}
 func (e *Identifier) Range() *util.Range { return &e.Token.Range }
 
+// The MemberExpression node.
+type MemberExpression struct {
+	Token token.Token
+	Left  Expr
+	Ident *Identifier
+}
+
+func (e *MemberExpression) expressionNode() {}
+func (e *MemberExpression) Range() *util.Range {
+	return &util.Range{Start: e.Left.Range().Start, End: e.Ident.Range().End}
+}
+
 // The PrefixExpression node.
 type PrefixExpression struct {
 	Token    token.Token
@@ -169,7 +202,7 @@ func (e *PrefixExpression) Range() *util.Range {
 
 // The InfixExpression node.
 type InfixExpression struct {
-	token    token.Token
+	Token    token.Token
 	Left     Expr
 	Operator string
 	Right    Expr
@@ -182,21 +215,27 @@ func (e *InfixExpression) Range() *util.Range {
 
 // The DeclareExpression node.
 type DeclareExpression struct {
-	Token token.Token
-	Ident *Identifier
-	Value Expr
+	Token   token.Token
+	Ident   *Identifier
+	Value   Expr
+	Default Expr // value after "or" of an optional input, ex: 15%, or nil
 }
 
 func (e *DeclareExpression) expressionNode() {}
 func (e *DeclareExpression) Range() *util.Range {
-	return &util.Range{Start: e.Ident.Range().Start, End: e.Value.Range().End}
+	end := e.Value.Range().End
+	if e.Default != nil {
+		end = e.Default.Range().End
+	}
+	return &util.Range{Start: e.Ident.Range().Start, End: end}
 }
 
 // The SetExpression node.
 type SetExpression struct {
-	Token token.Token
-	Ident *Identifier
-	Value Expr
+	Token    token.Token
+	Ident    *Identifier
+	Value    Expr
+	Citation string // path of the enclosing heading, ex: "121(b)(1)"
 }
 
 func (e *SetExpression) expressionNode() {}
@@ -204,6 +243,17 @@ func (e *SetExpression) Range() *util.Range {
 	return &util.Range{Start: e.Token.Range.Start, End: e.Value.Range().End}
 }
 
+// The ListType node.
+type ListType struct {
+	Token token.Token
+	Elem  Expr
+}
+
+func (e *ListType) expressionNode() {}
+func (e *ListType) Range() *util.Range {
+	return &util.Range{Start: e.Elem.Range().Start, End: e.Token.Range.End}
+}
+
 // The Condition node.
 type Condition struct {
 	Token token.Token
@@ -213,6 +263,14 @@ type Condition struct {
 func (e *Condition) expressionNode()    {}
 func (e *Condition) Range() *util.Range { return &e.Token.Range }
 
+// The Unknown node.
+type Unknown struct {
+	Token token.Token
+}
+
+func (e *Unknown) expressionNode()    {}
+func (e *Unknown) Range() *util.Range { return &e.Token.Range }
+
 // The TextLiteral node.
 type TextLiteral struct {
 	Token token.Token
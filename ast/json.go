@@ -0,0 +1,187 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// JSONVersion is the version of the JSON encoding of nodes. It changes
+// whenever a kind or field is added, renamed or removed, so that readers can
+// reject trees they do not understand. Version 2 added the default of
+// declarations and the Unknown kind.
+const JSONVersion = 2
+
+// Marshal encodes a node as JSON, ex:
+//
+//	{"version": 2, "node": {"kind": "Identifier", "range": {...}, "token": {...}, "value": "a"}}
+//
+// Each node is an object with its kind, which is the name of its type, and
+// its range, followed by its fields with the first letter of their names in
+// lower case. Children are nodes, or null if unset, and statements are arrays
+// of nodes.
+func Marshal(node Node) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"version": JSONVersion, "node": encode(node)})
+}
+
+// Unmarshal decodes a node encoded by Marshal. The ranges of nodes are
+// computed from their tokens, so the range of each object is not read. Every
+// child which the parser always sets must be present, ex: the value of a
+// SetExpression.
+func Unmarshal(data []byte) (Node, error) {
+	var doc struct {
+		Version *int            `json:"version"`
+		Node    json.RawMessage `json:"node"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("ast: %s", err)
+	}
+	if doc.Version == nil || *doc.Version != JSONVersion {
+		return nil, fmt.Errorf("ast: expected version %d", JSONVersion)
+	}
+	return decode(doc.Node, "node")
+}
+
+// UnmarshalProgram decodes a program encoded by Marshal.
+func UnmarshalProgram(data []byte) (*Program, error) {
+	node, err := Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	program, ok := node.(*Program)
+	if !ok {
+		return nil, fmt.Errorf("ast: expected Program, got %s", kindOf(node))
+	}
+	return program, nil
+}
+
+// kinds are the types of nodes, by kind.
+var kinds = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []Node{
+		&Program{}, &ExpressionStatement{}, &HeadingStatement{}, &ParagraphStatement{},
+		&CommentStatement{}, &BlockStatement{}, &ScopeStatement{}, &IfStatement{},
+		&ElseStatement{}, &ForStatement{}, &Identifier{}, &MemberExpression{},
+		&PrefixExpression{}, &InfixExpression{}, &DeclareExpression{}, &SetExpression{},
+		&ListType{}, &Condition{}, &Unknown{}, &TextLiteral{}, &IntegerLiteral{},
+		&DecimalLiteral{}, &MoneyLiteral{}, &PercentLiteral{}, &PeriodLiteral{}, &DateLiteral{},
+		&TimeLiteral{},
+	} {
+		kinds[kindOf(node)] = reflect.TypeOf(node).Elem()
+	}
+}
+
+// optional are the children which may be nil, by kind and field. Every other
+// child is required.
+var optional = map[string]bool{
+	"BlockStatement.Ident":      true,
+	"ElseStatement.Condition":   true,
+	"DeclareExpression.Default": true,
+}
+
+var (
+	nodeType  = reflect.TypeOf((*Node)(nil)).Elem()
+	stmtsType = reflect.TypeOf([]Stmt(nil))
+)
+
+func kindOf(node Node) string {
+	return reflect.TypeOf(node).Elem().Name()
+}
+
+// key returns the name of a field in JSON, ex: "stmts" for Stmts.
+func key(field string) string {
+	return strings.ToLower(field[:1]) + field[1:]
+}
+
+func encode(node Node) interface{} {
+	if isNil(node) {
+		return nil
+	}
+
+	object := map[string]interface{}{"kind": kindOf(node), "range": node.Range()}
+	v := reflect.ValueOf(node).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		switch {
+		case field.Type.Implements(nodeType):
+			child, _ := value.Interface().(Node)
+			object[key(field.Name)] = encode(child)
+		case field.Type == stmtsType:
+			stmts := make([]interface{}, value.Len())
+			for j := range stmts {
+				stmts[j] = encode(value.Index(j).Interface().(Node))
+			}
+			object[key(field.Name)] = stmts
+		default:
+			object[key(field.Name)] = value.Interface()
+		}
+	}
+	return object
+}
+
+// decode decodes a node, where path is where it is in the document, ex:
+// "node.stmts[0].expr", which is given in errors.
+func decode(data json.RawMessage, path string) (Node, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("ast: %s: %s", path, err)
+	}
+	var kind string
+	if err := json.Unmarshal(object["kind"], &kind); err != nil {
+		return nil, fmt.Errorf("ast: %s: expected a kind", path)
+	}
+	t, ok := kinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("ast: %s: unknown kind %q", path, kind)
+	}
+
+	v := reflect.New(t)
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Elem().Field(i)
+		name := key(field.Name)
+		raw, ok := object[name]
+		if !ok || string(raw) == "null" {
+			if field.Type.Implements(nodeType) && !optional[kind+"."+field.Name] {
+				return nil, fmt.Errorf("ast: %s.%s: missing the %s of %s", path, name, name, kind)
+			}
+			continue
+		}
+
+		switch {
+		case field.Type.Implements(nodeType):
+			child, err := decode(raw, path+"."+name)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.TypeOf(child).AssignableTo(field.Type) {
+				return nil, fmt.Errorf("ast: %s.%s: %s can not be the %s of %s",
+					path, name, kindOf(child), name, kind)
+			}
+			value.Set(reflect.ValueOf(child))
+		case field.Type == stmtsType:
+			var list []json.RawMessage
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, fmt.Errorf("ast: %s.%s: %s", path, name, err)
+			}
+			stmts := make([]Stmt, len(list))
+			for j, raw := range list {
+				elemPath := fmt.Sprintf("%s.%s[%d]", path, name, j)
+				child, err := decode(raw, elemPath)
+				if err != nil {
+					return nil, err
+				}
+				if stmts[j], ok = child.(Stmt); !ok {
+					return nil, fmt.Errorf("ast: %s: %s is not a statement", elemPath, kindOf(child))
+				}
+			}
+			value.Set(reflect.ValueOf(stmts))
+		default:
+			if err := json.Unmarshal(raw, value.Addr().Interface()); err != nil {
+				return nil, fmt.Errorf("ast: %s.%s: %s", path, name, err)
+			}
+		}
+	}
+	return v.Interface().(Node), nil
+}
@@ -0,0 +1,66 @@
+package ast_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/util"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var _ = Describe("JSON", func() {
+	It("encodes every kind of node", func() {
+		source, err := ioutil.ReadFile("testdata/nodes.law")
+		Expect(err).NotTo(HaveOccurred())
+		data, err := ast.Marshal(parse(string(source)))
+		Expect(err).NotTo(HaveOccurred())
+
+		var b bytes.Buffer
+		Expect(json.Indent(&b, data, "", "  ")).To(Succeed())
+		b.WriteString("\n")
+		if *update {
+			Expect(ioutil.WriteFile("testdata/nodes.json", b.Bytes(), 0644)).To(Succeed())
+		}
+		golden, err := ioutil.ReadFile("testdata/nodes.json")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.String()).To(Equal(string(golden)))
+	})
+
+	It("decodes what it encodes", func() {
+		golden, err := ioutil.ReadFile("testdata/nodes.json")
+		Expect(err).NotTo(HaveOccurred())
+		program, err := ast.UnmarshalProgram(golden)
+		Expect(err).NotTo(HaveOccurred())
+
+		source, err := ioutil.ReadFile("testdata/nodes.law")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(program).To(Equal(parse(string(source))))
+	})
+
+	util.Each("reports invalid documents", [][2]string{
+		{`{"node": {"kind": "Program"}}`, "ast: expected version 2"},
+		{`{"version": 1, "node": {"kind": "Program"}}`, "ast: expected version 2"},
+		{`{"version": 2, "node": {"kind": "Identifier"}}`, "ast: expected Program, got Identifier"},
+		{`{"version": 2, "node": {"kind": "Loop"}}`, `ast: node: unknown kind "Loop"`},
+		{`{"version": 2, "node": {}}`, "ast: node: expected a kind"},
+		{`{"version": 2, "node": {"kind": "Program", "stmts": [{"kind": "Identifier"}]}}`,
+			"ast: node.stmts[0]: Identifier is not a statement"},
+		{`{"version": 2, "node": {"kind": "Program", "stmts": [{"kind": "ForStatement", "ident": {"kind": "Condition"}}]}}`,
+			"ast: node.stmts[0].ident: Condition can not be the ident of ForStatement"},
+		{`{"version": 2, "node": {"kind": "Program", "stmts": [{"kind": "HeadingStatement", "depth": "1"}]}}`,
+			"ast: node.stmts[0].depth: json: cannot unmarshal string into Go value of type int"},
+		{`{"version": 2, "node": {"kind": "Program", "stmts": [{"kind": "ExpressionStatement", "expr": {"kind": "SetExpression"}}]}}`,
+			"ast: node.stmts[0].expr.ident: missing the ident of SetExpression"},
+		{`{"version": 2, "node": {"kind": "Program", "stmts": [{"kind": "IfStatement", "condition": null}]}}`,
+			"ast: node.stmts[0].condition: missing the condition of IfStatement"},
+	}, func(input, expects string) {
+		_, err := ast.UnmarshalProgram([]byte(input))
+		Expect(err).To(MatchError(expects))
+	})
+})
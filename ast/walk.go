@@ -0,0 +1,275 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/policyscript/policyscript/token"
+)
+
+// A Visitor's Visit method is called by Walk for each node. If the visitor w
+// it returns is not nil, Walk visits each child of the node with w, followed
+// by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an ast in depth-first order, visiting the children of a node
+// in source order. It starts by calling v.Visit(node); node must not be nil.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	for _, child := range Children(node) {
+		Walk(v, child)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an ast in depth-first order like Walk, calling f(node)
+// for each node. The children of a node are only inspected if f returns true,
+// after which f is called with nil.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Children returns the nodes directly inside a node, in source order. Nodes
+// which were not parsed, such as the condition of an else without an if, are
+// left out.
+func Children(node Node) []Node {
+	var nodes []Node
+	add := func(children ...Node) {
+		for _, child := range children {
+			if !isNil(child) {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+	addStmts := func(stmts []Stmt) {
+		for _, stmt := range stmts {
+			add(stmt)
+		}
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		addStmts(n.Stmts)
+	case *BlockStatement:
+		add(n.Ident)
+		addStmts(n.Stmts)
+	case *ScopeStatement:
+		addStmts(n.Stmts)
+	case *ExpressionStatement:
+		add(n.Expr)
+	case *IfStatement:
+		add(n.Condition, n.Block)
+	case *ElseStatement:
+		add(n.Condition, n.Block)
+	case *ForStatement:
+		add(n.Ident, n.Iter, n.Block)
+	case *MemberExpression:
+		add(n.Left, n.Ident)
+	case *PrefixExpression:
+		add(n.Right)
+	case *InfixExpression:
+		add(n.Left, n.Right)
+	case *DeclareExpression:
+		add(n.Ident, n.Value, n.Default)
+	case *SetExpression:
+		add(n.Ident, n.Value)
+	case *ListType:
+		add(n.Elem)
+	}
+	return nodes
+}
+
+// Rewrite replaces the nodes of an ast, from the leaves up: the children of a
+// node are rewritten before f is called with the node, and the node is
+// replaced by what f returns. Rewrite returns the replacement of node.
+//
+// A statement of a program, block or scope which f replaces with nil is
+// removed. Other nodes must be replaced by a node which fits where they are,
+// ex: an expression by an expression, or Rewrite panics.
+//
+// Ranges stay consistent: a replacement whose token has no range, such as one
+// built by a tool, takes the range of the token of the node it replaces.
+func Rewrite(node Node, f func(Node) Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		n.Stmts = rewriteStmts(n.Stmts, f)
+	case *BlockStatement:
+		n.Ident = rewriteIdent(n.Ident, f)
+		n.Stmts = rewriteStmts(n.Stmts, f)
+	case *ScopeStatement:
+		n.Stmts = rewriteStmts(n.Stmts, f)
+	case *ExpressionStatement:
+		n.Expr = rewriteExpr(n.Expr, f)
+	case *IfStatement:
+		n.Condition = rewriteExpr(n.Condition, f)
+		n.Block = rewriteScope(n.Block, f)
+	case *ElseStatement:
+		n.Condition = rewriteExpr(n.Condition, f)
+		n.Block = rewriteScope(n.Block, f)
+	case *ForStatement:
+		n.Ident = rewriteIdent(n.Ident, f)
+		n.Iter = rewriteExpr(n.Iter, f)
+		n.Block = rewriteScope(n.Block, f)
+	case *MemberExpression:
+		n.Left = rewriteExpr(n.Left, f)
+		n.Ident = rewriteIdent(n.Ident, f)
+	case *PrefixExpression:
+		n.Right = rewriteExpr(n.Right, f)
+	case *InfixExpression:
+		n.Left = rewriteExpr(n.Left, f)
+		n.Right = rewriteExpr(n.Right, f)
+	case *DeclareExpression:
+		n.Ident = rewriteIdent(n.Ident, f)
+		n.Value = rewriteExpr(n.Value, f)
+		n.Default = rewriteExpr(n.Default, f)
+	case *SetExpression:
+		n.Ident = rewriteIdent(n.Ident, f)
+		n.Value = rewriteExpr(n.Value, f)
+	case *ListType:
+		n.Elem = rewriteExpr(n.Elem, f)
+	}
+
+	replacement := f(node)
+	if !isNil(replacement) {
+		from, to := tokenOf(node), tokenOf(replacement)
+		if from != nil && to != nil && to.Range.Start.Line == 0 {
+			to.Range = from.Range
+		}
+	}
+	return replacement
+}
+
+func rewriteStmts(stmts []Stmt, f func(Node) Node) []Stmt {
+	rewritten := stmts[:0]
+	for _, stmt := range stmts {
+		if isNil(stmt) {
+			continue
+		}
+		replacement := Rewrite(stmt, f)
+		if isNil(replacement) {
+			continue
+		}
+		replaced, ok := replacement.(Stmt)
+		if !ok {
+			panic(misfit(stmt, replacement))
+		}
+		rewritten = append(rewritten, replaced)
+	}
+	return rewritten
+}
+
+func rewriteExpr(exp Expr, f func(Node) Node) Expr {
+	if isNil(exp) {
+		return exp
+	}
+	replacement := Rewrite(exp, f)
+	replaced, ok := replacement.(Expr)
+	if !ok || isNil(replaced) {
+		panic(misfit(exp, replacement))
+	}
+	return replaced
+}
+
+func rewriteIdent(ident *Identifier, f func(Node) Node) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	replacement := Rewrite(ident, f)
+	replaced, ok := replacement.(*Identifier)
+	if !ok || replaced == nil {
+		panic(misfit(ident, replacement))
+	}
+	return replaced
+}
+
+func rewriteScope(scope *ScopeStatement, f func(Node) Node) *ScopeStatement {
+	if scope == nil {
+		return nil
+	}
+	replacement := Rewrite(scope, f)
+	replaced, ok := replacement.(*ScopeStatement)
+	if !ok || replaced == nil {
+		panic(misfit(scope, replacement))
+	}
+	return replaced
+}
+
+func misfit(old, new Node) string {
+	return fmt.Sprintf("ast.Rewrite: can not replace %T with %T", old, new)
+}
+
+// tokenOf returns the token which starts a node, or nil if the node has none.
+func tokenOf(node Node) *token.Token {
+	switch n := node.(type) {
+	case *ExpressionStatement:
+		return &n.Token
+	case *HeadingStatement:
+		return &n.Token
+	case *ParagraphStatement:
+		return &n.Token
+	case *CommentStatement:
+		return &n.Token
+	case *BlockStatement:
+		return &n.Token
+	case *IfStatement:
+		return &n.Token
+	case *ElseStatement:
+		return &n.Token
+	case *ForStatement:
+		return &n.Token
+	case *Identifier:
+		return &n.Token
+	case *MemberExpression:
+		return &n.Token
+	case *PrefixExpression:
+		return &n.Token
+	case *InfixExpression:
+		return &n.Token
+	case *DeclareExpression:
+		return &n.Token
+	case *SetExpression:
+		return &n.Token
+	case *ListType:
+		return &n.Token
+	case *Condition:
+		return &n.Token
+	case *Unknown:
+		return &n.Token
+	case *TextLiteral:
+		return &n.Token
+	case *IntegerLiteral:
+		return &n.Token
+	case *DecimalLiteral:
+		return &n.Token
+	case *MoneyLiteral:
+		return &n.Token
+	case *PercentLiteral:
+		return &n.Token
+	case *PeriodLiteral:
+		return &n.Token
+	case *DateLiteral:
+		return &n.Token
+	case *TimeLiteral:
+		return &n.Token
+	}
+	return nil
+}
+
+// isNil reports whether a node is nil, including a nil pointer in an
+// interface.
+func isNil(node Node) bool {
+	return node == nil || reflect.ValueOf(node).IsNil()
+}
@@ -0,0 +1,130 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/parser"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/util"
+)
+
+const source = "_ Rules\n\n@define Person {\n  names: text list\n}\n\n@code {\n" +
+	"  # Total.\n  for n in person.names:\n    if -n > 1 and true:\n      set total to total + 2\n" +
+	"    else:\n      set total to `none`\n}\n"
+
+// kinds records the kind of each node visited, and "end" after the children
+// of a node.
+type kinds []string
+
+func (k *kinds) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		*k = append(*k, "end")
+		return nil
+	}
+	*k = append(*k, strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."))
+	return k
+}
+
+var _ = Describe("Walk", func() {
+	It("visits every node in source order", func() {
+		var k kinds
+		ast.Walk(&k, parse(source))
+		Expect(strings.Join(k, " ")).To(Equal("Program " +
+			"HeadingStatement end " +
+			"BlockStatement Identifier end ExpressionStatement DeclareExpression Identifier end " +
+			"ListType Identifier end end end end end " +
+			"BlockStatement CommentStatement end ForStatement Identifier end " +
+			"MemberExpression Identifier end Identifier end end ScopeStatement " +
+			"IfStatement InfixExpression InfixExpression PrefixExpression Identifier end end " +
+			"IntegerLiteral end end Condition end end ScopeStatement ExpressionStatement " +
+			"SetExpression Identifier end InfixExpression Identifier end IntegerLiteral end end " +
+			"end end end end " +
+			"ElseStatement ScopeStatement ExpressionStatement SetExpression Identifier end " +
+			"TextLiteral end end end end end end end end end"))
+	})
+
+	It("inspects the children of a node only if asked", func() {
+		var idents []string
+		ast.Inspect(parse(source), func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.Identifier:
+				idents = append(idents, node.Value)
+			case *ast.IfStatement:
+				return false
+			}
+			return true
+		})
+		Expect(idents).To(Equal([]string{"Person", "names", "text", "n", "person", "names", "total"}))
+	})
+
+	It("returns the children of a node", func() {
+		program := parse(source)
+		children := ast.Children(program)
+		Expect(children).To(HaveLen(3))
+		Expect(ast.Children(children[0])).To(BeEmpty())
+		Expect(ast.Children(&ast.ElseStatement{Block: &ast.ScopeStatement{}})).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("Rewrite", func() {
+	It("replaces nodes from the leaves up", func() {
+		program := parse(source)
+		loop := program.Stmts[2].(*ast.BlockStatement).Stmts[1].(*ast.ForStatement)
+		branch := loop.Block.Stmts[0].(*ast.IfStatement)
+		rng := branch.Condition.(*ast.InfixExpression).Left.(*ast.InfixExpression).Right.Range().String()
+
+		ast.Rewrite(program, func(node ast.Node) ast.Node {
+			switch node := node.(type) {
+			case *ast.IntegerLiteral:
+				return &ast.IntegerLiteral{Token: token.Token{Type: token.INTEGER, Literal: "3"}, Value: 3}
+			case *ast.InfixExpression:
+				// Both operands are rewritten before the expression.
+				if right, ok := node.Right.(*ast.IntegerLiteral); ok && right.Value == 3 && node.Operator == "+" {
+					return node.Left
+				}
+			case *ast.CommentStatement, *ast.HeadingStatement:
+				return nil
+			}
+			return node
+		})
+
+		Expect(program.Stmts).To(HaveLen(2))
+		code := program.Stmts[1].(*ast.BlockStatement)
+		Expect(code.Stmts).To(Equal([]ast.Stmt{loop}))
+
+		set := branch.Block.Stmts[0].(*ast.ExpressionStatement).Expr.(*ast.SetExpression)
+		Expect(set.Value.(*ast.Identifier).Value).To(Equal("total"))
+
+		one := branch.Condition.(*ast.InfixExpression).Left.(*ast.InfixExpression).Right
+		Expect(one.(*ast.IntegerLiteral).Value).To(Equal(3))
+		Expect(one.Range().String()).To(Equal(rng))
+	})
+
+	It("panics if a node does not fit where it is", func() {
+		program := parse(source)
+		Expect(func() {
+			ast.Rewrite(program, func(node ast.Node) ast.Node {
+				if _, ok := node.(*ast.Identifier); ok {
+					return &ast.ScopeStatement{}
+				}
+				return node
+			})
+		}).To(PanicWith("ast.Rewrite: can not replace *ast.Identifier with *ast.ScopeStatement"))
+	})
+})
+
+func parse(input string) *ast.Program {
+	var errs util.ErrorList
+	s := scanner.New(util.NewFile("", []byte(input)), func(err *util.Error) {
+		errs = append(errs, err)
+	})
+	p := parser.New(*s)
+	program := p.ParseProgram()
+	Expect(append(errs, p.Errors()...)).To(BeEmpty())
+	return program
+}
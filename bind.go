@@ -0,0 +1,663 @@
+package policyscript
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/policyscript/policyscript/evaluator"
+	"github.com/policyscript/policyscript/object"
+	"github.com/policyscript/policyscript/types"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Period is a length of time with calendar units, ex: "1 year 6 months",
+// which a time.Duration can not hold since the length of a month depends on
+// the date it is added to.
+type Period struct {
+	Years   int
+	Months  int
+	Days    int
+	Seconds int
+}
+
+// Money is an amount of money in cents, ex: 1050 is $10.50, which holds every
+// amount exactly, unlike a float. It is in the currency of the policy it is
+// bound to.
+type Money int64
+
+// String returns the amount as a PolicyScript literal in dollars, ex:
+// "$1_050.25".
+func (m Money) String() string {
+	return m.In("$")
+}
+
+// In returns the amount as a PolicyScript literal in the currency with a
+// symbol, ex: "€1_050.25" in "€".
+func (m Money) In(symbol string) string {
+	return (&object.Money{Cents: int64(m), Symbol: symbol}).Inspect()
+}
+
+// Binding evaluates a policy with its inputs read from a Go struct, and its
+// outputs written to another. Each input or output is the field tagged with
+// its name, ex:
+//
+//	type Inputs struct {
+//		Person Person `law:"person"`
+//	}
+//
+// and the same goes for the fields of @define types, which are bound to
+// structs. Fields without a tag are ignored.
+//
+// Text and enums are bound to strings, integers to ints, decimals to floats,
+// conditions to bools, and lists to slices. Money is bound to Money, which
+// counts cents, or to a decimal type whose text is a number, such as the
+// Decimal of github.com/shopspring/decimal, or to a float, which is rounded to
+// the nearest cent. All of these are in the currency of the policy.
+// Percentages are bound to floats, where 15 is 15%. Dates and times are bound
+// to time.Time, and periods to time.Duration, which must be a whole number of
+// seconds, or Period. Optional inputs may also be bound to pointers,
+// where nil gives the input its default, and outputs to pointers, which are
+// nil if the output is unknown.
+type Binding struct {
+	policy  *Policy
+	inputs  reflect.Type
+	outputs reflect.Type
+	in      []*structField
+	out     []*structField
+}
+
+// BindError lists the differences between the structs of a binding and the
+// fields of a policy, ex: an input without a field.
+type BindError struct {
+	Mismatches []string
+}
+
+func (e *BindError) Error() string {
+	return "policyscript: " + strings.Join(e.Mismatches, "; ")
+}
+
+// Bind binds the inputs and outputs of the policy to structs of the types of
+// inputs and outputs, which may also be pointers to them. Every input and
+// output, and every field of their types, must have a struct field of a type
+// which it can be bound to, or the error is a *BindError with all of those
+// which do not.
+func (p *Policy) Bind(inputs, outputs interface{}) (*Binding, error) {
+	b := &binder{currency: p.info.Currency}
+	binding := &Binding{
+		policy:  p,
+		inputs:  structType(inputs),
+		outputs: structType(outputs),
+	}
+	binding.in = b.fields(p.info.Inputs, binding.inputs, "inputs")
+	binding.out = b.fields(p.info.Outputs, binding.outputs, "outputs")
+	if len(b.mismatches) > 0 {
+		return nil, &BindError{Mismatches: b.mismatches}
+	}
+	return binding, nil
+}
+
+// structType returns the type of a struct, or of the struct a pointer points
+// to.
+func structType(value interface{}) reflect.Type {
+	t := reflect.TypeOf(value)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Evaluate runs the policy with the inputs in a struct, or a pointer to one,
+// and sets the fields of the outputs, which must be a pointer to a struct.
+// Fields of outputs which the policy did not set are left as they are. Values
+// of inputs which do not fit their declared types, such as a string which is
+// not a member of an enum, are reported as with Policy.Evaluate. If an output
+// is unknown and its field is not a pointer, the other outputs are still set,
+// and the error is an *Error whose code is util.CodeUndetermined, ex:
+// "resident is undetermined: need moved".
+func (b *Binding) Evaluate(ctx context.Context, inputs, outputs interface{}) error {
+	in := reflect.ValueOf(inputs)
+	if in.Kind() == reflect.Ptr && !in.IsNil() {
+		in = in.Elem()
+	}
+	if !in.IsValid() || in.Type() != b.inputs {
+		return fmt.Errorf("policyscript: expected inputs of type %s, got %T", b.inputs, inputs)
+	}
+	out := reflect.ValueOf(outputs)
+	if out.Kind() != reflect.Ptr || out.IsNil() || out.Elem().Type() != b.outputs {
+		return fmt.Errorf("policyscript: expected outputs of type *%s, got %T", b.outputs, outputs)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	values := map[string]object.Object{}
+	var errs util.ErrorList
+	for _, field := range b.in {
+		obj, err := field.conv.encode(in.FieldByIndex(field.index))
+		switch {
+		case err != nil:
+			errs.Add("input "+at(field.name, err).Error(), &field.decl.Range).
+				WithCode(util.CodeInvalidInput)
+		case obj != nil:
+			values[field.name] = obj
+		}
+	}
+	if len(errs) > 0 {
+		return fail(errs)
+	}
+
+	result, err := b.policy.run(ctx, values)
+	if err != nil {
+		return err
+	}
+	for _, field := range b.out {
+		obj, ok := result[field.name]
+		if !ok {
+			continue
+		}
+		v := out.Elem().FieldByIndex(field.index)
+		if unknown, ok := obj.(*object.Unknown); ok {
+			if field.nilable {
+				v.Set(reflect.Zero(v.Type()))
+			} else {
+				errs.Add(field.name+" is "+evaluator.Undetermined(unknown), &field.decl.Range).
+					WithCode(util.CodeUndetermined)
+			}
+			continue
+		}
+		if err := field.conv.decode(obj, v); err != nil {
+			return fmt.Errorf("policyscript: output %s", at(field.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fail(errs)
+	}
+	return nil
+}
+
+// structField is a field of a struct bound to a field of a policy.
+type structField struct {
+	name  string
+	decl  *types.Field
+	index []int
+	conv  *converter
+
+	// Whether the field is a pointer, which is nil if the value is not given
+	// or unknown.
+	nilable bool
+}
+
+// converter converts between Go values of one type and objects of one type.
+// Errors of encode are about values, so they are written as those of
+// types.Decode, with the path of the value.
+type converter struct {
+	encode func(v reflect.Value) (object.Object, error)
+	decode func(o object.Object, v reflect.Value) error
+}
+
+// binder builds converters, and records the mismatches it finds.
+type binder struct {
+	mismatches []string
+
+	// The symbol of the currency of the policy, which money is in.
+	currency string
+
+	// Converters of the structs bound to groups, which are reused so that a
+	// type can refer to itself through a list.
+	structs map[groupStruct]*converter
+}
+
+type groupStruct struct {
+	group *types.Group
+	gt    reflect.Type
+}
+
+func (b *binder) mismatch(format string, args ...interface{}) {
+	b.mismatches = append(b.mismatches, fmt.Sprintf(format, args...))
+}
+
+// fields binds the fields of a struct to declared fields, which are those of
+// owner, ex: "inputs" or "Person".
+func (b *binder) fields(decls []*types.Field, t reflect.Type, owner string) []*structField {
+	if t == nil || t.Kind() != reflect.Struct {
+		b.mismatch("%s must be bound to a struct, not %s", owner, t)
+		return nil
+	}
+
+	tagged := map[string]reflect.StructField{}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := field.Tag.Lookup("law")
+		switch {
+		case !ok || name == "-":
+			continue
+		case field.PkgPath != "":
+			b.mismatch("%s.%s: can not bind an unexported field", t, field.Name)
+			continue
+		}
+		if _, ok := tagged[name]; ok {
+			b.mismatch("%s.%s: %s is bound more than once", t, field.Name, name)
+			continue
+		}
+		tagged[name] = field
+		names = append(names, name)
+	}
+
+	var fields []*structField
+	for _, decl := range decls {
+		field, ok := tagged[decl.Name]
+		if !ok {
+			b.mismatch("%s: no field for %s of %s", t, decl.Name, owner)
+			continue
+		}
+		delete(tagged, decl.Name)
+		gt := field.Type
+		// Optional inputs and outputs may be unknown, so they may be pointers.
+		nilable := (decl.Default != nil || owner == "outputs") && gt.Kind() == reflect.Ptr
+		if nilable {
+			gt = gt.Elem()
+		}
+		conv := b.bind(decl.Type, gt, t.String()+"."+field.Name)
+		if conv == nil {
+			continue
+		}
+		if nilable {
+			conv = pointer(conv)
+		}
+		fields = append(fields, &structField{name: decl.Name, decl: decl, index: field.Index, conv: conv,
+			nilable: nilable})
+	}
+	for _, name := range names {
+		if field, ok := tagged[name]; ok {
+			b.mismatch("%s.%s: %s has no field %s", t, field.Name, owner, name)
+		}
+	}
+	return fields
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	periodType        = reflect.TypeOf(Period{})
+	moneyType         = reflect.TypeOf(Money(0))
+	textMarshaler     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshaler   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	errNotFixedLength = fmt.Errorf("can not be a time.Duration, since it has years or months")
+)
+
+// bind returns the converter between the Go type gt and the type t, or nil
+// if they do not fit, where path is where gt is, ex: "Inputs.Person".
+func (b *binder) bind(t types.Type, gt reflect.Type, path string) *converter {
+	conv := b.convert(t, gt)
+	if conv == nil {
+		b.mismatch("%s: can not bind %s to %s", path, t, gt)
+	}
+	return conv
+}
+
+func (b *binder) convert(t types.Type, gt reflect.Type) *converter {
+	switch t := t.(type) {
+	case *types.List:
+		return b.list(t, gt)
+	case *types.Group:
+		return b.group(t, gt)
+	case *types.Enum:
+		if gt.Kind() != reflect.String {
+			return nil
+		}
+		return &converter{
+			encode: func(v reflect.Value) (object.Object, error) {
+				if !t.Has(v.String()) {
+					return nil, fmt.Errorf("expected one of %s, got %q", strings.Join(t.Members, ", "), v.String())
+				}
+				return &object.Enum{Name: t.Name, Value: v.String()}, nil
+			},
+			decode: func(o object.Object, v reflect.Value) error {
+				v.SetString(o.(*object.Enum).Value)
+				return nil
+			},
+		}
+	}
+
+	switch t {
+	case types.Text:
+		if gt.Kind() == reflect.String {
+			return &converter{
+				encode: func(v reflect.Value) (object.Object, error) {
+					return &object.Text{Value: v.String()}, nil
+				},
+				decode: func(o object.Object, v reflect.Value) error {
+					v.SetString(o.(*object.Text).Value)
+					return nil
+				},
+			}
+		}
+	case types.Integer:
+		switch gt.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return &converter{
+				encode: func(v reflect.Value) (object.Object, error) {
+					return &object.Integer{Value: int(v.Int())}, nil
+				},
+				decode: func(o object.Object, v reflect.Value) error {
+					n := o.(*object.Integer).Value
+					if v.OverflowInt(int64(n)) {
+						return fmt.Errorf("%d does not fit in %s", n, v.Type())
+					}
+					v.SetInt(int64(n))
+					return nil
+				},
+			}
+		}
+	case types.Decimal:
+		if isFloat(gt) {
+			return &converter{
+				encode: func(v reflect.Value) (object.Object, error) {
+					return &object.Decimal{Value: v.Float()}, nil
+				},
+				decode: func(o object.Object, v reflect.Value) error {
+					v.SetFloat(o.(*object.Decimal).Value)
+					return nil
+				},
+			}
+		}
+		if isDecimal(gt) {
+			return decimal(func(n float64) object.Object {
+				return &object.Decimal{Value: n}
+			}, func(o object.Object) float64 {
+				return o.(*object.Decimal).Value
+			})
+		}
+	case types.Money:
+		if gt == moneyType {
+			return &converter{
+				encode: func(v reflect.Value) (object.Object, error) {
+					return &object.Money{Cents: v.Int(), Symbol: b.currency}, nil
+				},
+				decode: func(o object.Object, v reflect.Value) error {
+					v.SetInt(o.(*object.Money).Cents)
+					return nil
+				},
+			}
+		}
+		if isFloat(gt) {
+			return &converter{
+				encode: func(v reflect.Value) (object.Object, error) {
+					return object.NewMoney(v.Float(), b.currency), nil
+				},
+				decode: func(o object.Object, v reflect.Value) error {
+					v.SetFloat(o.(*object.Money).Amount())
+					return nil
+				},
+			}
+		}
+		if isDecimal(gt) {
+			return cents(b.currency)
+		}
+	case types.Percent:
+		if isFloat(gt) {
+			return &converter{
+				encode: func(v reflect.Value) (object.Object, error) {
+					return &object.Percent{Value: v.Float()}, nil
+				},
+				decode: func(o object.Object, v reflect.Value) error {
+					v.SetFloat(o.(*object.Percent).Value)
+					return nil
+				},
+			}
+		}
+	case types.Condition:
+		if gt.Kind() == reflect.Bool {
+			return &converter{
+				encode: func(v reflect.Value) (object.Object, error) {
+					return object.NativeCondition(v.Bool()), nil
+				},
+				decode: func(o object.Object, v reflect.Value) error {
+					v.SetBool(o.(*object.Condition).Value)
+					return nil
+				},
+			}
+		}
+	case types.Date:
+		if gt == timeType {
+			return &converter{
+				encode: func(v reflect.Value) (object.Object, error) {
+					return object.NewDate(v.Interface().(time.Time)), nil
+				},
+				decode: func(o object.Object, v reflect.Value) error {
+					v.Set(reflect.ValueOf(o.(*object.Date).Time()))
+					return nil
+				},
+			}
+		}
+	case types.Time:
+		if gt == timeType {
+			return &converter{
+				encode: func(v reflect.Value) (object.Object, error) {
+					t := v.Interface().(time.Time)
+					return &object.Time{Hours: t.Hour(), Minutes: t.Minute(), Seconds: t.Second()}, nil
+				},
+				decode: func(o object.Object, v reflect.Value) error {
+					t := o.(*object.Time)
+					v.Set(reflect.ValueOf(time.Date(0, 1, 1, t.Hours, t.Minutes, t.Seconds, 0, time.UTC)))
+					return nil
+				},
+			}
+		}
+	case types.Period:
+		switch gt {
+		case durationType:
+			return &converter{
+				encode: func(v reflect.Value) (object.Object, error) {
+					d := time.Duration(v.Int())
+					if d%time.Second != 0 {
+						return nil, fmt.Errorf("%s is not a whole number of seconds", d)
+					}
+					return &object.Period{Seconds: int(d / time.Second)}, nil
+				},
+				decode: func(o object.Object, v reflect.Value) error {
+					p := o.(*object.Period)
+					if p.Years != 0 || p.Months != 0 {
+						return errNotFixedLength
+					}
+					v.SetInt(int64(time.Duration(p.Days)*24*time.Hour + time.Duration(p.Seconds)*time.Second))
+					return nil
+				},
+			}
+		case periodType:
+			return &converter{
+				encode: func(v reflect.Value) (object.Object, error) {
+					p := v.Interface().(Period)
+					return &object.Period{Years: p.Years, Months: p.Months, Days: p.Days, Seconds: p.Seconds}, nil
+				},
+				decode: func(o object.Object, v reflect.Value) error {
+					p := o.(*object.Period)
+					v.Set(reflect.ValueOf(Period{Years: p.Years, Months: p.Months, Days: p.Days, Seconds: p.Seconds}))
+					return nil
+				},
+			}
+		}
+	}
+	return nil
+}
+
+// pointer returns the converter of pointers to the values of conv, which
+// encodes nil as no object.
+func pointer(conv *converter) *converter {
+	return &converter{
+		encode: func(v reflect.Value) (object.Object, error) {
+			if v.IsNil() {
+				return nil, nil
+			}
+			return conv.encode(v.Elem())
+		},
+		decode: func(o object.Object, v reflect.Value) error {
+			value := reflect.New(v.Type().Elem())
+			if err := conv.decode(o, value.Elem()); err != nil {
+				return err
+			}
+			v.Set(value)
+			return nil
+		},
+	}
+}
+
+func (b *binder) list(t *types.List, gt reflect.Type) *converter {
+	if gt.Kind() != reflect.Slice {
+		return nil
+	}
+	elem := b.convert(t.Elem, gt.Elem())
+	if elem == nil {
+		return nil
+	}
+	return &converter{
+		encode: func(v reflect.Value) (object.Object, error) {
+			list := &object.List{Elems: make([]object.Object, v.Len())}
+			for i := range list.Elems {
+				obj, err := elem.encode(v.Index(i))
+				if err != nil {
+					return nil, at(strconv.Itoa(i), err)
+				}
+				list.Elems[i] = obj
+			}
+			return list, nil
+		},
+		decode: func(o object.Object, v reflect.Value) error {
+			elems := o.(*object.List).Elems
+			slice := reflect.MakeSlice(gt, len(elems), len(elems))
+			for i, obj := range elems {
+				if err := elem.decode(obj, slice.Index(i)); err != nil {
+					return at(strconv.Itoa(i), err)
+				}
+			}
+			v.Set(slice)
+			return nil
+		},
+	}
+}
+
+func (b *binder) group(t *types.Group, gt reflect.Type) *converter {
+	if gt.Kind() != reflect.Struct || gt == timeType || gt == periodType {
+		return nil
+	}
+	key := groupStruct{t, gt}
+	if conv, ok := b.structs[key]; ok {
+		return conv
+	}
+	conv := &converter{}
+	if b.structs == nil {
+		b.structs = map[groupStruct]*converter{}
+	}
+	b.structs[key] = conv
+
+	fields := b.fields(t.Fields, gt, t.Name)
+	conv.encode = func(v reflect.Value) (object.Object, error) {
+		group := &object.Group{Name: t.Name, Fields: map[string]object.Object{}}
+		for _, field := range fields {
+			obj, err := field.conv.encode(v.FieldByIndex(field.index))
+			if err != nil {
+				return nil, at(field.name, err)
+			}
+			group.Fields[field.name] = obj
+		}
+		return group, nil
+	}
+	conv.decode = func(o object.Object, v reflect.Value) error {
+		group := o.(*object.Group)
+		for _, field := range fields {
+			if obj, ok := group.Fields[field.name]; ok {
+				if err := field.conv.decode(obj, v.FieldByIndex(field.index)); err != nil {
+					return at(field.name, err)
+				}
+			}
+		}
+		return nil
+	}
+	return conv
+}
+
+// at returns an error about the value of a field or element, whose path is
+// that of err after the key.
+func at(key string, err error) error {
+	if p, ok := err.(*types.Problem); ok {
+		return &types.Problem{Path: "/" + key + p.Path, Msg: p.Msg}
+	}
+	return &types.Problem{Path: "/" + key, Msg: err.Error()}
+}
+
+func isFloat(t reflect.Type) bool {
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
+
+// isDecimal returns whether t is a decimal type, which is written as text
+// and read from text.
+func isDecimal(t reflect.Type) bool {
+	return t != timeType && t.Implements(textMarshaler) && reflect.PtrTo(t).Implements(textUnmarshaler)
+}
+
+// cents returns the converter of a decimal type to money in a currency, which
+// is exact since its text is read and written as a whole number of cents.
+func cents(currency string) *converter {
+	return &converter{
+		encode: func(v reflect.Value) (object.Object, error) {
+			text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return nil, err
+			}
+			n, ok := new(big.Rat).SetString(string(text))
+			if !ok {
+				return nil, fmt.Errorf("expected a number, got %q", text)
+			}
+			// Round half away from zero, as object.NewMoney does.
+			n.Mul(n, big.NewRat(100, 1))
+			half := big.NewRat(1, 2)
+			if n.Sign() < 0 {
+				half.Neg(half)
+			}
+			n.Add(n, half)
+			c := new(big.Int).Quo(n.Num(), n.Denom())
+			if !c.IsInt64() {
+				return nil, fmt.Errorf("%s does not fit in money", text)
+			}
+			return &object.Money{Cents: c.Int64(), Symbol: currency}, nil
+		},
+		decode: func(o object.Object, v reflect.Value) error {
+			cents := o.(*object.Money).Cents
+			c, sign := uint64(cents), ""
+			if cents < 0 {
+				sign, c = "-", -c
+			}
+			text := fmt.Sprintf("%s%d.%02d", sign, c/100, c%100)
+			return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+		},
+	}
+}
+
+// decimal returns the converter of a decimal type, given functions to convert
+// a number to an object and back.
+func decimal(toObject func(float64) object.Object, toNumber func(object.Object) float64) *converter {
+	return &converter{
+		encode: func(v reflect.Value) (object.Object, error) {
+			text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.ParseFloat(string(text), 64)
+			if err != nil {
+				return nil, fmt.Errorf("expected a number, got %q", text)
+			}
+			return toObject(n), nil
+		},
+		decode: func(o object.Object, v reflect.Value) error {
+			text := strconv.FormatFloat(toNumber(o), 'f', -1, 64)
+			return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+		},
+	}
+}
@@ -0,0 +1,298 @@
+package policyscript_test
+
+import (
+	"context"
+	"math"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript"
+	"github.com/policyscript/policyscript/util"
+)
+
+const leave = `@enum Status {
+  - single
+  - married
+}
+
+@define Person {
+  status: Status
+  salary: money
+  born: date
+  children: Child list
+}
+
+@define Child {
+  born: date
+}
+
+@inputs {
+  person: Person
+  today: date
+  rate: percent
+}
+
+@outputs {
+  leave: period
+  waiting: period
+  pay: money
+  children: integer
+}
+
+@code {
+  set children to 0
+  for child in person.children:
+    if today - child.born < 1 year:
+      set children to children + 1
+  set leave to 10 days
+  set waiting to 1 month
+  set pay to person.salary * rate
+}
+`
+
+// amount is a decimal type, written as text like those of decimal packages.
+type amount struct {
+	text string
+}
+
+func (a amount) MarshalText() ([]byte, error) { return []byte(a.text), nil }
+
+func (a *amount) UnmarshalText(text []byte) error {
+	a.text = string(text)
+	return nil
+}
+
+type child struct {
+	Born time.Time `law:"born"`
+}
+
+type person struct {
+	Status   string    `law:"status"`
+	Salary   amount    `law:"salary"`
+	Born     time.Time `law:"born"`
+	Children []child   `law:"children"`
+	Notes    string
+}
+
+type inputs struct {
+	Person person    `law:"person"`
+	Today  time.Time `law:"today"`
+	Rate   float64   `law:"rate"`
+}
+
+type outputs struct {
+	Leave    time.Duration       `law:"leave"`
+	Waiting  policyscript.Period `law:"waiting"`
+	Pay      float64             `law:"pay"`
+	Children int                 `law:"children"`
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+var _ = Describe("Binding", func() {
+	in := inputs{
+		Person: person{
+			Status:   "married",
+			Salary:   amount{"50000.50"},
+			Born:     date(1990, time.May, 1),
+			Children: []child{{Born: date(2021, time.March, 1)}, {Born: date(2015, time.June, 1)}},
+		},
+		Today: date(2021, time.September, 1),
+		Rate:  10,
+	}
+
+	It("evaluates a policy with structs", func() {
+		binding, err := compile(leave).Bind(inputs{}, &outputs{})
+		Expect(err).NotTo(HaveOccurred())
+
+		var out outputs
+		Expect(binding.Evaluate(context.Background(), in, &out)).To(Succeed())
+		Expect(out).To(Equal(outputs{
+			Leave:    10 * 24 * time.Hour,
+			Waiting:  policyscript.Period{Months: 1},
+			Pay:      5000.05,
+			Children: 1,
+		}))
+	})
+
+	It("binds money exactly", func() {
+		type prices struct {
+			Price policyscript.Money `law:"price"`
+			Other amount             `law:"other"`
+		}
+		type totals struct {
+			Total policyscript.Money `law:"total"`
+			Text  amount             `law:"text"`
+		}
+		policy := compile("@inputs {\n  price: money\n  other: money\n}\n\n" +
+			"@outputs {\n  total: money\n  text: money\n}\n\n" +
+			"@code {\n  set total to price + other\n  set text to total\n}\n")
+		binding, err := policy.Bind(prices{}, totals{})
+		Expect(err).NotTo(HaveOccurred())
+
+		// Cents past the precision of a float64.
+		var out totals
+		Expect(binding.Evaluate(context.Background(), prices{Price: 90_071_992_547_409_91, Other: amount{"0.015"}},
+			&out)).To(Succeed())
+		Expect(out).To(Equal(totals{Total: 90_071_992_547_409_93, Text: amount{"90071992547409.93"}}))
+		Expect(out.Total.String()).To(Equal("$90_071_992_547_409.93"))
+		Expect(policyscript.Money(math.MinInt64).String()).To(Equal("-$92_233_720_368_547_758.08"))
+
+		Expect(binding.Evaluate(context.Background(), prices{Other: amount{"-1.005"}}, &out)).To(Succeed())
+		Expect(out.Text).To(Equal(amount{"-1.01"}))
+
+		err = binding.Evaluate(context.Background(), prices{Other: amount{"lots"}}, &out)
+		Expect(err).To(MatchError(ContainSubstring(`input /other: expected a number, got "lots"`)))
+	})
+
+	It("binds money in the currency of the policy", func() {
+		type prices struct {
+			Price policyscript.Money `law:"price"`
+			Fee   float64            `law:"fee"`
+			Other amount             `law:"other"`
+		}
+		type totals struct {
+			Total policyscript.Money `law:"total"`
+		}
+		policy := compile("@inputs {\n  price: money\n  fee: money\n  other: money\n}\n\n" +
+			"@outputs {\n  total: money\n}\n\n" +
+			"@code {\n  set total to price + fee + other + €1\n}\n")
+		binding, err := policy.Bind(prices{}, totals{})
+		Expect(err).NotTo(HaveOccurred())
+
+		var out totals
+		Expect(binding.Evaluate(context.Background(), prices{Price: 1_00, Fee: 2.5, Other: amount{"0.25"}},
+			&out)).To(Succeed())
+		Expect(out.Total.In("€")).To(Equal("€4.75"))
+	})
+
+	It("reports every mismatch when binding", func() {
+		type badPerson struct {
+			Status string  `law:"status"`
+			Salary string  `law:"salary"`
+			Age    int     `law:"age"`
+			Kids   []child `law:"children"`
+		}
+		type badInputs struct {
+			Person badPerson `law:"person"`
+			Today  string    `law:"today"`
+			Rate   float64   `law:"rate"`
+		}
+		type badOutputs struct {
+			Leave   policyscript.Period `law:"leave"`
+			Waiting time.Duration       `law:"waiting"`
+			Pay     float64             `law:"pay"`
+		}
+
+		_, err := compile(leave).Bind(badInputs{}, badOutputs{})
+		Expect(err).To(BeAssignableToTypeOf(&policyscript.BindError{}))
+		Expect(err.(*policyscript.BindError).Mismatches).To(Equal([]string{
+			"policyscript_test.badPerson.Salary: can not bind money to string",
+			"policyscript_test.badPerson: no field for born of Person",
+			"policyscript_test.badPerson.Age: Person has no field age",
+			"policyscript_test.badInputs.Today: can not bind date to string",
+			"policyscript_test.badOutputs: no field for children of outputs",
+		}))
+	})
+
+	It("binds optional inputs to pointers", func() {
+		type sale struct {
+			Sold  *time.Time `law:"sold"`
+			Rate  *float64   `law:"rate"`
+			Today *time.Time `law:"today"`
+		}
+		type result struct {
+			Early *bool   `law:"early"`
+			Rate  float64 `law:"rate_used"`
+		}
+		policy := compile("@inputs {\n  sold: date or unknown\n  rate: percent or 10%\n  today: date\n}\n\n" +
+			"@outputs {\n  early: condition\n  rate_used: percent\n}\n\n" +
+			"@code {\n  set early to sold < today\n  set rate_used to rate\n}\n")
+
+		_, err := policy.Bind(sale{}, result{})
+		Expect(err).To(MatchError("policyscript: policyscript_test.sale.Today: can not bind date to *time.Time"))
+
+		type optional struct {
+			Sold  *time.Time `law:"sold"`
+			Rate  *float64   `law:"rate"`
+			Today time.Time  `law:"today"`
+		}
+		binding, err := policy.Bind(optional{}, result{})
+		Expect(err).NotTo(HaveOccurred())
+
+		early := true
+		out := result{Early: &early}
+		Expect(binding.Evaluate(context.Background(), optional{Today: date(2021, time.May, 1)}, &out)).To(Succeed())
+		Expect(out).To(Equal(result{Rate: 10}))
+
+		sold, rate := date(2020, time.May, 1), 5.0
+		out = result{}
+		Expect(binding.Evaluate(context.Background(), optional{Sold: &sold, Rate: &rate, Today: date(2021, time.May, 1)},
+			&out)).To(Succeed())
+		Expect(out).To(Equal(result{Early: &early, Rate: 5}))
+	})
+
+	It("reports outputs which are unknown", func() {
+		type sale struct {
+			Sold *time.Time `law:"sold"`
+		}
+		type result struct {
+			Early bool `law:"early"`
+			Late  bool `law:"late"`
+		}
+		policy := compile("@inputs {\n  sold: date or unknown\n}\n\n" +
+			"@outputs {\n  early: condition\n  late: condition\n}\n\n" +
+			"@code {\n  set early to sold < |2020/01/01|\n  set late to true\n}\n")
+		binding, err := policy.Bind(sale{}, result{})
+		Expect(err).NotTo(HaveOccurred())
+
+		out := result{}
+		err = binding.Evaluate(context.Background(), sale{}, &out)
+		Expect(err).To(MatchError(ContainSubstring("early is undetermined: need sold")))
+		Expect(err.(*policyscript.Error).Diagnostics[0].Code).To(Equal(util.CodeUndetermined))
+		Expect(out).To(Equal(result{Late: true}))
+	})
+
+	It("reports values which do not fit their types", func() {
+		binding, err := compile(leave).Bind(inputs{}, outputs{})
+		Expect(err).NotTo(HaveOccurred())
+
+		bad := in
+		bad.Person.Status = "divorced"
+		err = binding.Evaluate(context.Background(), &bad, &outputs{})
+		Expect(err).To(BeAssignableToTypeOf(&policyscript.Error{}))
+		diagnostic := err.(*policyscript.Error).Diagnostics[0]
+		Expect(diagnostic.Msg).To(Equal(`input /person/status: expected one of single, married, got "divorced"`))
+		Expect(diagnostic.Code).To(Equal(util.CodeInvalidInput))
+
+		// Periods are counted in whole seconds.
+		type leaves struct {
+			Leave time.Duration `law:"leave"`
+		}
+		withLeave, err := compile("@inputs {\n  leave: period\n}\n").Bind(leaves{}, struct{}{})
+		Expect(err).NotTo(HaveOccurred())
+		err = withLeave.Evaluate(context.Background(), leaves{Leave: 1500 * time.Millisecond}, &struct{}{})
+		Expect(err).To(MatchError(ContainSubstring("input /leave: 1.5s is not a whole number of seconds")))
+
+		Expect(binding.Evaluate(context.Background(), 1, &outputs{})).To(MatchError(
+			"policyscript: expected inputs of type policyscript_test.inputs, got int"))
+		Expect(binding.Evaluate(context.Background(), in, outputs{})).To(MatchError(
+			"policyscript: expected outputs of type *policyscript_test.outputs, got policyscript_test.outputs"))
+	})
+
+	It("reports outputs which do not fit their fields", func() {
+		type durations struct {
+			Leave    time.Duration `law:"leave"`
+			Waiting  time.Duration `law:"waiting"`
+			Pay      float64       `law:"pay"`
+			Children int8          `law:"children"`
+		}
+		binding, err := compile(leave).Bind(inputs{}, durations{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(binding.Evaluate(context.Background(), in, &durations{})).To(MatchError(
+			"policyscript: output /waiting: can not be a time.Duration, since it has years or months"))
+	})
+})
@@ -0,0 +1,311 @@
+// Package citation assigns labels and citation paths to headings, such as
+// "121(a)(1)", and validates references to them.
+package citation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/parser"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Meta fields which configure numbering.
+const (
+	metaNumbering = "numbering"
+	metaPath      = "path"
+)
+
+// Resolve reads the numbering scheme and section path from the @meta blocks of
+// the program, numbers every heading, checks all references and then attaches
+// citations to the code.
+func Resolve(program *ast.Program) util.ErrorList {
+	scheme, root, errs := Meta(program)
+	errs = append(errs, Number(program, scheme, root)...)
+	errs = append(errs, Check(program, root)...)
+	Attach(program)
+	return errs
+}
+
+// Parse scans and parses a file, and resolves its citations if it has no
+// syntax errors.
+func Parse(file *util.File) (*ast.Program, util.ErrorList) {
+	var errs util.ErrorList
+	s := scanner.New(file, func(err *util.Error) {
+		errs = append(errs, err)
+	})
+	p := parser.New(*s)
+	program := p.ParseProgram()
+	if errs = append(errs, p.Errors()...); len(errs) > 0 {
+		return program, errs
+	}
+	return program, Resolve(program)
+}
+
+// Meta returns the numbering scheme and section path declared in the @meta
+// blocks of the program, for example:
+//
+//	@meta {
+//	  set path      to `121`
+//	  set numbering to `legal`
+//	}
+//
+// The scheme is nil if none is declared.
+func Meta(program *ast.Program) (scheme Scheme, root string, errs util.ErrorList) {
+	for _, stmt := range program.Stmts {
+		block, ok := stmt.(*ast.BlockStatement)
+		if !ok || block.Token.Type != token.META {
+			continue
+		}
+
+		for _, stmt := range block.Stmts {
+			set := metaSet(stmt)
+			if set == nil {
+				continue
+			}
+
+			switch set.Ident.Value {
+			case metaPath:
+				if text, ok := set.Value.(*ast.TextLiteral); ok {
+					root = text.Value
+				} else {
+					errs.Add("meta path must be text", set.Value.Range()).
+						WithCode(util.CodeInvalidMeta)
+				}
+			case metaNumbering:
+				text, ok := set.Value.(*ast.TextLiteral)
+				if !ok {
+					errs.Add("meta numbering must be text", set.Value.Range()).
+						WithCode(util.CodeInvalidMeta)
+					continue
+				}
+				if scheme, ok = LookupScheme(text.Value); !ok {
+					err := errs.Add(fmt.Sprintf("unknown numbering scheme %q", text.Value),
+						set.Value.Range()).WithCode(util.CodeUnknownNumbering)
+					if name, ok := util.Closest(text.Value, schemeNames()); ok {
+						err.WithFix(fmt.Sprintf("Change to %q", name),
+							util.Replace(set.Value.Range(), "`"+name+"`"))
+					}
+				}
+			}
+		}
+	}
+	return scheme, root, errs
+}
+
+func metaSet(stmt ast.Stmt) *ast.SetExpression {
+	exp, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return nil
+	}
+	set, _ := exp.Expr.(*ast.SetExpression)
+	return set
+}
+
+// Number assigns a label and citation path to every heading in the program.
+//
+// A heading which begins with an explicit label, such as "(a) Ability to
+// read", keeps that label and has it removed from its value. Otherwise the
+// label is generated from the scheme for the heading's depth, continuing from
+// the previous sibling. Headings without a label are not given a path.
+func Number(program *ast.Program, scheme Scheme, root string) util.ErrorList {
+	var (
+		errs     util.ErrorList
+		counters []int
+		labels   []string
+		paths    = map[string]*ast.HeadingStatement{}
+	)
+
+	for _, stmt := range program.Stmts {
+		heading, ok := stmt.(*ast.HeadingStatement)
+		if !ok || heading.Depth < 1 {
+			continue
+		}
+
+		depth := heading.Depth
+		for len(counters) < depth {
+			counters = append(counters, 0)
+			labels = append(labels, "")
+		}
+		counters, labels = counters[:depth], labels[:depth]
+		counters[depth-1]++
+
+		var style *Style
+		if depth <= len(scheme) {
+			style = &scheme[depth-1]
+		}
+
+		label, value := splitLabel(heading.Value)
+		switch {
+		case label != "":
+			heading.Value = value
+			if style == nil {
+				break
+			}
+			if n, ok := style.Parse(label); ok {
+				counters[depth-1] = n
+			} else {
+				errs.Add(fmt.Sprintf("label %q does not match the numbering scheme",
+					label), heading.Range()).WithCode(util.CodeLabelMismatch)
+			}
+		case style != nil:
+			label = style.Format(counters[depth-1])
+		case scheme != nil:
+			errs.Add(fmt.Sprintf("numbering scheme has no style for depth %d", depth),
+				heading.Range()).WithCode(util.CodeNumberingDepth)
+		}
+
+		labels[depth-1] = label
+		heading.Label = label
+		heading.Path = ""
+		if label == "" {
+			continue
+		}
+
+		heading.Path = join(root, labels)
+		if first, ok := paths[heading.Path]; ok {
+			errs.Add(fmt.Sprintf("duplicate citation path %s", heading.Path),
+				heading.Range()).WithCode(util.CodeDuplicateCite).
+				WithRelated("first used here", first.Range())
+			continue
+		}
+		paths[heading.Path] = heading
+	}
+	return errs
+}
+
+// Attach sets the citation of every block and set expression to the path of
+// the closest heading above it. Number must be called first.
+func Attach(program *ast.Program) {
+	path := ""
+	for _, stmt := range program.Stmts {
+		switch stmt := stmt.(type) {
+		case *ast.HeadingStatement:
+			path = stmt.Path
+		case *ast.BlockStatement:
+			stmt.Citation = path
+			ast.Inspect(stmt, func(node ast.Node) bool {
+				if set, ok := node.(*ast.SetExpression); ok {
+					set.Citation = path
+				}
+				return true
+			})
+		}
+	}
+}
+
+// Cite formats a citation path for messages, ex: "§121(b)(1)". An empty path
+// is formatted as an empty string.
+func Cite(path string) string {
+	if path == "" {
+		return ""
+	}
+	return "§" + path
+}
+
+// splitLabel splits "(a) Title" into "a" and "Title".
+func splitLabel(value string) (label string, rest string) {
+	if !strings.HasPrefix(value, "(") {
+		return "", value
+	}
+	end := strings.IndexRune(value, ')')
+	if end < 2 || !isLabel(value[1:end]) {
+		return "", value
+	}
+	return value[1:end], strings.TrimLeft(value[end+1:], " \t")
+}
+
+func isLabel(label string) bool {
+	for _, ch := range label {
+		if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func join(root string, labels []string) string {
+	path := root
+	for _, label := range labels {
+		if label != "" {
+			path += "(" + label + ")"
+		}
+	}
+	return path
+}
+
+// A reference is written as "§" followed by a citation path. References which
+// begin with "(" are relative to the section path of the document.
+var reference = regexp.MustCompile(`§\s?([0-9A-Za-z\-]*(?:\([0-9A-Za-z]+\))+|[0-9A-Za-z\-]+)`)
+
+// Check reports every reference in a paragraph or heading which does not
+// match the citation path of a heading. References to other sections are not
+// checked.
+func Check(program *ast.Program, root string) util.ErrorList {
+	var (
+		errs  util.ErrorList
+		paths = map[string]bool{root: true}
+		known = []string{root}
+	)
+
+	for _, stmt := range program.Stmts {
+		if heading, ok := stmt.(*ast.HeadingStatement); ok && heading.Path != "" {
+			paths[heading.Path] = true
+			known = append(known, heading.Path)
+		}
+	}
+
+	for _, stmt := range program.Stmts {
+		var tok token.Token
+		switch stmt := stmt.(type) {
+		case *ast.ParagraphStatement:
+			tok = stmt.Token
+		case *ast.HeadingStatement:
+			tok = stmt.Token
+		default:
+			continue
+		}
+
+		for _, match := range reference.FindAllStringSubmatchIndex(tok.Literal, -1) {
+			path := tok.Literal[match[2]:match[3]]
+			if strings.HasPrefix(path, "(") {
+				path = root + path
+			} else if !strings.HasPrefix(path, root+"(") && path != root {
+				continue
+			}
+
+			if !paths[path] {
+				rng := util.Range{
+					Start: advance(tok.Range.Start, tok.Literal[:match[0]]),
+					End:   advance(tok.Range.Start, tok.Literal[:match[1]]),
+				}
+				err := errs.Add(fmt.Sprintf("reference to unknown section §%s", path), &rng).
+					WithCode(util.CodeUnknownSection)
+				if closest, ok := util.Closest(path, known); ok {
+					err.WithFix("Change to §"+closest, util.Replace(&rng, "§"+closest))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// advance returns the position after reading text from pos.
+func advance(pos util.Position, text string) util.Position {
+	for _, ch := range text {
+		pos.Offset++
+		pos.ByteOffset += utf8.RuneLen(ch)
+		if ch == '\n' {
+			pos.Line++
+			pos.Column = 0
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
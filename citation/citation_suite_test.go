@@ -0,0 +1,13 @@
+package citation_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCitation(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Citation Suite")
+}
@@ -0,0 +1,127 @@
+package citation_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/citation"
+	"github.com/policyscript/policyscript/parser"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/util"
+)
+
+const meta = "@meta {\n  set path to `121`\n  set numbering to `legal`\n}\n\n"
+
+var _ = Describe("Citation", func() {
+	util.Each("can format legal labels", [][2]string{
+		{"1 1", "a"},
+		{"1 27", "aa"},
+		{"2 12", "12"},
+		{"3 3", "C"},
+		{"4 4", "iv"},
+		{"5 14", "XIV"},
+	}, func(input, expects string) {
+		var depth, n int
+		_, _ = fmt.Sscan(input, &depth, &n)
+
+		label := citation.Legal[depth-1].Format(n)
+		Expect(label).To(Equal(expects))
+
+		parsed, ok := citation.Legal[depth-1].Parse(label)
+		Expect(ok).To(BeTrue())
+		Expect(parsed).To(Equal(n))
+	})
+
+	util.Each("can number headings", [][2]string{
+		{meta + "_ A\n\n_ _ B\n\n_ _ C\n\n_ D", "121(a) 121(a)(1) 121(a)(2) 121(b)"},
+		{meta + "_ A\n\n_ _ _ B\n\n_ _ C", "121(a) 121(a)(A) 121(a)(1)"},
+		{meta + "_ (c) A\n\n_ B\n\n_ _ (5) C\n\n_ _ D", "121(c) 121(d) 121(d)(5) 121(d)(6)"},
+		{"_ (a) A\n\n_ B\n\n_ _ (1) C", "(a) - (1)"},
+		{"@meta {\n  set path to `121`\n}\n\n_ (a) A\n\n_ _ (1) C", "121(a) 121(a)(1)"},
+	}, func(input, expects string) {
+		program := parse(input)
+		Expect(citation.Resolve(program)).To(BeEmpty())
+
+		paths := []string{}
+		for _, stmt := range program.Stmts {
+			if heading, ok := stmt.(*ast.HeadingStatement); ok {
+				path := heading.Path
+				if path == "" {
+					path = "-"
+				}
+				paths = append(paths, path)
+			}
+		}
+		Expect(strings.Join(paths, " ")).To(Equal(expects))
+	})
+
+	It("removes explicit labels from the heading value", func() {
+		program := parse(meta + "_ (a) Ability to read")
+		Expect(citation.Resolve(program)).To(BeEmpty())
+
+		heading := program.Stmts[1].(*ast.HeadingStatement)
+		Expect(heading.Label).To(Equal("a"))
+		Expect(heading.Value).To(Equal("Ability to read"))
+	})
+
+	util.Each("reports error", [][2]string{
+		{"@meta {\n  set numbering to `unknown`\n}", `unknown numbering scheme "unknown"`},
+		{meta + "_ (1) A", `label "1" does not match the numbering scheme`},
+		{meta + "_ (a) A\n\n_ (a) B", "duplicate citation path 121(a)"},
+		{meta + "_ A\n\nSee §121(b).", "reference to unknown section §121(b)"},
+		{meta + "_ A\n\nSee §(a)(1).", "reference to unknown section §121(a)(1)"},
+	}, func(input, expects string) {
+		errs := citation.Resolve(parse(input))
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Msg).To(Equal(expects))
+	})
+
+	It("reports the position of a reference", func() {
+		errs := citation.Resolve(parse(meta + "_ A\n\nFirst line\nsee §121(b)"))
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Rng.Start.Line).To(Equal(9))
+		Expect(errs[0].Rng.Start.Column).To(Equal(4))
+		Expect(errs[0].Rng.End.Column).To(Equal(11))
+	})
+
+	It("attaches citations to blocks and set expressions", func() {
+		program := parse(meta + "@code {\n  set a to 1\n}\n\n_ A\n\n_ _ B\n\n" +
+			"@code {\n  if a:\n    set b to 2\n}")
+		Expect(citation.Resolve(program)).To(BeEmpty())
+
+		first := program.Stmts[1].(*ast.BlockStatement)
+		Expect(first.Citation).To(Equal(""))
+
+		second := program.Stmts[4].(*ast.BlockStatement)
+		Expect(second.Citation).To(Equal("121(a)(1)"))
+
+		set := second.Stmts[0].(*ast.IfStatement).Block.Stmts[0].(*ast.ExpressionStatement).Expr
+		Expect(set.(*ast.SetExpression).Citation).To(Equal("121(a)(1)"))
+		Expect(citation.Cite("121(a)(1)")).To(Equal("§121(a)(1)"))
+	})
+
+	It("parses a file and resolves its citations", func() {
+		program, errs := citation.Parse(util.NewFile("a.law", []byte(meta+"_ A\n\n@code {\n  set a to 1\n}")))
+		Expect(errs).To(BeEmpty())
+		Expect(program.Stmts[2].(*ast.BlockStatement).Citation).To(Equal("121(a)"))
+
+		_, errs = citation.Parse(util.NewFile("a.law", []byte("_ A\n\nSee §9.\n\n@code {\n  set a to\n}")))
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Rng.Start.Filename).To(Equal("a.law"))
+	})
+
+	It("accepts references to known and other sections", func() {
+		program := parse(meta + "_ A\n\n_ _ B\n\nSee §121(a)(1), §(a) and §1031(b).")
+		Expect(citation.Resolve(program)).To(BeEmpty())
+	})
+})
+
+func parse(input string) *ast.Program {
+	p := parser.New(*scanner.New(util.NewFile("", []byte(input)), nil))
+	program := p.ParseProgram()
+	Expect(p.Errors()).To(BeEmpty())
+	return program
+}
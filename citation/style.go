@@ -0,0 +1,106 @@
+package citation
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Style is the way a heading label is written at a given depth.
+type Style int
+
+const (
+	LowerAlpha Style = iota // a, b, ..., z, aa, bb
+	Arabic                  // 1, 2, 3
+	UpperAlpha              // A, B, ..., Z, AA, BB
+	LowerRoman              // i, ii, iii
+	UpperRoman              // I, II, III
+)
+
+// Scheme is a list of styles, where the first style is used for headings of
+// depth 1, the second for depth 2 and so on.
+type Scheme []Style
+
+// Legal is the scheme used by the U.S. Code: subsection (a), paragraph (1),
+// subparagraph (A), clause (i) and subclause (I).
+var Legal = Scheme{LowerAlpha, Arabic, UpperAlpha, LowerRoman, UpperRoman}
+
+var schemes = map[string]Scheme{
+	"legal": Legal,
+}
+
+// LookupScheme will return the scheme and true, or nil and false if not found.
+func LookupScheme(name string) (Scheme, bool) {
+	scheme, ok := schemes[name]
+	return scheme, ok
+}
+
+// schemeNames returns the names of the schemes, sorted.
+func schemeNames() []string {
+	var names []string
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Format returns the label for the n-th (1-indexed) heading.
+func (s Style) Format(n int) string {
+	switch s {
+	case Arabic:
+		return strconv.Itoa(n)
+	case LowerAlpha:
+		return formatAlpha(n, 'a')
+	case UpperAlpha:
+		return formatAlpha(n, 'A')
+	case LowerRoman:
+		return strings.ToLower(formatRoman(n))
+	case UpperRoman:
+		return formatRoman(n)
+	}
+	return ""
+}
+
+// Parse returns the position of a label, or false if the label is not written
+// in this style.
+func (s Style) Parse(label string) (int, bool) {
+	for n := 1; n <= maxLabel; n++ {
+		if s.Format(n) == label {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// maxLabel is the highest position Parse will recognize.
+const maxLabel = 1000
+
+// Labels past "z" are doubled, then tripled, as is done in the U.S. Code.
+func formatAlpha(n int, first rune) string {
+	var (
+		letter = first + rune((n-1)%26)
+		count  = (n-1)/26 + 1
+	)
+	return strings.Repeat(string(letter), count)
+}
+
+var romanNumerals = []struct {
+	value  int
+	symbol string
+}{
+	{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"},
+	{100, "C"}, {90, "XC"}, {50, "L"}, {40, "XL"},
+	{10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+}
+
+func formatRoman(n int) string {
+	var b strings.Builder
+	for _, numeral := range romanNumerals {
+		for n >= numeral.value {
+			b.WriteString(numeral.symbol)
+			n -= numeral.value
+		}
+	}
+	return b.String()
+}
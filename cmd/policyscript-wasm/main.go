@@ -0,0 +1,40 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command policyscript-wasm is the WebAssembly build of PolicyScript. It sets
+// a global "policyscript" object with the functions of package wasm, which
+// each take a request and return a response as JSON text, and then waits to
+// be called. Build it with:
+//
+//	GOOS=js GOARCH=wasm go build -o policyscript.wasm ./cmd/policyscript-wasm
+//
+// and run it with the wasm_exec.js of the Go distribution, as the frontend
+// package does.
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/policyscript/policyscript/wasm"
+)
+
+func main() {
+	exports := map[string]interface{}{}
+	for name, f := range wasm.Functions {
+		exports[name] = export(f)
+	}
+	js.Global().Set("policyscript", js.ValueOf(exports))
+
+	// The functions can only be called while the program runs.
+	select {}
+}
+
+// export wraps a function as a JavaScript function of one string.
+func export(f func(request string) string) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != 1 || args[0].Type() != js.TypeString {
+			return `{"error": "expected a request as JSON text"}`
+		}
+		return f(args[0].String())
+	})
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	ps "github.com/policyscript/policyscript"
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/citation"
+	"github.com/policyscript/policyscript/diagnostic"
+	"github.com/policyscript/policyscript/evaluator"
+	"github.com/policyscript/policyscript/format"
+	"github.com/policyscript/policyscript/gen"
+	"github.com/policyscript/policyscript/lsp"
+	"github.com/policyscript/policyscript/render"
+	"github.com/policyscript/policyscript/repl"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/types"
+	"github.com/policyscript/policyscript/util"
+	"gopkg.in/yaml.v2"
+)
+
+func cmdScan(c *cli, args []string) int {
+	if !c.parseFlags(c.flags("<file>"), args) {
+		return exitUsage
+	}
+
+	var errs util.ErrorList
+	tokens := scanner.New(c.file, func(err *util.Error) {
+		errs = append(errs, err)
+	}).Scan()
+
+	var b strings.Builder
+	for _, tok := range tokens {
+		fmt.Fprintln(&b, tok)
+	}
+	return c.report(map[string]interface{}{"tokens": tokens}, b.String(), errs)
+}
+
+func cmdParse(c *cli, args []string) int {
+	if !c.parseFlags(c.flags("<file>"), args) {
+		return exitUsage
+	}
+
+	// The JSON of the program is the versioned encoding of the ast package,
+	// which can be decoded by ast.Unmarshal.
+	program, errs := citation.Parse(c.file)
+	data, err := ast.Marshal(program)
+	if err != nil {
+		c.fail(err)
+		return exitUsage
+	}
+	return c.report(map[string]interface{}{"program": json.RawMessage(data)}, tree(program).String(), errs)
+}
+
+func cmdCheck(c *cli, args []string) int {
+	if !c.parseFilesFlags(c.flags("<file>..."), args, true) {
+		return exitUsage
+	}
+
+	_, errs := c.check()
+	return c.report(nil, "", errs)
+}
+
+func cmdRun(c *cli, args []string) int {
+	flags := c.flags("<file>...")
+	inputsPath := flags.String("inputs", "", "read the inputs from a JSON or YAML `file`")
+	trace := flags.Bool("trace", false, "explain how each output was computed")
+	if !c.parseFilesFlags(flags, args, true) {
+		return exitUsage
+	}
+
+	values := map[string]interface{}{}
+	if *inputsPath != "" {
+		var err error
+		if values, err = readInputs(*inputsPath); err != nil {
+			c.fail(err)
+			return exitUsage
+		}
+	}
+
+	// Warnings do not stop the program from running, and are reported with
+	// its result.
+	policy, warnings := c.check()
+	if policy == nil {
+		return c.report(nil, "", warnings)
+	}
+
+	program := policy.Program()
+	inputs, errs := types.DecodeInputs(policy.Info(), values, program.Range())
+	if len(errs) > 0 {
+		return c.report(nil, "", append(warnings, errs...))
+	}
+
+	var t *evaluator.Trace
+	if *trace {
+		t = &evaluator.Trace{}
+	}
+	result, errs := evaluator.Evaluate(program, inputs, t)
+
+	names := make([]string, 0, len(result.Outputs))
+	for name := range result.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	outputs := map[string]interface{}{}
+	var b strings.Builder
+	for _, name := range names {
+		value := result.Outputs[name]
+		outputs[name] = types.Encode(value)
+		fmt.Fprintf(&b, "%s = %s", name, value.Inspect())
+		if cite := citation.Cite(result.Citations[name]); cite != "" {
+			fmt.Fprintf(&b, " (%s)", cite)
+		}
+		b.WriteString("\n")
+	}
+
+	report := map[string]interface{}{"outputs": outputs, "citations": result.Citations}
+	if t != nil {
+		report["trace"] = t
+		b.WriteString("\n" + t.Narrative(c.files))
+	}
+	return c.report(report, b.String(), append(warnings, errs...))
+}
+
+func cmdRender(c *cli, args []string) int {
+	if !c.parseFlags(c.flags("<file>"), args) {
+		return exitUsage
+	}
+
+	program, errs := citation.Parse(c.file)
+	if len(errs) > 0 {
+		return c.report(nil, "", errs)
+	}
+
+	markdown := render.Prose(program)
+	return c.report(map[string]interface{}{"markdown": markdown}, markdown, nil)
+}
+
+func cmdFmt(c *cli, args []string) int {
+	flags := c.flags("<file>...")
+	write := flags.Bool("w", false, "write the result to each file instead of stdout")
+	check := flags.Bool("check", false, "list the files which are not formatted, and fail if there are any")
+	if !c.parseFilesFlags(flags, args, true) {
+		return exitUsage
+	}
+
+	// Files with errors are reported and left as they are.
+	var (
+		errs        util.ErrorList
+		unformatted = []string{}
+		formatted   = map[string]string{}
+		b           strings.Builder
+	)
+	for _, file := range c.files.Files() {
+		source, fileErrs := format.Source(file)
+		if fileErrs != nil {
+			errs = append(errs, fileErrs...)
+			continue
+		}
+		changed := !bytes.Equal(source, file.Source())
+
+		switch {
+		case *check:
+			if changed {
+				unformatted = append(unformatted, file.Name())
+				fmt.Fprintln(&b, file.Name())
+			}
+		case *write:
+			if changed {
+				if err := writeFile(file.Name(), source); err != nil {
+					c.fail(err)
+					return exitUsage
+				}
+			}
+		default:
+			formatted[file.Name()] = string(source)
+			b.Write(source)
+		}
+	}
+
+	result := map[string]interface{}{}
+	switch {
+	case *check:
+		result["unformatted"] = unformatted
+	case !*write:
+		result["formatted"] = formatted
+	}
+	if code := c.report(result, b.String(), errs); code != exitOK {
+		return code
+	}
+	if len(unformatted) > 0 {
+		return exitErrors
+	}
+	return exitOK
+}
+
+func cmdRepl(c *cli, args []string) int {
+	flags := c.flags("[file]...")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	format, ok := diagnostic.LookupFormat(c.format)
+	if !ok {
+		c.fail(fmt.Errorf("unknown format %q", c.format))
+		return exitUsage
+	}
+
+	// The types of the files are loaded into the session.
+	c.files = util.NewFileSet()
+	session := repl.New(c.files)
+	for _, path := range flags.Args() {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			c.fail(err)
+			return exitUsage
+		}
+		file := c.files.AddFile(path, source)
+		if file.Name() != path {
+			c.fail(fmt.Errorf("%s is named more than once", path))
+			return exitUsage
+		}
+		if errs := session.Load(file); len(errs) > 0 {
+			return c.report(nil, "", errs)
+		}
+	}
+
+	printer := &diagnostic.Printer{Format: format, Color: color(c.stdout), Files: c.files}
+	if err := repl.Run(session, os.Stdin, c.stdout, printer); err != nil {
+		c.fail(err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+func cmdGen(c *cli, args []string) int {
+	languages := map[string]bool{"go": true, "ts": true, "schema": true, "openapi": true}
+	if len(args) == 0 || !languages[args[0]] {
+		fmt.Fprint(c.stderr, "Usage: policyscript gen <go|ts|schema|openapi> [flags] <file>...\n")
+		return exitUsage
+	}
+	language := args[0]
+	c.name += " " + language
+
+	flags := c.flags("<file>...")
+	output := flags.String("o", "", "write the code to `file` instead of stdout")
+	var pkg, title *string
+	switch language {
+	case "go":
+		pkg = flags.String("package", "policy", "name the Go package `name`")
+	case "openapi":
+		title = flags.String("title", "", "the `title` of the API, by default the name of the first file")
+	}
+	if !c.parseFilesFlags(flags, args[1:], true) {
+		return exitUsage
+	}
+
+	policy, err := ps.Compile(c.files)
+	if err != nil {
+		return c.report(nil, "", err.(*ps.Error).Diagnostics)
+	}
+	var code []byte
+	switch language {
+	case "go":
+		code, err = gen.Go(c.files, policy.Info(), *pkg)
+	case "ts":
+		code, err = gen.TypeScript(policy.Info())
+	case "schema":
+		code, err = gen.JSONSchema(policy.Info())
+	case "openapi":
+		if *title == "" {
+			*title = strings.TrimSuffix(filepath.Base(c.path), filepath.Ext(c.path))
+		}
+		code, err = gen.OpenAPI(policy.Info(), *title)
+	}
+	if err != nil {
+		c.fail(err)
+		return exitUsage
+	}
+
+	text := string(code)
+	if *output != "" {
+		if err := ioutil.WriteFile(*output, code, 0644); err != nil {
+			c.fail(err)
+			return exitUsage
+		}
+		text = ""
+	}
+	return c.report(map[string]interface{}{"code": string(code)}, text, policy.Warnings())
+}
+
+func cmdLsp(c *cli, args []string) int {
+	flags := c.flags("")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		return exitUsage
+	}
+
+	if err := lsp.Serve(os.Stdin, c.stdout); err != nil {
+		c.fail(err)
+		return exitErrors
+	}
+	return exitOK
+}
+
+// writeFile replaces the contents of a file, keeping its permissions.
+func writeFile(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, info.Mode().Perm())
+}
+
+// readInputs reads a JSON or YAML object, where files ending in .yaml or .yml
+// are YAML.
+func readInputs(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &value)
+		value = stringKeys(value)
+	default:
+		err = json.Unmarshal(data, &value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: expected an object of inputs", path)
+	}
+	return values, nil
+}
+
+// stringKeys converts the maps decoded from YAML, which may have keys of any
+// type, into maps with text keys like those decoded from JSON.
+func stringKeys(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for key, elem := range value {
+			m[fmt.Sprint(key)] = stringKeys(elem)
+		}
+		return m
+	case []interface{}:
+		for i, elem := range value {
+			value[i] = stringKeys(elem)
+		}
+	}
+	return value
+}
@@ -0,0 +1,236 @@
+// Command policyscript scans, parses, checks, runs, renders and formats
+// PolicyScript (.law) files, evaluates statements as they are typed, and
+// generates code for the declarations of files.
+//
+// Every command takes a -json flag to write its result and diagnostics as a
+// single JSON object. Otherwise diagnostics are written to stderr in the
+// format given by -format: pretty, with source snippets and colors when
+// stderr is a terminal, plain, json or sarif. The exit code is 0 on success,
+// 1 if the file has errors and 2 if the command could not be run.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	ps "github.com/policyscript/policyscript"
+	"github.com/policyscript/policyscript/diagnostic"
+	"github.com/policyscript/policyscript/util"
+)
+
+const usage = `Usage: policyscript <command> [flags] <file>...
+
+Commands:
+  scan     print the tokens of a file
+  parse    print the syntax tree of a file
+  check    report errors in files
+  run      evaluate files with inputs from a JSON or YAML file
+  render   write a file as Markdown, with its code explained in plain English
+  fmt      write files in the canonical layout
+  repl     evaluate statements as they are typed, with the types of files
+  gen      generate Go or TypeScript types, a JSON Schema or an OpenAPI document
+           for the inputs and outputs of files
+  lsp      run a language server over stdin and stdout
+
+Run "policyscript <command> -h" for the flags of a command.
+`
+
+// Exit codes.
+const (
+	exitOK     = 0
+	exitErrors = 1 // the file has errors
+	exitUsage  = 2 // the command could not be run
+)
+
+type command func(c *cli, args []string) int
+
+var commands = map[string]command{
+	"scan":   cmdScan,
+	"parse":  cmdParse,
+	"check":  cmdCheck,
+	"run":    cmdRun,
+	"render": cmdRender,
+	"fmt":    cmdFmt,
+	"repl":   cmdRepl,
+	"gen":    cmdGen,
+	"lsp":    cmdLsp,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command line with the given arguments, without the program
+// name, and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "policyscript: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+	return cmd(&cli{name: args[0], stdout: stdout, stderr: stderr}, args[1:])
+}
+
+// cli holds what is shared by the commands.
+type cli struct {
+	name   string
+	stdout io.Writer
+	stderr io.Writer
+	json   bool
+	format string
+
+	// Path and source of the file being run, in a set with the other files
+	// named by the command.
+	path  string
+	files *util.FileSet
+	file  *util.File
+}
+
+// flags returns the flag set of the command, with the -json and -format flags
+// added.
+func (c *cli) flags(args string) *flag.FlagSet {
+	flags := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.BoolVar(&c.json, "json", false, "write the result and diagnostics as JSON")
+	flags.StringVar(&c.format, "format", string(diagnostic.Pretty),
+		"write diagnostics as `pretty`, plain, json or sarif")
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: policyscript %s [flags] %s\n\nFlags:\n", c.name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses the flags of the command and reads the file named by the
+// only remaining argument. It returns false if the command can not be run.
+func (c *cli) parseFlags(flags *flag.FlagSet, args []string) bool {
+	return c.parseFilesFlags(flags, args, false)
+}
+
+// parseFilesFlags parses the flags of the command and reads the files named by
+// the remaining arguments, of which there must be one, or more if many is
+// true. The first file is the file being run.
+func (c *cli) parseFilesFlags(flags *flag.FlagSet, args []string, many bool) bool {
+	if err := flags.Parse(args); err != nil {
+		return false
+	}
+	if flags.NArg() == 0 || !many && flags.NArg() != 1 {
+		flags.Usage()
+		return false
+	}
+	if _, ok := diagnostic.LookupFormat(c.format); !ok {
+		c.fail(fmt.Errorf("unknown format %q", c.format))
+		return false
+	}
+
+	c.files = util.NewFileSet()
+	for _, path := range flags.Args() {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			c.fail(err)
+			return false
+		}
+		// A file named twice would be renamed by the FileSet, and then
+		// written under that name by fmt -w.
+		if file := c.files.AddFile(path, source); file.Name() != path {
+			c.fail(fmt.Errorf("%s is named more than once", path))
+			return false
+		}
+	}
+	c.path = flags.Arg(0)
+	c.file = c.files.Files()[0]
+	return true
+}
+
+// check compiles the files with policyscript.Compile. The policy is nil if
+// any file has an error, and the diagnostics are every error and warning.
+func (c *cli) check() (*ps.Policy, util.ErrorList) {
+	policy, err := ps.Compile(c.files)
+	var compileErr *ps.Error
+	if errors.As(err, &compileErr) {
+		return nil, compileErr.Diagnostics
+	}
+	return policy, policy.Warnings()
+}
+
+// jsonDiagnostic is an error as written by -json.
+type jsonDiagnostic struct {
+	File string `json:"file"`
+	*util.Error
+}
+
+// report writes the result of a command. With -json, the result and the
+// diagnostics are written together as one object. Otherwise text is written
+// to stdout, and the diagnostics to stderr in the format of -format. It
+// returns the exit code, which is only exitErrors if a diagnostic is an error.
+func (c *cli) report(result map[string]interface{}, text string, errs util.ErrorList) int {
+	errs.Dedupe()
+	if c.json {
+		diagnostics := make([]jsonDiagnostic, len(errs))
+		for i, err := range errs {
+			file := err.Rng.Start.Filename
+			if file == "" {
+				file = c.path
+			}
+			diagnostics[i] = jsonDiagnostic{File: file, Error: err}
+		}
+		if result == nil {
+			result = map[string]interface{}{}
+		}
+		result["diagnostics"] = diagnostics
+
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			c.fail(err)
+			return exitUsage
+		}
+	} else {
+		fmt.Fprint(c.stdout, text)
+
+		// Tools reading SARIF or JSON expect a log even without diagnostics.
+		format, _ := diagnostic.LookupFormat(c.format)
+		if len(errs) > 0 || format == diagnostic.JSON || format == diagnostic.SARIF {
+			printer := &diagnostic.Printer{Format: format, Color: color(c.stderr), Files: c.files}
+			if err := printer.Print(c.stderr, errs); err != nil {
+				c.fail(err)
+				return exitUsage
+			}
+		}
+	}
+
+	if errs.HasErrors() {
+		return exitErrors
+	}
+	return exitOK
+}
+
+// color reports whether colors should be written to w: only to a terminal,
+// and not if the NO_COLOR environment variable is set.
+func color(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// fail writes an error which stops the command from running.
+func (c *cli) fail(err error) {
+	fmt.Fprintf(c.stderr, "policyscript %s: %s\n", c.name, err)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Policyscript", func() {
+	It("runs the demo with YAML inputs", func() {
+		code, stdout, stderr := policyscript("run", "-inputs", "testdata/old.yaml", "testdata/demo.law")
+		Expect(stderr).To(BeEmpty())
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout).To(Equal("can_read = true (§121(a))\n"))
+	})
+
+	It("runs the demo with JSON inputs and output", func() {
+		code, stdout, _ := policyscript("run", "-json", "-trace", "-inputs", "testdata/young.json",
+			"testdata/demo.law")
+		Expect(code).To(Equal(exitOK))
+
+		var result struct {
+			Outputs     map[string]interface{}
+			Citations   map[string]string
+			Trace       struct{ Steps []interface{} }
+			Diagnostics []interface{}
+		}
+		Expect(json.Unmarshal([]byte(stdout), &result)).To(Succeed())
+		Expect(result.Outputs).To(Equal(map[string]interface{}{"can_read": false}))
+		Expect(result.Citations).To(Equal(map[string]string{"can_read": "121(a)(1)"}))
+		Expect(result.Trace.Steps).To(HaveLen(2))
+		Expect(result.Diagnostics).To(BeEmpty())
+	})
+
+	It("checks and runs several files as one", func() {
+		code, _, stderr := policyscript("check", "testdata/read.law")
+		Expect(code).To(Equal(exitErrors))
+		Expect(stderr).To(ContainSubstring("testdata/read.law:2:11"))
+
+		code, stdout, stderr := policyscript("check", "testdata/person.law", "testdata/read.law")
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout + stderr).To(BeEmpty())
+
+		code, stdout, stderr = policyscript("run", "-trace", "-inputs", "testdata/old.yaml",
+			"testdata/read.law", "testdata/person.law")
+		Expect(stderr).To(BeEmpty())
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout).To(ContainSubstring("can_read = true\n"))
+		Expect(stdout).To(ContainSubstring(`Checked whether "person.age = Age.old", which was true.`))
+	})
+
+	It("reports bad inputs", func() {
+		code, _, stderr := policyscript("run", "-format", "plain", "testdata/demo.law")
+		Expect(code).To(Equal(exitErrors))
+		Expect(stderr).To(Equal("testdata/demo.law:17:3: error[PS5001]: missing input person\n"))
+	})
+
+	It("reports type errors", func() {
+		code, stdout, stderr := policyscript("check", "testdata/bad.law")
+		Expect(code).To(Equal(exitErrors))
+		Expect(stdout).To(BeEmpty())
+		Expect(stderr).To(Equal("error[PS4012]: can not set b of type text to integer\n" +
+			"  --> testdata/bad.law:10:12\n" +
+			"   |\n" +
+			"10 |   set b to a + 1\n" +
+			"   |            ^^^^^\n" +
+			"note: declared here\n" +
+			"  --> testdata/bad.law:6:3\n" +
+			"   |\n" +
+			" 6 |   b: text\n" +
+			"   |   -------\n" +
+			"\n" +
+			"found 1 error\n"))
+
+		code, stdout, _ = policyscript("check", "-json", "testdata/bad.law")
+		Expect(code).To(Equal(exitErrors))
+		Expect(stdout).To(ContainSubstring(`"message": "can not set b of type text to integer"`))
+		Expect(stdout).To(ContainSubstring(`"severity": "error"`))
+		Expect(stdout).To(ContainSubstring(`"code": "PS4012"`))
+
+		code, _, stderr = policyscript("check", "-format", "sarif", "testdata/bad.law")
+		Expect(code).To(Equal(exitErrors))
+		var log struct {
+			Runs []struct {
+				Results []struct{ RuleID string }
+			}
+		}
+		Expect(json.Unmarshal([]byte(stderr), &log)).To(Succeed())
+		Expect(log.Runs[0].Results).To(Equal([]struct{ RuleID string }{{"PS4012"}}))
+
+		code, _, stderr = policyscript("check", "-format", "json", "testdata/demo.law")
+		Expect(code).To(Equal(exitOK))
+		Expect(stderr).To(Equal("[]\n"))
+	})
+
+	It("runs despite warnings", func() {
+		code, stdout, stderr := policyscript("run", "-format", "plain", "-inputs", "testdata/old.yaml",
+			"testdata/unset.law")
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout).To(Equal("can_read = true\n"))
+		Expect(stderr).To(Equal("testdata/unset.law:12:3: warning[PS4101]: output note is never set\n"))
+	})
+
+	It("checks, scans, parses and renders", func() {
+		code, stdout, _ := policyscript("check", "testdata/demo.law")
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout).To(BeEmpty())
+
+		code, stdout, _ = policyscript("scan", "testdata/bad.law")
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout).To(HavePrefix("@inputs: @inputs | \"testdata/bad.law:1:0-1:7\"\n"))
+
+		code, stdout, _ = policyscript("parse", "testdata/bad.law")
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout).To(ContainSubstring("\n        Value: InfixExpression testdata/bad.law:10:11-10:16 operator=\"+\"\n"))
+
+		code, stdout, _ = policyscript("parse", "-json", "testdata/bad.law")
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout).To(ContainSubstring(`"version": 2`))
+		Expect(stdout).To(ContainSubstring(`"kind": "InfixExpression"`))
+
+		code, stdout, _ = policyscript("render", "testdata/demo.law")
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout).To(HavePrefix("An Age is young or old.\n"))
+	})
+
+	It("formats files", func() {
+		code, stdout, _ := policyscript("fmt", "testdata/demo.law")
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout).To(HavePrefix("@meta {\n  set path to `121`\n"))
+
+		code, stdout, _ = policyscript("fmt", "-check", "testdata/bad.law", "testdata/demo.law")
+		Expect(code).To(Equal(exitErrors))
+		Expect(stdout).To(Equal("testdata/demo.law\n"))
+
+		code, stdout, _ = policyscript("fmt", "-check", "testdata/bad.law")
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout).To(BeEmpty())
+
+		dir, err := ioutil.TempDir("", "policyscript")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "a.law")
+		Expect(ioutil.WriteFile(path, []byte("@inputs {\n  a :integer\n}"), 0644)).To(Succeed())
+		code, _, _ = policyscript("fmt", "-w", path)
+		Expect(code).To(Equal(exitOK))
+		Expect(ioutil.ReadFile(path)).To(Equal([]byte("@inputs {\n  a: integer\n}\n")))
+
+		Expect(ioutil.WriteFile(path, []byte("@inputs {\n  a :integer\n"), 0644)).To(Succeed())
+		code, _, stderr := policyscript("fmt", "-w", "-format", "plain", path)
+		Expect(code).To(Equal(exitErrors))
+		Expect(stderr).To(ContainSubstring("error[PS1001]"))
+		Expect(ioutil.ReadFile(path)).To(Equal([]byte("@inputs {\n  a :integer\n")))
+	})
+
+	It("generates Go code", func() {
+		code, stdout, stderr := policyscript("gen", "go", "-package", "demo", "testdata/demo.law")
+		Expect(stderr).To(BeEmpty())
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout).To(HavePrefix("// Code generated by \"policyscript gen go\"; DO NOT EDIT.\n\npackage demo\n"))
+		Expect(stdout).To(ContainSubstring("\tCanRead bool `law:\"can_read\"`\n"))
+
+		code, stdout, _ = policyscript("gen", "ts", "testdata/demo.law")
+		Expect(code).To(Equal(exitOK))
+		Expect(stdout).To(ContainSubstring("export type Age = typeof AgeMembers[number];\n"))
+
+		code, stdout, _ = policyscript("gen", "openapi", "testdata/demo.law")
+		Expect(code).To(Equal(exitOK))
+		var document struct{ Info struct{ Title string } }
+		Expect(json.Unmarshal([]byte(stdout), &document)).To(Succeed())
+		Expect(document.Info.Title).To(Equal("demo"))
+
+		code, _, stderr = policyscript("gen", "go", "-format", "plain", "testdata/bad.law")
+		Expect(code).To(Equal(exitErrors))
+		Expect(stderr).To(ContainSubstring("error[PS4012]"))
+	})
+
+	It("fails on bad usage", func() {
+		code, _, stderr := policyscript("frobnicate")
+		Expect(code).To(Equal(exitUsage))
+		Expect(stderr).To(HavePrefix("policyscript: unknown command \"frobnicate\""))
+
+		code, _, stderr = policyscript("run", "-inputs", "testdata/missing.json", "testdata/demo.law")
+		Expect(code).To(Equal(exitUsage))
+		Expect(stderr).To(ContainSubstring("no such file or directory"))
+
+		code, _, _ = policyscript("check")
+		Expect(code).To(Equal(exitUsage))
+
+		code, _, _ = policyscript("render", "testdata/bad.law", "testdata/demo.law")
+		Expect(code).To(Equal(exitUsage))
+
+		code, _, _ = policyscript("fmt")
+		Expect(code).To(Equal(exitUsage))
+
+		code, _, stderr = policyscript("fmt", "-w", "testdata/demo.law", "testdata/demo.law")
+		Expect(code).To(Equal(exitUsage))
+		Expect(stderr).To(Equal("policyscript fmt: testdata/demo.law is named more than once\n"))
+
+		code, _, stderr = policyscript("gen", "cobol", "testdata/demo.law")
+		Expect(code).To(Equal(exitUsage))
+		Expect(stderr).To(HavePrefix("Usage: policyscript gen <go|ts|schema|openapi>"))
+
+		code, _, stderr = policyscript("check", "-format", "xml", "testdata/demo.law")
+		Expect(code).To(Equal(exitUsage))
+		Expect(stderr).To(Equal("policyscript check: unknown format \"xml\"\n"))
+	})
+})
+
+func policyscript(args ...string) (code int, stdout, stderr string) {
+	var out, err bytes.Buffer
+	code = run(args, &out, &err)
+	return code, out.String(), err.String()
+}
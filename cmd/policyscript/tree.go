@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/util"
+)
+
+// node is an ast node as printed by the parse command without -json.
+type node struct {
+	Node     string
+	Range    util.Range
+	Fields   map[string]interface{} // values other than nodes, ex: Value
+	Children []*child               // nodes, in the order of their fields
+}
+
+type child struct {
+	Field string
+	Node  *node
+}
+
+var (
+	nodeType  = reflect.TypeOf((*ast.Node)(nil)).Elem()
+	tokenType = reflect.TypeOf(token.Token{})
+)
+
+// tree builds the node of an ast node and its children.
+func tree(n ast.Node) *node {
+	v := reflect.ValueOf(n).Elem()
+	t := v.Type()
+	result := &node{Node: t.Name(), Range: *n.Range(), Fields: map[string]interface{}{}}
+	if block, ok := n.(*ast.BlockStatement); ok {
+		result.Fields["keyword"] = block.Token.Literal
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		switch {
+		case field.PkgPath != "" || field.Type == tokenType:
+			// Tokens are covered by the range of the node.
+		case field.Type.Implements(nodeType):
+			if !value.IsNil() {
+				result.Children = append(result.Children,
+					&child{Field: field.Name, Node: tree(value.Interface().(ast.Node))})
+			}
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Implements(nodeType):
+			for j := 0; j < value.Len(); j++ {
+				result.Children = append(result.Children,
+					&child{Field: field.Name, Node: tree(value.Index(j).Interface().(ast.Node))})
+			}
+		case field.Type.Kind() == reflect.String && value.Len() == 0:
+			// Unset text, ex: the label of a heading which is not numbered.
+		default:
+			result.Fields[strings.ToLower(field.Name)] = value.Interface()
+		}
+	}
+	return result
+}
+
+// String returns the tree with one node per line, and children indented below
+// their parent.
+func (n *node) String() string {
+	var b strings.Builder
+	n.write(&b, "", 0)
+	return b.String()
+}
+
+func (n *node) write(b *strings.Builder, field string, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	if field != "" {
+		b.WriteString(field + ": ")
+	}
+	fmt.Fprintf(b, "%s %s", n.Node, n.Range)
+
+	names := make([]string, 0, len(n.Fields))
+	for name := range n.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(b, " %s=%#v", name, n.Fields[name])
+	}
+	b.WriteString("\n")
+
+	for _, c := range n.Children {
+		c.Node.write(b, c.Field, depth+1)
+	}
+}
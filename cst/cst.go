@@ -0,0 +1,197 @@
+// Package cst builds a lossless concrete syntax tree, which keeps every byte
+// of the source: whitespace, line breaks, comments and the positions of
+// automatic semicolons are kept as trivia attached to the tokens around them.
+// Writing the tree back out gives the source it was built from, so tools can
+// change one token and keep the rest of a file exactly as it was.
+package cst
+
+import (
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/parser"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Element is a *Node or a *Token.
+type Element interface {
+	element()
+	writeTo(b *strings.Builder)
+}
+
+// Node is a node of the tree, which mirrors a node of the ast.
+type Node struct {
+
+	// Kind is the type of the ast node, ex: "SetExpression".
+	Kind string
+
+	// AST is the ast node.
+	AST ast.Node
+
+	// Children are the tokens and nodes within the node, in source order.
+	// Tokens which the ast drops, such as parentheses, are children of the
+	// innermost node around them.
+	Children []Element
+}
+
+// Token is a token with the text it was scanned from and the trivia around
+// it.
+type Token struct {
+	token.Token
+
+	// Text is the token as written in the source, ex: "`Canada`" where the
+	// literal is "Canada".
+	Text string
+
+	// Leading is the trivia before the token which is not on the line of the
+	// previous token.
+	Leading []Trivia
+
+	// Trailing is the trivia after the token on its line, up to and including
+	// the line break.
+	Trailing []Trivia
+}
+
+// TriviaKind is the kind of a piece of trivia.
+type TriviaKind string
+
+const (
+	// Whitespace is a run of spaces, tabs and carriage returns.
+	Whitespace TriviaKind = "whitespace"
+
+	// LineBreak is "\n" or "\r\n".
+	LineBreak TriviaKind = "line break"
+
+	// Comment is a line from "#" up to the line break.
+	Comment TriviaKind = "comment"
+
+	// Semicolon is where the scanner ended a statement at the end of a line.
+	// It has no text.
+	Semicolon TriviaKind = "semicolon"
+)
+
+// Trivia is source text between tokens, which does not change the meaning of
+// a program.
+type Trivia struct {
+	Kind  TriviaKind `json:"kind"`
+	Text  string     `json:"text"`
+	Range util.Range `json:"range"`
+}
+
+func (n *Node) element()  {}
+func (t *Token) element() {}
+
+// Parse builds the tree of a file. The tree is built even if the file has
+// errors, which are returned with it.
+func Parse(file *util.File) (*Node, util.ErrorList) {
+	var errs util.ErrorList
+	s := scanner.New(file, func(err *util.Error) {
+		errs = append(errs, err)
+	})
+	p := parser.New(*s)
+	program := p.ParseProgram()
+	errs = append(errs, p.Errors()...)
+
+	return Build(file, program), errs
+}
+
+// Build builds the tree of a program parsed from a file.
+func Build(file *util.File, program *ast.Program) *Node {
+	b := &builder{tokens: tokens(file)}
+	return b.node(program, -1)
+}
+
+// Tokens returns the tokens of the node, in source order.
+func (n *Node) Tokens() []*Token {
+	var tokens []*Token
+	for _, child := range n.Children {
+		switch child := child.(type) {
+		case *Token:
+			tokens = append(tokens, child)
+		case *Node:
+			tokens = append(tokens, child.Tokens()...)
+		}
+	}
+	return tokens
+}
+
+// Range returns the range from the first token of the node to the end of its
+// last token, without their trivia.
+func (n *Node) Range() *util.Range {
+	tokens := n.Tokens()
+	if len(tokens) == 0 {
+		return &util.Range{}
+	}
+	return &util.Range{Start: tokens[0].Range.Start, End: tokens[len(tokens)-1].Range.End}
+}
+
+// String returns the source of the node, including the trivia of its tokens.
+// The string of the root is the source of the file.
+func (n *Node) String() string {
+	var b strings.Builder
+	n.writeTo(&b)
+	return b.String()
+}
+
+// WriteTo writes the source of the node.
+func (n *Node) WriteTo(w io.Writer) (int64, error) {
+	written, err := io.WriteString(w, n.String())
+	return int64(written), err
+}
+
+func (n *Node) writeTo(b *strings.Builder) {
+	for _, child := range n.Children {
+		child.writeTo(b)
+	}
+}
+
+// String returns the source of the token, including its trivia.
+func (t *Token) String() string {
+	var b strings.Builder
+	t.writeTo(&b)
+	return b.String()
+}
+
+func (t *Token) writeTo(b *strings.Builder) {
+	for _, trivia := range t.Leading {
+		b.WriteString(trivia.Text)
+	}
+	b.WriteString(t.Text)
+	for _, trivia := range t.Trailing {
+		b.WriteString(trivia.Text)
+	}
+}
+
+type builder struct {
+	tokens []*Token
+	i      int
+}
+
+// node builds the node of an ast node from the tokens which end by the rune
+// offset end, or from every token left if end is negative.
+func (b *builder) node(n ast.Node, end int) *Node {
+	node := &Node{Kind: reflect.TypeOf(n).Elem().Name(), AST: n}
+
+	for _, child := range ast.Children(n) {
+		if _, ok := child.(*ast.CommentStatement); ok {
+			// Comments are trivia of the tokens around them.
+			continue
+		}
+		rng := child.Range()
+		for b.i < len(b.tokens) && b.tokens[b.i].Range.Start.Offset < rng.Start.Offset {
+			node.Children = append(node.Children, b.tokens[b.i])
+			b.i++
+		}
+		node.Children = append(node.Children, b.node(child, rng.End.Offset))
+	}
+
+	for b.i < len(b.tokens) && (end < 0 || b.tokens[b.i].Range.End.Offset <= end) {
+		node.Children = append(node.Children, b.tokens[b.i])
+		b.i++
+	}
+	return node
+}
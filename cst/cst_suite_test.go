@@ -0,0 +1,13 @@
+package cst_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCst(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Cst Suite")
+}
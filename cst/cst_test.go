@@ -0,0 +1,94 @@
+package cst_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/cst"
+	"github.com/policyscript/policyscript/util"
+)
+
+var _ = Describe("CST", func() {
+	util.Each("round-trips", [][2]string{
+		{"", ""},
+		{"_ (a) Heading  \n\n\nSome text.\n  More text.   \n", ""},
+		{"# A\n  # B\n@define Person {  # C\n  age:   integer\n\n\n  kids : Person list\n}\n\n", ""},
+		{"@code {\n  set a to (b + c) * -d\n  if a >= 1:\n    set e to `x`\n  else:\n    set e to |2021/01/15|\n}", ""},
+		{"@code {\r\n  set a to 4   days\r\n}\r\n# End\r\n", ""},
+		{"@code {\n  set a to `€ 😀`\n  set b to \xff\xfe\n}\n", ""},
+		{"@code {\n  set a to\n", ""},
+		{"@meta {\n  set a to `b", ""},
+		{"\t\n  \n", ""},
+	}, func(input, _ string) {
+		root, _ := cst.Parse(util.NewFile("a.law", []byte(input)))
+		Expect(root.String()).To(Equal(input))
+	})
+
+	It("keeps trivia", func() {
+		root, errs := cst.Parse(util.NewFile("a.law", []byte("# A\n@code {  # B\n  set a to 1  \n\n  set b to 2\n}\n")))
+		Expect(errs).To(BeEmpty())
+
+		var described []string
+		for _, tok := range root.Tokens() {
+			described = append(described, fmt.Sprintf("%s %s %s", trivia(tok.Leading), tok.Text, trivia(tok.Trailing)))
+		}
+		Expect(described).To(Equal([]string{
+			`[comment "# A" line break] @code [whitespace]`,
+			`[] { [whitespace comment "# B" line break]`,
+			`[whitespace] set [whitespace]`,
+			`[] a [whitespace]`,
+			`[] to [whitespace]`,
+			`[] 1 [semicolon whitespace line break]`,
+			`[line break whitespace] set [whitespace]`,
+			`[] b [whitespace]`,
+			`[] to [whitespace]`,
+			`[] 2 [semicolon line break]`,
+			`[] } [line break]`,
+			`[]  []`,
+		}))
+
+		semicolon := root.Tokens()[5].Trailing[0]
+		Expect(semicolon.Range.Start).To(Equal(util.Position{
+			Filename: "a.law", Line: 3, Column: 12, Offset: 29, ByteOffset: 29,
+		}))
+	})
+
+	It("builds nodes from the ast", func() {
+		root, _ := cst.Parse(util.NewFile("a.law", []byte("@code {\n  set a to (b + c) * d\n}\n")))
+		Expect(describe(root)).To(Equal(
+			"Program(BlockStatement(@code { ExpressionStatement(SetExpression(set Identifier(a) to ( " +
+				"InfixExpression(InfixExpression(Identifier(b) + Identifier(c)) ) * Identifier(d)))) }) )"))
+
+		set := root.Children[0].(*cst.Node).Children[2].(*cst.Node)
+		Expect(set.Range().String()).To(Equal("a.law:2:2-2:22"))
+		Expect(set.String()).To(Equal("  set a to (b + c) * d\n"))
+	})
+})
+
+func trivia(list []cst.Trivia) string {
+	var parts []string
+	for _, t := range list {
+		if t.Kind == cst.Comment {
+			parts = append(parts, fmt.Sprintf("%s %q", t.Kind, t.Text))
+		} else {
+			parts = append(parts, string(t.Kind))
+		}
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+func describe(element cst.Element) string {
+	switch element := element.(type) {
+	case *cst.Token:
+		return element.Text
+	case *cst.Node:
+		parts := make([]string, len(element.Children))
+		for i, child := range element.Children {
+			parts[i] = describe(child)
+		}
+		return element.Kind + "(" + strings.Join(parts, " ") + ")"
+	}
+	return ""
+}
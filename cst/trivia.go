@@ -0,0 +1,118 @@
+package cst
+
+import (
+	"strings"
+
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/util"
+)
+
+// tokens scans a file into tokens with trivia. Comments and semicolons are
+// turned into trivia, and the source between the other tokens is split into
+// trivia. The last token is EOF, whose leading trivia ends the file.
+func tokens(file *util.File) []*Token {
+	var (
+		lines  = file.Lines()
+		src    = file.Source()
+		result []*Token
+
+		// Byte offset of the end of the previous token, and the semicolons
+		// since.
+		end        int
+		semicolons []int
+	)
+
+	// The scanner counts bytes of invalid UTF-8 as runes of three bytes, so
+	// byte offsets are found from rune offsets instead.
+	byteOffset := func(pos util.Position) int {
+		return lines.AtRune(pos.Offset).ByteOffset
+	}
+
+	scanned := scanner.New(file, nil).Scan()
+	for i, tok := range scanned {
+		switch tok.Type {
+		case token.SEMI:
+			semicolons = append(semicolons, byteOffset(tok.Range.Start))
+			continue
+		case token.COMMENT:
+			continue
+		case token.EOF:
+			// The scanner also ends an unclosed block with EOF.
+			if i < len(scanned)-1 {
+				continue
+			}
+		}
+
+		start, stop := byteOffset(tok.Range.Start), byteOffset(tok.Range.End)
+		trivia := lex(file, end, start, semicolons)
+
+		// Trivia on the line of the previous token trails it.
+		if len(result) > 0 {
+			prev := result[len(result)-1]
+			n := 0
+			for n < len(trivia) {
+				n++
+				if trivia[n-1].Kind == LineBreak {
+					break
+				}
+			}
+			prev.Trailing, trivia = trivia[:n], trivia[n:]
+		}
+
+		result = append(result, &Token{Token: tok, Text: string(src[start:stop]), Leading: trivia})
+		end, semicolons = stop, nil
+	}
+	return result
+}
+
+// lex splits the source between two byte offsets into trivia. The semicolons
+// are byte offsets of automatic semicolons, which have no text.
+func lex(file *util.File, start, end int, semicolons []int) []Trivia {
+	var (
+		trivia []Trivia
+		text   = string(file.Source()[start:end])
+		lines  = file.Lines()
+	)
+
+	add := func(kind TriviaKind, from, to int) {
+		startPos, endPos := lines.AtByte(start+from), lines.AtByte(start+to)
+		startPos.Filename, endPos.Filename = file.Name(), file.Name()
+		trivia = append(trivia, Trivia{Kind: kind, Text: text[from:to], Range: util.Range{Start: startPos, End: endPos}})
+	}
+
+	for i := 0; i <= len(text); {
+		for len(semicolons) > 0 && semicolons[0] <= start+i {
+			add(Semicolon, i, i)
+			semicolons = semicolons[1:]
+		}
+		if i == len(text) {
+			break
+		}
+
+		var j int
+		switch {
+		case text[i] == '\n':
+			add(LineBreak, i, i+1)
+			i++
+			continue
+		case strings.HasPrefix(text[i:], "\r\n"):
+			add(LineBreak, i, i+2)
+			i += 2
+			continue
+		case text[i] == '#':
+			j = i + strings.IndexByte(text[i:]+"\n", '\n')
+			if j > i && text[j-1] == '\r' {
+				j--
+			}
+			add(Comment, i, j)
+		default:
+			for j = i; j < len(text) && text[j] != '\n' && text[j] != '#' &&
+				!strings.HasPrefix(text[j:], "\r\n"); j++ {
+			}
+			add(Whitespace, i, j)
+		}
+		i = j
+	}
+	return trivia
+}
@@ -0,0 +1,126 @@
+// Package diagnostic writes diagnostics for people and for tools: with source
+// snippets for a terminal, one per line for CI logs, or as JSON or SARIF for
+// code scanning.
+package diagnostic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/policyscript/policyscript/util"
+)
+
+// Format is a way of writing diagnostics.
+type Format string
+
+const (
+	// Pretty shows each diagnostic with the source lines it is about, and
+	// underlines its range.
+	Pretty Format = "pretty"
+
+	// Plain writes a diagnostic per line, as "file:line:column: error[code]:
+	// message", which CI problem matchers understand.
+	Plain Format = "plain"
+
+	// JSON writes the diagnostics as a JSON array.
+	JSON Format = "json"
+
+	// SARIF writes the diagnostics as a SARIF 2.1.0 log, for code scanning.
+	SARIF Format = "sarif"
+)
+
+// Formats are the formats, in the order they are documented.
+var Formats = []Format{Pretty, Plain, JSON, SARIF}
+
+// LookupFormat returns the format with a name, or false if there is none.
+func LookupFormat(name string) (Format, bool) {
+	for _, format := range Formats {
+		if string(format) == name {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// Printer writes diagnostics in a format.
+type Printer struct {
+	Format Format
+
+	// Color is whether pretty diagnostics are written with ANSI colors.
+	Color bool
+
+	// Files are the sources of the diagnostics, by filename. Snippets are left
+	// out for files which are not in the set.
+	Files *util.FileSet
+}
+
+// Print writes a list of diagnostics.
+func (p *Printer) Print(w io.Writer, errs util.ErrorList) error {
+	switch p.Format {
+	case Pretty, "":
+		return p.pretty(w, errs)
+	case Plain:
+		return plain(w, errs)
+	case JSON:
+		if errs == nil {
+			errs = util.ErrorList{}
+		}
+		return writeJSON(w, errs)
+	case SARIF:
+		return writeJSON(w, sarif(errs))
+	}
+	return fmt.Errorf("unknown format %q", p.Format)
+}
+
+// plain writes a diagnostic per line, with 1-indexed columns.
+func plain(w io.Writer, errs util.ErrorList) error {
+	for _, err := range errs {
+		pos := err.Rng.Start
+		var b strings.Builder
+		if pos.Filename != "" {
+			b.WriteString(pos.Filename + ":")
+		}
+		fmt.Fprintf(&b, "%d:%d: %s: %s\n", pos.Line, pos.Column+1, title(err), err.Msg)
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// title returns the severity and code of a diagnostic, ex: "error[PS4006]".
+func title(err *util.Error) string {
+	if err.Code == "" {
+		return err.Severity.String()
+	}
+	return fmt.Sprintf("%s[%s]", err.Severity, err.Code)
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// Summary returns the number of errors and warnings, ex: "2 errors and 1
+// warning", or an empty string if there are neither.
+func Summary(errs util.ErrorList) string {
+	counts := map[util.Severity]int{}
+	for _, err := range errs {
+		counts[err.Severity]++
+	}
+
+	var parts []string
+	for _, severity := range []util.Severity{util.SeverityError, util.SeverityWarning} {
+		switch n := counts[severity]; n {
+		case 0:
+		case 1:
+			parts = append(parts, fmt.Sprintf("1 %s", severity))
+		default:
+			parts = append(parts, fmt.Sprintf("%d %ss", n, severity))
+		}
+	}
+	return strings.Join(parts, " and ")
+}
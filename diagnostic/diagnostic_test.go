@@ -0,0 +1,174 @@
+package diagnostic_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/diagnostic"
+	"github.com/policyscript/policyscript/util"
+)
+
+const source = "@outputs {\n\tb: text\n}\n\n@code {\n  set b to a + 1\n}\n"
+
+var _ = Describe("Printer", func() {
+	var (
+		files *util.FileSet
+		errs  util.ErrorList
+	)
+
+	// span returns the range of a 1-indexed line from a column to another.
+	span := func(line, from, to int) *util.Range {
+		return &util.Range{
+			Start: util.Position{Filename: "a.law", Line: line, Column: from},
+			End:   util.Position{Filename: "a.law", Line: line, Column: to},
+		}
+	}
+
+	print := func(printer *diagnostic.Printer) string {
+		var b bytes.Buffer
+		Expect(printer.Print(&b, errs)).To(Succeed())
+		return b.String()
+	}
+
+	BeforeEach(func() {
+		files = util.NewFileSet()
+		files.AddFile("a.law", []byte(source))
+
+		errs = nil
+		errs.Add("a is not declared", span(6, 11, 12)).WithCode(util.CodeUndeclared).
+			WithRelated("b is declared here", span(2, 1, 8)).
+			WithFix("Change to b", util.Replace(span(6, 11, 12), "b"))
+		errs.Add("output c is never set", span(9, 0, 1)).WithSeverity(util.SeverityWarning)
+	})
+
+	It("writes source snippets", func() {
+		Expect(print(&diagnostic.Printer{Format: diagnostic.Pretty, Files: files})).To(Equal(
+			"error[PS4013]: a is not declared\n" +
+				" --> a.law:6:12\n" +
+				"  |\n" +
+				"6 |   set b to a + 1\n" +
+				"  |            ^\n" +
+				"note: b is declared here\n" +
+				" --> a.law:2:2\n" +
+				"  |\n" +
+				"2 |     b: text\n" +
+				"  |     -------\n" +
+				"  = help: Change to b\n" +
+				"\n" +
+				"warning: output c is never set\n" +
+				" --> a.law:9:1\n" +
+				"\n" +
+				"found 1 error and 1 warning\n"))
+	})
+
+	It("colors snippets", func() {
+		out := print(&diagnostic.Printer{Format: diagnostic.Pretty, Files: files, Color: true})
+		Expect(out).To(HavePrefix("\x1b[1m\x1b[31merror[PS4013]\x1b[0m\x1b[1m: a is not declared\x1b[0m\n"))
+		Expect(out).To(ContainSubstring("\x1b[1m\x1b[33mwarning\x1b[0m"))
+	})
+
+	It("shortens long ranges", func() {
+		long := util.NewFileSet()
+		long.AddFile("a.law", []byte("1\n2\n3\n4\n5\n6\n"))
+		errs = nil
+		errs.Add("too long", &util.Range{
+			Start: util.Position{Filename: "a.law", Line: 1, Column: 0},
+			End:   util.Position{Filename: "a.law", Line: 6, Column: 1},
+		})
+		Expect(print(&diagnostic.Printer{Files: long})).To(Equal(
+			"error: too long\n" +
+				" --> a.law:1:1\n" +
+				"  |\n" +
+				"1 | 1\n" +
+				"  | ^\n" +
+				"2 | 2\n" +
+				"  | ^\n" +
+				"3 | 3\n" +
+				"  | ^\n" +
+				"...\n" +
+				"6 | 6\n" +
+				"  | ^\n" +
+				"\n" +
+				"found 1 error\n"))
+	})
+
+	It("writes a diagnostic per line", func() {
+		Expect(print(&diagnostic.Printer{Format: diagnostic.Plain})).To(Equal(
+			"a.law:6:12: error[PS4013]: a is not declared\n" +
+				"a.law:9:1: warning: output c is never set\n"))
+	})
+
+	It("writes JSON", func() {
+		var decoded util.ErrorList
+		Expect(json.Unmarshal([]byte(print(&diagnostic.Printer{Format: diagnostic.JSON})), &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(errs))
+
+		errs = nil
+		Expect(print(&diagnostic.Printer{Format: diagnostic.JSON})).To(Equal("[]\n"))
+	})
+
+	It("writes SARIF", func() {
+		var log struct {
+			Version string
+			Runs    []struct {
+				Tool struct {
+					Driver struct{ Rules []struct{ ID string } }
+				}
+				Results []struct {
+					RuleID    string
+					Level     string
+					Locations []struct {
+						PhysicalLocation struct {
+							ArtifactLocation struct{ URI string }
+							Region           struct{ StartLine, StartColumn, EndLine, EndColumn int }
+						}
+					}
+					RelatedLocations []struct{ ID int }
+					Fixes            []struct {
+						ArtifactChanges []struct {
+							Replacements []struct{ InsertedContent struct{ Text string } }
+						}
+					}
+				}
+			}
+		}
+		Expect(json.Unmarshal([]byte(print(&diagnostic.Printer{Format: diagnostic.SARIF})), &log)).To(Succeed())
+		Expect(log.Version).To(Equal("2.1.0"))
+
+		run := log.Runs[0]
+		Expect(run.Tool.Driver.Rules).To(HaveLen(1))
+		Expect(run.Tool.Driver.Rules[0].ID).To(Equal("PS4013"))
+		Expect(run.Results).To(HaveLen(2))
+
+		result := run.Results[0]
+		Expect(result.Level).To(Equal("error"))
+		Expect(result.Locations[0].PhysicalLocation.ArtifactLocation.URI).To(Equal("a.law"))
+		Expect(result.Locations[0].PhysicalLocation.Region).To(Equal(
+			struct{ StartLine, StartColumn, EndLine, EndColumn int }{6, 12, 6, 13}))
+		Expect(result.RelatedLocations[0].ID).To(Equal(1))
+		Expect(result.Fixes[0].ArtifactChanges[0].Replacements[0].InsertedContent.Text).To(Equal("b"))
+		Expect(run.Results[1].Level).To(Equal("warning"))
+		Expect(run.Results[1].RuleID).To(BeEmpty())
+	})
+
+	util.Each("summarizes", [][2]string{
+		{"", ""},
+		{"e", "1 error"},
+		{"eew", "2 errors and 1 warning"},
+		{"wwh", "2 warnings"},
+	}, func(input, expects string) {
+		errs = nil
+		for _, ch := range input {
+			err := errs.Add("", span(1, 0, 0))
+			switch ch {
+			case 'w':
+				err.WithSeverity(util.SeverityWarning)
+			case 'h':
+				err.WithSeverity(util.SeverityHint)
+			}
+		}
+		Expect(diagnostic.Summary(errs)).To(Equal(expects))
+	})
+})
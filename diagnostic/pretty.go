@@ -0,0 +1,172 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/policyscript/policyscript/util"
+)
+
+// ANSI escape codes.
+const (
+	reset  = "\x1b[0m"
+	bold   = "\x1b[1m"
+	red    = "\x1b[31m"
+	green  = "\x1b[32m"
+	yellow = "\x1b[33m"
+	blue   = "\x1b[34m"
+	cyan   = "\x1b[36m"
+)
+
+var severityColors = map[util.Severity]string{
+	util.SeverityError:   red,
+	util.SeverityWarning: yellow,
+	util.SeverityInfo:    blue,
+	util.SeverityHint:    cyan,
+}
+
+// Source lines of a range shown before the rest are left out.
+const maxLines = 4
+
+// Tabs are shown as this many spaces, so that underlines line up.
+const tabWidth = 4
+
+// pretty writes each diagnostic with the source lines of its range, ex:
+//
+//	error[PS4012]: can not set b of type text to integer
+//	  --> bad.law:10:12
+//	   |
+//	10 |   set b to a + 1
+//	   |            ^^^^^
+//
+// Related ranges are written the same way as notes, and fixes as help.
+func (p *Printer) pretty(w io.Writer, errs util.ErrorList) error {
+	var b strings.Builder
+	for i, err := range errs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		p.diagnostic(&b, err)
+	}
+	if summary := Summary(errs); summary != "" {
+		b.WriteString("\n" + p.paint(bold, "found "+summary) + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (p *Printer) diagnostic(b *strings.Builder, err *util.Error) {
+	// The gutter is as wide as the largest line number shown.
+	width := len(strconv.Itoa(err.Rng.End.Line))
+	for _, related := range err.Related {
+		if n := len(strconv.Itoa(related.Rng.End.Line)); n > width {
+			width = n
+		}
+	}
+	gutter := strings.Repeat(" ", width)
+
+	color := severityColors[err.Severity]
+	fmt.Fprintf(b, "%s%s\n", p.paint(bold+color, title(err)), p.paint(bold, ": "+err.Msg))
+	p.snippet(b, gutter, &err.Rng, '^', color)
+
+	for _, related := range err.Related {
+		fmt.Fprintf(b, "%s %s\n", p.paint(bold+green, "note:"), related.Msg)
+		p.snippet(b, gutter, &related.Rng, '-', green)
+	}
+	for _, fix := range err.Fixes {
+		fmt.Fprintf(b, "%s %s %s\n", gutter, p.paint(bold+blue, "= help:"), fix.Title)
+	}
+}
+
+// snippet writes the location of a range, and its source lines underlined
+// with mark if the source is known.
+func (p *Printer) snippet(b *strings.Builder, gutter string, rng *util.Range, mark rune, color string) {
+	start, end := rng.Start, rng.End
+	location := fmt.Sprintf("%d:%d", start.Line, start.Column+1)
+	if start.Filename != "" {
+		location = start.Filename + ":" + location
+	}
+	fmt.Fprintf(b, "%s%s %s\n", gutter, p.paint(bold+blue, "-->"), location)
+
+	lines := p.lines(start.Filename)
+	if lines == nil || start.Line < 1 || start.Line > lines.Lines() {
+		return
+	}
+	if end.Line < start.Line || end.Line > lines.Lines() {
+		end = start
+	}
+
+	bar := p.paint(bold+blue, "|")
+	fmt.Fprintf(b, "%s %s\n", gutter, bar)
+	for line := start.Line; line <= end.Line; line++ {
+		if end.Line-start.Line >= maxLines && line == start.Line+maxLines-1 {
+			// Leave out the middle of a long range, but show its end.
+			fmt.Fprintf(b, "%s\n", p.paint(bold+blue, "..."))
+			line = end.Line
+		}
+
+		text := lines.Line(line)
+		from, to := 0, len([]rune(text))
+		if line == start.Line {
+			from = start.Column
+		}
+		if line == end.Line {
+			to = end.Column
+		}
+
+		number := fmt.Sprintf("%*d", len(gutter), line)
+		fmt.Fprintf(b, "%s %s %s\n", p.paint(bold+blue, number), bar, expandTabs(text))
+
+		col, n := displayColumn(text, from), displayColumn(text, to)-displayColumn(text, from)
+		if n < 1 {
+			n = 1
+		}
+		underline := strings.Repeat(" ", col) + p.paint(bold+color, strings.Repeat(string(mark), n))
+		fmt.Fprintf(b, "%s %s %s\n", gutter, bar, underline)
+	}
+}
+
+// lines returns the line index of a file, or nil if its source is not known.
+func (p *Printer) lines(filename string) *util.LineIndex {
+	if p.Files == nil {
+		return nil
+	}
+	if f := p.Files.Lookup(filename); f != nil {
+		return f.Lines()
+	}
+	return nil
+}
+
+// paint colors text, if colors are on.
+func (p *Printer) paint(color, text string) string {
+	if !p.Color {
+		return text
+	}
+	return color + text + reset
+}
+
+func expandTabs(text string) string {
+	return strings.ReplaceAll(text, "\t", strings.Repeat(" ", tabWidth))
+}
+
+// displayColumn returns where a rune column of a line is shown, once tabs are
+// expanded.
+func displayColumn(text string, column int) int {
+	display := 0
+	for i, ch := range []rune(text) {
+		if i >= column {
+			break
+		}
+		if ch == '\t' {
+			display += tabWidth
+		} else {
+			display++
+		}
+	}
+	if n := len([]rune(text)); column > n {
+		display += column - n
+	}
+	return display
+}
@@ -0,0 +1,187 @@
+package diagnostic
+
+import (
+	"sort"
+
+	"github.com/policyscript/policyscript/util"
+)
+
+// The subset of SARIF 2.1.0 used for diagnostics. See
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool sarifTool `json:"tool"`
+
+	// Columns are counted in runes, as they are by the scanner, rather than
+	// the default of UTF-16 code units.
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId,omitempty"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifRegion is a range with 1-indexed lines and columns, where the end
+// column is exclusive.
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+// SARIF levels of each severity. Info and hint are both notes.
+var sarifLevels = map[util.Severity]string{
+	util.SeverityError:   "error",
+	util.SeverityWarning: "warning",
+	util.SeverityInfo:    "note",
+	util.SeverityHint:    "note",
+}
+
+// sarif returns the SARIF log of a list of diagnostics. Each code used is a
+// rule of the tool.
+func sarif(errs util.ErrorList) *sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "policyscript",
+			InformationURI: "https://github.com/policyscript/policyscript",
+			Rules:          []sarifRule{},
+		}},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+
+	codes := map[util.Code]bool{}
+	for _, err := range errs {
+		result := sarifResult{
+			RuleID:    string(err.Code),
+			Level:     sarifLevels[err.Severity],
+			Message:   sarifMessage{Text: err.Msg},
+			Locations: []sarifLocation{{PhysicalLocation: physicalLocation(&err.Rng)}},
+		}
+		for i, related := range err.Related {
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+				ID:               i + 1,
+				PhysicalLocation: physicalLocation(&related.Rng),
+				Message:          &sarifMessage{Text: related.Msg},
+			})
+		}
+		for _, fix := range err.Fixes {
+			result.Fixes = append(result.Fixes, sarifFixOf(fix))
+		}
+		run.Results = append(run.Results, result)
+
+		if err.Code != "" && !codes[err.Code] {
+			codes[err.Code] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: string(err.Code)})
+		}
+	}
+	sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool {
+		return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID
+	})
+
+	return &sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}}
+}
+
+// sarifFixOf groups the edits of a fix by file.
+func sarifFixOf(fix util.Fix) sarifFix {
+	var (
+		changes []sarifArtifactChange
+		files   = map[string]int{}
+	)
+	for _, edit := range fix.Edits {
+		file := edit.Rng.Start.Filename
+		i, ok := files[file]
+		if !ok {
+			i = len(changes)
+			files[file] = i
+			changes = append(changes, sarifArtifactChange{ArtifactLocation: sarifArtifactLocation{URI: file}})
+		}
+		changes[i].Replacements = append(changes[i].Replacements, sarifReplacement{
+			DeletedRegion:   region(&edit.Rng),
+			InsertedContent: sarifMessage{Text: edit.NewText},
+		})
+	}
+	return sarifFix{Description: sarifMessage{Text: fix.Title}, ArtifactChanges: changes}
+}
+
+func physicalLocation(rng *util.Range) sarifPhysicalLocation {
+	return sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: rng.Start.Filename},
+		Region:           region(rng),
+	}
+}
+
+func region(rng *util.Range) sarifRegion {
+	return sarifRegion{
+		StartLine:   rng.Start.Line,
+		StartColumn: rng.Start.Column + 1,
+		EndLine:     rng.End.Line,
+		EndColumn:   rng.End.Column + 1,
+	}
+}
@@ -0,0 +1,664 @@
+// Package evaluator runs the @code blocks of a program.
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/citation"
+	"github.com/policyscript/policyscript/object"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Result is the outcome of evaluating a program.
+type Result struct {
+
+	// Outputs are the values of every @outputs field which was set.
+	Outputs map[string]object.Object
+
+	// Citations are the citation paths of the set which last changed each
+	// output, ex: "121(b)(1)". Outputs set outside of a numbered heading are
+	// not included.
+	Citations map[string]string
+
+	// Values are the last values of the expression statements which are not
+	// sets, which a program otherwise discards, ex: those typed into a REPL.
+	Values map[ast.Expr]object.Object
+}
+
+// The kind of a declared name.
+type declaration int
+
+const (
+	input declaration = iota + 1
+	output
+	local
+)
+
+type evaluator struct {
+	ctx context.Context
+
+	declared map[string]declaration
+	values   map[string]object.Object
+	enums    map[string]map[string]bool
+
+	// Loop variables, innermost last.
+	scopes []map[string]object.Object
+
+	result *Result
+
+	// Citation of the block being evaluated.
+	citation string
+
+	// Ranges of the sets which last changed each output.
+	sets map[string]util.Range
+
+	trace *Trace
+	step  *Step
+}
+
+// fail stops evaluation with an error at rng.
+type fail struct {
+	err *util.Error
+}
+
+// Evaluate runs every @code block of the program in order, starting from the
+// given inputs. If trace is not nil, every condition and set is recorded to it.
+func Evaluate(program *ast.Program, inputs map[string]object.Object, trace *Trace) (
+	*Result, util.ErrorList) {
+	return EvaluateContext(context.Background(), program, inputs, trace)
+}
+
+// EvaluateContext is like Evaluate, but stops before the next statement once
+// ctx is done, with an error whose code is util.CodeCanceled.
+func EvaluateContext(ctx context.Context, program *ast.Program, inputs map[string]object.Object,
+	trace *Trace) (result *Result, errs util.ErrorList) {
+	e := &evaluator{
+		ctx:      ctx,
+		declared: map[string]declaration{},
+		values:   map[string]object.Object{},
+		enums:    map[string]map[string]bool{},
+		sets:     map[string]util.Range{},
+		result: &Result{
+			Outputs:   map[string]object.Object{},
+			Citations: map[string]string{},
+			Values:    map[ast.Expr]object.Object{},
+		},
+		trace: trace,
+	}
+	result = e.result
+
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(fail)
+			if !ok {
+				panic(r)
+			}
+			errs = append(errs, f.err)
+		}
+	}()
+
+	if errs = e.declare(program, inputs); len(errs) > 0 {
+		return result, errs
+	}
+
+	for _, stmt := range program.Stmts {
+		if block, ok := stmt.(*ast.BlockStatement); ok && block.Token.Type == token.CODE {
+			e.evalBlock(block)
+		}
+	}
+	return result, e.undeterminedOutputs()
+}
+
+// undeterminedOutputs returns a warning for each output whose value is
+// unknown, with the inputs it needs, in order of name.
+func (e *evaluator) undeterminedOutputs() util.ErrorList {
+	var names []string
+	for name, value := range e.result.Outputs {
+		if _, ok := value.(*object.Unknown); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var errs util.ErrorList
+	for _, name := range names {
+		rng := e.sets[name]
+		errs.Add(name+" is "+Undetermined(e.result.Outputs[name].(*object.Unknown)), &rng).
+			WithCode(util.CodeUndetermined).WithSeverity(util.SeverityWarning)
+	}
+	return errs
+}
+
+// Undetermined describes an unknown value and the inputs it needs, ex:
+// "undetermined: need date_of_sale and price".
+func Undetermined(unknown *object.Unknown) string {
+	msg := "undetermined"
+	switch n := len(unknown.Needs); n {
+	case 0:
+	case 1:
+		msg += ": need " + unknown.Needs[0]
+	default:
+		msg += ": need " + strings.Join(unknown.Needs[:n-1], ", ") + " and " + unknown.Needs[n-1]
+	}
+	return msg
+}
+
+// Constant evaluates an expression which uses no names other than members of
+// enums, such as the default of an input, where enums are the members of each
+// enum, by name.
+func Constant(exp ast.Expr, enums map[string]map[string]bool) (value object.Object, err error) {
+	e := &evaluator{ctx: context.Background(), enums: enums}
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(fail)
+			if !ok {
+				panic(r)
+			}
+			err = f.err
+		}
+	}()
+	return e.eval(exp), nil
+}
+
+// declare records every declared name and enum, and checks the inputs.
+// Optional inputs without a value are given their defaults, once every enum
+// is known.
+func (e *evaluator) declare(program *ast.Program, inputs map[string]object.Object) util.ErrorList {
+	var (
+		errs     util.ErrorList
+		defaults []*ast.DeclareExpression
+	)
+
+	for _, stmt := range program.Stmts {
+		block, ok := stmt.(*ast.BlockStatement)
+		if !ok {
+			continue
+		}
+
+		var kind declaration
+		switch block.Token.Type {
+		case token.INPUTS:
+			kind = input
+		case token.OUTPUTS:
+			kind = output
+		case token.LOCALS:
+			kind = local
+		case token.ENUM:
+			// The parser reports an enum without a name.
+			if block.Ident != nil {
+				e.enums[block.Ident.Value] = Members(block)
+			}
+			continue
+		default:
+			continue
+		}
+
+		for _, decl := range Declarations(block) {
+			name := decl.Ident.Value
+			if _, ok := e.declared[name]; ok {
+				errs.Add(fmt.Sprintf("%s is declared more than once", name), decl.Ident.Range()).
+					WithCode(util.CodeDuplicateField)
+				continue
+			}
+			e.declared[name] = kind
+
+			if kind != input {
+				continue
+			}
+			value, ok := inputs[name]
+			switch {
+			case !ok && decl.Default != nil:
+				defaults = append(defaults, decl)
+				continue
+			case !ok:
+				errs.Add(fmt.Sprintf("missing input %s", name), decl.Ident.Range()).
+					WithCode(util.CodeMissingInput)
+				continue
+			}
+			e.values[name] = value
+		}
+	}
+
+	for name := range inputs {
+		if e.declared[name] != input {
+			errs.Add(fmt.Sprintf("unknown input %s", name), program.Range()).
+				WithCode(util.CodeUnknownInput)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for _, decl := range defaults {
+		value := e.eval(decl.Default)
+		if _, ok := value.(*object.Unknown); ok {
+			value = &object.Unknown{Needs: []string{decl.Ident.Value}}
+		}
+		e.values[decl.Ident.Value] = value
+	}
+	return nil
+}
+
+// Declarations returns the fields declared in a block.
+func Declarations(block *ast.BlockStatement) []*ast.DeclareExpression {
+	var decls []*ast.DeclareExpression
+	for _, stmt := range block.Stmts {
+		if exp, ok := stmt.(*ast.ExpressionStatement); ok {
+			if decl, ok := exp.Expr.(*ast.DeclareExpression); ok {
+				decls = append(decls, decl)
+			}
+		}
+	}
+	return decls
+}
+
+// Members returns the members of an @enum block, written as "- member".
+func Members(block *ast.BlockStatement) map[string]bool {
+	members := map[string]bool{}
+	for _, stmt := range block.Stmts {
+		exp, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			continue
+		}
+		if prefix, ok := exp.Expr.(*ast.PrefixExpression); ok && prefix.Operator == "-" {
+			if ident, ok := prefix.Right.(*ast.Identifier); ok {
+				members[ident.Value] = true
+			}
+		}
+	}
+	return members
+}
+
+func (e *evaluator) evalBlock(block *ast.BlockStatement) {
+	e.citation = block.Citation
+	e.record(&Step{Kind: BlockStep, Range: *block.Range(), Citation: block.Citation}, func() {
+		e.evalStmts(block.Stmts)
+	})
+}
+
+func (e *evaluator) evalStmts(stmts []ast.Stmt) {
+	// Whether a branch of the current if and else chain was taken, or nil if
+	// the previous statement was not part of a chain.
+	var taken *bool
+
+	for _, stmt := range stmts {
+		if err := e.ctx.Err(); err != nil {
+			panic(fail{err: &util.Error{Msg: fmt.Sprintf("stopped: %s", err), Rng: *stmt.Range(),
+				Code: util.CodeCanceled}})
+		}
+		switch stmt := stmt.(type) {
+		case *ast.CommentStatement:
+			// Comments do not break an if and else chain.
+		case *ast.IfStatement:
+			ok := e.evalBranch(stmt.Condition, stmt.Block, stmt.Token.Range)
+			taken = &ok
+		case *ast.ElseStatement:
+			if taken == nil {
+				e.fail("else must follow if", &stmt.Token.Range)
+			}
+			if !*taken {
+				ok := e.evalBranch(stmt.Condition, stmt.Block, stmt.Token.Range)
+				taken = &ok
+			}
+		case *ast.ForStatement:
+			taken = nil
+			e.evalFor(stmt)
+		case *ast.ExpressionStatement:
+			taken = nil
+			if set, ok := stmt.Expr.(*ast.SetExpression); ok {
+				e.evalSet(set)
+			} else {
+				e.result.Values[stmt.Expr] = e.eval(stmt.Expr)
+			}
+		default:
+			taken = nil
+		}
+	}
+}
+
+// evalBranch runs the block if the condition is true, or if there is no
+// condition, and returns whether it ran.
+func (e *evaluator) evalBranch(condition ast.Expr, block *ast.ScopeStatement, rng util.Range) bool {
+	if condition == nil {
+		e.record(&Step{Kind: ElseStep, Range: rng, Citation: e.citation}, func() {
+			e.evalStmts(block.Stmts)
+		})
+		return true
+	}
+
+	result := e.evalCondition(condition)
+	value, ok := result.(*object.Condition)
+	if !ok {
+		e.undetermined(result.(*object.Unknown), condition.Range())
+	}
+	step := &Step{
+		Kind:     ConditionStep,
+		Range:    *condition.Range(),
+		Citation: e.citation,
+		Value:    value.Inspect(),
+	}
+	e.record(step, func() {
+		if value.Value {
+			e.evalStmts(block.Stmts)
+		}
+	})
+	return value.Value
+}
+
+// evalCondition returns a condition, or an unknown value.
+func (e *evaluator) evalCondition(exp ast.Expr) object.Object {
+	value := e.eval(exp)
+	switch value.(type) {
+	case *object.Condition, *object.Unknown:
+		return value
+	}
+	e.fail(fmt.Sprintf("expected a condition, got %s", value.Type()), exp.Range())
+	return nil
+}
+
+func (e *evaluator) evalFor(stmt *ast.ForStatement) {
+	iter := e.eval(stmt.Iter)
+	if unknown, ok := iter.(*object.Unknown); ok {
+		e.undetermined(unknown, stmt.Iter.Range())
+	}
+	list, ok := iter.(*object.List)
+	if !ok {
+		e.fail(fmt.Sprintf("can only loop over a list, got %s", iter.Type()), stmt.Iter.Range())
+	}
+
+	name := stmt.Ident.Value
+	if _, ok := e.declared[name]; ok || e.loopVariable(name) {
+		e.fail(fmt.Sprintf("%s is already declared", name), stmt.Ident.Range())
+	}
+
+	step := &Step{Kind: LoopStep, Range: *stmt.Iter.Range(), Citation: e.citation, Name: name}
+	e.record(step, func() {
+		for _, elem := range list.Elems {
+			e.scopes = append(e.scopes, map[string]object.Object{name: elem})
+
+			iteration := &Step{
+				Kind:     IterationStep,
+				Range:    *stmt.Ident.Range(),
+				Citation: e.citation,
+				Name:     name,
+				Value:    elem.Inspect(),
+			}
+			e.record(iteration, func() { e.evalStmts(stmt.Block.Stmts) })
+
+			e.scopes = e.scopes[:len(e.scopes)-1]
+		}
+	})
+}
+
+func (e *evaluator) evalSet(set *ast.SetExpression) {
+	name := set.Ident.Value
+	switch e.declared[name] {
+	case input:
+		e.fail(fmt.Sprintf("can not set input %s", name), set.Ident.Range())
+	case output, local:
+	default:
+		if e.loopVariable(name) {
+			e.fail(fmt.Sprintf("can not set loop variable %s", name), set.Ident.Range())
+		}
+		e.fail(fmt.Sprintf("%s is not declared", name), set.Ident.Range())
+	}
+
+	value := e.eval(set.Value)
+	old, hasOld := e.values[name]
+	e.values[name] = value
+
+	if e.declared[name] == output {
+		e.result.Outputs[name] = value
+		e.sets[name] = *set.Range()
+		if set.Citation != "" {
+			e.result.Citations[name] = set.Citation
+		} else {
+			delete(e.result.Citations, name)
+		}
+	}
+
+	step := &Step{
+		Kind:     SetStep,
+		Range:    *set.Range(),
+		Citation: set.Citation,
+		Name:     name,
+		Value:    value.Inspect(),
+	}
+	if hasOld {
+		step.Old = old.Inspect()
+	}
+	e.record(step, nil)
+}
+
+func (e *evaluator) eval(exp ast.Expr) object.Object {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return e.evalIdentifier(exp)
+	case *ast.MemberExpression:
+		return e.evalMember(exp)
+	case *ast.PrefixExpression:
+		return e.evalPrefix(exp)
+	case *ast.InfixExpression:
+		return e.evalInfix(exp)
+	case *ast.TextLiteral:
+		return &object.Text{Value: exp.Value}
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: exp.Value}
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Value: literalFloat(exp.Token.Literal, "", float64(exp.Value))}
+	case *ast.MoneyLiteral:
+		amount := literalFloat(exp.Token.Literal, exp.Symbol, float64(exp.Value))
+		return object.NewMoney(amount, exp.Symbol)
+	case *ast.PercentLiteral:
+		return &object.Percent{Value: literalFloat(
+			strings.TrimSuffix(exp.Token.Literal, "%"), "", float64(exp.Value))}
+	case *ast.PeriodLiteral:
+		period, ok := object.NewPeriod(exp.Value, exp.Symbol)
+		if !ok {
+			e.fail(fmt.Sprintf("unknown period %s", exp.Symbol), exp.Range())
+		}
+		return period
+	case *ast.DateLiteral:
+		return &object.Date{Year: exp.Year, Month: exp.Month, Day: exp.Day}
+	case *ast.TimeLiteral:
+		return &object.Time{Hours: exp.Hours, Minutes: exp.Minutes, Seconds: exp.Seconds}
+	case *ast.Condition:
+		return object.NativeCondition(exp.Value)
+	case *ast.Unknown:
+		return &object.Unknown{}
+	}
+	e.fail(fmt.Sprintf("can not evaluate %s", describe(exp)), exp.Range())
+	return nil
+}
+
+func (e *evaluator) evalIdentifier(ident *ast.Identifier) object.Object {
+	name := ident.Value
+	if value, ok := e.lookup(name); ok {
+		return value
+	}
+	if _, ok := e.declared[name]; ok {
+		e.fail(fmt.Sprintf("%s has not been set", name), ident.Range())
+	}
+	if _, ok := e.enums[name]; ok {
+		e.fail(fmt.Sprintf("%s must be followed by a member, ex: %s.value", name, name),
+			ident.Range())
+	}
+	e.fail(fmt.Sprintf("%s is not declared", name), ident.Range())
+	return nil
+}
+
+func (e *evaluator) evalMember(exp *ast.MemberExpression) object.Object {
+	if ident, ok := exp.Left.(*ast.Identifier); ok {
+		if members, ok := e.enums[ident.Value]; ok {
+			if !members[exp.Ident.Value] {
+				e.fail(fmt.Sprintf("%s has no member %s", ident.Value, exp.Ident.Value),
+					exp.Ident.Range())
+			}
+			return &object.Enum{Name: ident.Value, Value: exp.Ident.Value}
+		}
+	}
+
+	left := e.eval(exp.Left)
+	if _, ok := left.(*object.Unknown); ok {
+		return left
+	}
+	group, ok := left.(*object.Group)
+	if !ok {
+		e.fail(fmt.Sprintf("%s has no fields", left.Type()), exp.Range())
+	}
+
+	value, ok := group.Fields[exp.Ident.Value]
+	if !ok {
+		e.fail(fmt.Sprintf("%s has no value for %s", group.Name, exp.Ident.Value),
+			exp.Ident.Range())
+	}
+	return value
+}
+
+func (e *evaluator) evalPrefix(exp *ast.PrefixExpression) object.Object {
+	right := e.eval(exp.Right)
+	if exp.Operator == "-" {
+		switch right := right.(type) {
+		case *object.Unknown:
+			return right
+		case *object.Integer:
+			return &object.Integer{Value: -right.Value}
+		case *object.Decimal:
+			return &object.Decimal{Value: -right.Value}
+		case *object.Money:
+			return &object.Money{Cents: -right.Cents, Symbol: right.Symbol}
+		case *object.Percent:
+			return &object.Percent{Value: -right.Value}
+		case *object.Period:
+			return scalePeriod(right, -1)
+		}
+	}
+	e.fail(fmt.Sprintf("operator %s is not defined for %s", exp.Operator, right.Type()),
+		exp.Range())
+	return nil
+}
+
+func (e *evaluator) evalInfix(exp *ast.InfixExpression) object.Object {
+	switch exp.Operator {
+	case "and", "or":
+		// A side which is false for and, or true for or, decides the result
+		// even if the other is unknown. Only evaluate the right side when it
+		// can change the result.
+		decisive := exp.Operator == "or"
+		left := e.evalCondition(exp.Left)
+		if isCondition(left, decisive) {
+			return left
+		}
+		right := e.evalCondition(exp.Right)
+		if isCondition(right, decisive) {
+			return right
+		}
+		if _, ok := left.(*object.Unknown); ok {
+			return object.NewUnknown(left, right)
+		}
+		return right
+	}
+
+	left, right := e.eval(exp.Left), e.eval(exp.Right)
+	if isUnknown(left) || isUnknown(right) {
+		return object.NewUnknown(left, right)
+	}
+	value, err := infix(exp.Operator, left, right)
+	if err != "" {
+		e.fail(err, exp.Range())
+	}
+	return value
+}
+
+// isCondition reports whether value is the condition of value v.
+func isCondition(value object.Object, v bool) bool {
+	condition, ok := value.(*object.Condition)
+	return ok && condition.Value == v
+}
+
+func isUnknown(value object.Object) bool {
+	_, ok := value.(*object.Unknown)
+	return ok
+}
+
+func (e *evaluator) lookup(name string) (object.Object, bool) {
+	for i := len(e.scopes) - 1; i >= 0; i-- {
+		if value, ok := e.scopes[i][name]; ok {
+			return value, true
+		}
+	}
+	value, ok := e.values[name]
+	return value, ok
+}
+
+func (e *evaluator) loopVariable(name string) bool {
+	for _, scope := range e.scopes {
+		if _, ok := scope[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// record adds the step to the trace, and records every step taken by run as a
+// child of it.
+func (e *evaluator) record(step *Step, run func()) {
+	if e.trace == nil {
+		if run != nil {
+			run()
+		}
+		return
+	}
+
+	parent := e.step
+	if parent == nil {
+		e.trace.Steps = append(e.trace.Steps, step)
+	} else {
+		parent.Steps = append(parent.Steps, step)
+	}
+
+	if run != nil {
+		e.step = step
+		defer func() { e.step = parent }()
+		run()
+	}
+}
+
+func (e *evaluator) fail(msg string, rng *util.Range) {
+	e.stop(msg, rng, util.CodeRuntime)
+}
+
+// undetermined stops evaluation where an unknown value decides what happens
+// next, with the inputs it needs, ex: "outcome undetermined: need
+// date_of_sale".
+func (e *evaluator) undetermined(unknown *object.Unknown, rng *util.Range) {
+	e.stop("outcome "+Undetermined(unknown), rng, util.CodeUndetermined)
+}
+
+func (e *evaluator) stop(msg string, rng *util.Range, code util.Code) {
+	if cite := citation.Cite(e.citation); cite != "" {
+		msg += " (" + cite + ")"
+	}
+	panic(fail{err: &util.Error{Msg: msg, Rng: *rng, Code: code}})
+}
+
+// literalFloat parses the literal of a number token without losing precision,
+// falling back to value if the literal can not be parsed.
+func literalFloat(literal, symbol string, value float64) float64 {
+	literal = strings.TrimPrefix(literal, symbol)
+	parsed, err := strconv.ParseFloat(strings.NewReplacer("_", "", ",", "").Replace(literal), 64)
+	if err != nil {
+		return value
+	}
+	return parsed
+}
+
+func describe(exp ast.Expr) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", exp), "*ast.")
+}
@@ -0,0 +1,291 @@
+package evaluator_test
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/citation"
+	"github.com/policyscript/policyscript/evaluator"
+	"github.com/policyscript/policyscript/object"
+	"github.com/policyscript/policyscript/parser"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/util"
+)
+
+const demo = `@meta {
+  set path      to ` + "`121`" + `
+  set numbering to ` + "`legal`" + `
+}
+
+@enum Age {
+  - young
+  - old
+}
+
+@define Person {
+  age: Age
+  countries_lived_in: text list
+}
+
+@inputs {
+  person: Person
+}
+
+@outputs {
+  can_read: condition
+}
+
+_ Ability to read
+
+If a person has lived in Canada, they are able to read.
+
+@locals {
+  has_lived_in_canada: condition
+}
+
+@code {
+  set has_lived_in_canada to false
+  for country in person.countries_lived_in:
+    if country = ` + "`Canada`" + `:
+      set has_lived_in_canada to true
+  set can_read to has_lived_in_canada
+}
+
+_ _ Exceptions
+
+However, if a person is young, they are unable to read.
+
+@code {
+  if person.age = Age.young:
+    set can_read to false
+}
+`
+
+var _ = Describe("Evaluator", func() {
+	util.Each("can evaluate expression", [][2]string{
+		{"1 + 2 * 3", "7"},
+		{"7 / 2", "3.5"},
+		{"8 / 2", "4"},
+		{"1.5 + 2", "3.5"},
+		{"$250_000 * 15%", "$37_500.00"},
+		{"$10.10 + $0.20", "$10.30"},
+		{"$100 / 3", "$33.33"},
+		{"$100 / $50", "2.0"},
+		{"200 * 15%", "30.0"},
+		{"10% + 5%", "15%"},
+		{"|2020/01/31| + 1 month", "|2020/03/02|"},
+		{"|2020/01/01| + 2 years", "|2022/01/01|"},
+		{"|2021/01/15| - |2019/01/20|", "1 year 11 months 26 days"},
+		{"|2021/01/15| - |2019/01/20| >= 2 years", "false"},
+		{"|2021/03/01| - |2021/01/31|", "1 month 1 day"},
+		{"|2021/02/28| - |2021/01/31|", "28 days"},
+		{"|2021/01/01| - |2020/12/31|", "1 day"},
+		{"|2020/03/01| - |2020/02/28|", "2 days"},
+		{"|2021/03/01| - |2021/02/28|", "1 day"},
+		{"|2020/03/01| - |2020/01/31|", "1 month 1 day"},
+		{"|2020/01/31| - |2020/03/01|", "-1 month -1 day"},
+		{"1 month > 40 days", "false"},
+		{"730 days >= 2 years", "true"},
+		{"12 months = 1 year", "true"},
+		{"2 days * 3", "6 days"},
+		{"-(1 - 3)", "2"},
+		{"|08:30| < |12:00|", "true"},
+		{"1 = 1.0", "true"},
+		{"`a` + `b` = `ab`", "true"},
+		{"true and false or true", "true"},
+		{"Age.old != Age.young", "true"},
+	}, func(input, expects string) {
+		result, errs := evaluate("@enum Age {\n  - young\n  - old\n}\n@outputs {\n  value: integer\n}\n"+
+			"@code {\n  set value to "+input+"\n}", nil, nil)
+		Expect(errs).To(BeEmpty())
+		Expect(result.Outputs["value"].Inspect()).To(Equal(expects))
+	})
+
+	util.Each("reports error", [][2]string{
+		{"set value to 1 / 0", "division by zero"},
+		{"set value to $1 + |2020/01/01|", "operator + is not defined for money and date"},
+		{"set value to missing", "missing is not declared"},
+		{"set value to unset", "unset has not been set"},
+		{"set missing to 1", "missing is not declared"},
+		{"set person to 1", "can not set input person"},
+		{"if 1:\n    set value to 1", "expected a condition, got integer"},
+		{"else:\n    set value to 1", "else must follow if"},
+		{"set value to Age.middle", "Age has no member middle"},
+	}, func(input, expects string) {
+		_, errs := evaluate("@enum Age {\n  - young\n}\n@inputs {\n  person: Person\n}\n"+
+			"@outputs {\n  value: integer\n}\n@locals {\n  unset: integer\n}\n"+
+			"@code {\n  "+input+"\n}", map[string]object.Object{"person": person("young")}, nil)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Msg).To(Equal(expects))
+	})
+
+	It("reports missing and unknown inputs", func() {
+		_, errs := evaluate("@inputs {\n  a: integer\n}", map[string]object.Object{
+			"b": &object.Integer{Value: 1},
+		}, nil)
+		Expect(errs).To(HaveLen(2))
+		Expect(errs[0].Msg).To(Equal("missing input a"))
+		Expect(errs[1].Msg).To(Equal("unknown input b"))
+	})
+
+	It("skips enums without a name", func() {
+		p := parser.New(*scanner.New(util.NewFile("", []byte("@enum {\n  - a\n}")), nil))
+		program := p.ParseProgram()
+		Expect(p.Errors()).To(HaveLen(1))
+		_, errs := evaluator.Evaluate(program, nil, nil)
+		Expect(errs).To(BeEmpty())
+	})
+
+	util.Each("follows three-valued logic with unknown values", [][2]string{
+		{"sold < |2020/01/01|", "unknown"},
+		{"sold + 1 day", "unknown"},
+		{"unknown = unknown", "unknown"},
+		{"false and known", "false"},
+		{"known and false", "false"},
+		{"known and true", "unknown"},
+		{"true or known", "true"},
+		{"known or true", "true"},
+		{"known or false", "unknown"},
+		{"known or sold = |2020/01/01|", "unknown"},
+	}, func(input, expects string) {
+		result, errs := evaluate("@inputs {\n  known: condition or unknown\n  sold: date or unknown\n}\n"+
+			"@outputs {\n  value: condition\n}\n@code {\n  set value to "+input+"\n}", nil, nil)
+		Expect(errs.HasErrors()).To(BeFalse())
+		Expect(result.Outputs["value"].Inspect()).To(Equal(expects))
+	})
+
+	It("gives optional inputs their defaults", func() {
+		result, errs := evaluate("@enum Age {\n  - young\n  - old\n}\n"+
+			"@inputs {\n  age: Age or Age.old\n  rate: percent or 5% + 5%\n  sold: date or unknown\n}\n"+
+			"@outputs {\n  a: Age\n  b: percent\n  c: date\n}\n"+
+			"@code {\n  set a to age\n  set b to rate\n  set c to sold\n}",
+			map[string]object.Object{"rate": &object.Percent{Value: 15}}, nil)
+		Expect(errs).To(HaveLen(1))
+		Expect(result.Outputs["a"].Inspect()).To(Equal("Age.old"))
+		Expect(result.Outputs["b"].Inspect()).To(Equal("15%"))
+		Expect(result.Outputs["c"]).To(Equal(&object.Unknown{Needs: []string{"sold"}}))
+	})
+
+	It("warns of outputs which are unknown", func() {
+		result, errs := evaluate("@inputs {\n  sold: date or unknown\n  price: money or unknown\n}\n"+
+			"@outputs {\n  value: condition\n}\n"+
+			"@code {\n  set value to price > $5 and sold < |2020/01/01|\n}", nil, nil)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Error()).To(Equal("9:2-9:49: warning: value is undetermined: need price and sold [PS5006]"))
+		Expect(result.Outputs["value"]).To(Equal(&object.Unknown{Needs: []string{"price", "sold"}}))
+	})
+
+	It("stops where an unknown value decides the outcome", func() {
+		result, errs := evaluate("@meta {\n  set path to `121`\n}\n\n_ (b) A\n\n"+
+			"@inputs {\n  sold: date or unknown\n}\n@outputs {\n  early: condition\n}\n"+
+			"@code {\n  set early to false\n  if sold < |2020/01/01|:\n    set early to true\n}", nil, nil)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Msg).To(Equal("outcome undetermined: need sold (§121(b))"))
+		Expect(errs[0].Code).To(Equal(util.CodeUndetermined))
+		Expect(errs[0].Severity).To(Equal(util.SeverityError))
+		Expect(result.Outputs["early"].Inspect()).To(Equal("false"))
+
+		_, errs = evaluate("@inputs {\n  countries: text list or unknown\n}\n@locals {\n  n: integer\n}\n"+
+			"@code {\n  for country in countries:\n    set n to 1\n}", nil, nil)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Msg).To(Equal("outcome undetermined: need countries"))
+	})
+
+	It("takes the first matching branch", func() {
+		result, errs := evaluate("@outputs {\n  value: integer\n}\n@code {\n"+
+			"  if false:\n    set value to 1\n"+
+			"  else if true:\n    set value to 2\n"+
+			"  # A comment.\n"+
+			"  else if true:\n    set value to 3\n"+
+			"  else:\n    set value to 4\n}", nil, nil)
+		Expect(errs).To(BeEmpty())
+		Expect(result.Outputs["value"].Inspect()).To(Equal("2"))
+	})
+
+	It("can evaluate the demo with citations", func() {
+		result, errs := evaluate(demo, map[string]object.Object{"person": person("old", "USA", "Canada")}, nil)
+		Expect(errs).To(BeEmpty())
+		Expect(result.Outputs["can_read"].Inspect()).To(Equal("true"))
+		Expect(result.Citations["can_read"]).To(Equal("121(a)"))
+
+		result, errs = evaluate(demo, map[string]object.Object{"person": person("young", "Canada")}, nil)
+		Expect(errs).To(BeEmpty())
+		Expect(result.Outputs["can_read"].Inspect()).To(Equal("false"))
+		Expect(result.Citations["can_read"]).To(Equal("121(a)(1)"))
+	})
+
+	It("records a trace", func() {
+		trace := &evaluator.Trace{}
+		_, errs := evaluate(demo, map[string]object.Object{"person": person("young", "Canada")}, trace)
+		Expect(errs).To(BeEmpty())
+
+		Expect(trace.Steps).To(HaveLen(2))
+		Expect(trace.Steps[0].Kind).To(Equal(evaluator.BlockStep))
+		Expect(trace.Steps[0].Citation).To(Equal("121(a)"))
+
+		set := trace.Steps[0].Steps[1].Steps[0].Steps[0].Steps[0]
+		Expect(set.Kind).To(Equal(evaluator.SetStep))
+		Expect(set.Name).To(Equal("has_lived_in_canada"))
+		Expect(set.Old).To(Equal("false"))
+		Expect(set.Value).To(Equal("true"))
+		Expect(set.Range.Start.Line).To(Equal(36))
+
+		data, err := json.Marshal(trace)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`{"kind":"condition","range":{"start":{"line":35,"column":7,"offset":`))
+
+		files := util.NewFileSet()
+		files.AddFile("", []byte(demo))
+		Expect(trace.Narrative(files)).To(Equal(`Under §121(a):
+  Set has_lived_in_canada to false (was unset) by §121(a).
+  For each country in "person.countries_lived_in":
+    With country as ` + "`Canada`" + `:
+      Checked whether "country = ` + "`Canada`" + `", which was true.
+        Set has_lived_in_canada to true (was false) by §121(a).
+  Set can_read to true (was unset) by §121(a).
+Under §121(a)(1):
+  Checked whether "person.age = Age.young", which was true.
+    Set can_read to false (was true) by §121(a)(1).
+`))
+	})
+
+	It("cites the section in errors", func() {
+		_, errs := evaluate("@meta {\n  set path to `121`\n}\n\n_ (b) A\n\n"+
+			"@outputs {\n  value: integer\n}\n@code {\n  set value to 1 / 0\n}", nil, nil)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Msg).To(Equal("division by zero (§121(b))"))
+	})
+
+	It("stops once its context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		p := parser.New(*scanner.New(util.NewFile("", []byte("@outputs {\n  value: integer\n}\n"+
+			"@code {\n  set value to 1\n}")), nil))
+		result, errs := evaluator.EvaluateContext(ctx, p.ParseProgram(), nil, nil)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Msg).To(Equal("stopped: context canceled"))
+		Expect(errs[0].Code).To(Equal(util.CodeCanceled))
+		Expect(result.Outputs).To(BeEmpty())
+	})
+})
+
+func evaluate(input string, inputs map[string]object.Object, trace *evaluator.Trace) (*evaluator.Result, util.ErrorList) {
+	p := parser.New(*scanner.New(util.NewFile("", []byte(input)), nil))
+	program := p.ParseProgram()
+	Expect(p.Errors()).To(BeEmpty())
+	Expect(citation.Resolve(program)).To(BeEmpty())
+	return evaluator.Evaluate(program, inputs, trace)
+}
+
+func person(age string, countries ...string) *object.Group {
+	list := &object.List{}
+	for _, country := range countries {
+		list.Elems = append(list.Elems, &object.Text{Value: country})
+	}
+	return &object.Group{Name: "Person", Fields: map[string]object.Object{
+		"age":                &object.Enum{Name: "Age", Value: age},
+		"countries_lived_in": list,
+	}}
+}
@@ -0,0 +1,321 @@
+package evaluator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/policyscript/policyscript/object"
+)
+
+// infix applies an operator to two values. It returns an error message if the
+// operator is not defined for the values.
+func infix(operator string, left, right object.Object) (object.Object, string) {
+	switch operator {
+	case "=":
+		return object.NativeCondition(equal(left, right)), ""
+	case "!=":
+		return object.NativeCondition(!equal(left, right)), ""
+	case "<", ">", "<=", ">=":
+		if cmp, ok := compare(left, right); ok {
+			return object.NativeCondition(compareResult(operator, cmp)), ""
+		}
+	case "+", "-", "*", "/":
+		if value, err := arithmetic(operator, left, right); err != "" || value != nil {
+			return value, err
+		}
+	}
+	return nil, fmt.Sprintf("operator %s is not defined for %s and %s",
+		operator, left.Type(), right.Type())
+}
+
+func equal(left, right object.Object) bool {
+	if l, ok := number(left); ok {
+		if r, ok := number(right); ok {
+			return l == r
+		}
+	}
+	return object.Equal(left, right)
+}
+
+// compare returns -1, 0 or 1 if the values can be ordered.
+func compare(left, right object.Object) (int, bool) {
+	if l, ok := number(left); ok {
+		if r, ok := number(right); ok {
+			return compareFloats(l, r), true
+		}
+		return 0, false
+	}
+
+	if left.Type() != right.Type() {
+		return 0, false
+	}
+	switch left := left.(type) {
+	case *object.Money:
+		right := right.(*object.Money)
+		if left.Symbol != right.Symbol {
+			return 0, false
+		}
+		return compareFloats(float64(left.Cents), float64(right.Cents)), true
+	case *object.Percent:
+		return compareFloats(left.Value, right.(*object.Percent).Value), true
+	case *object.Period:
+		return left.Compare(right.(*object.Period)), true
+	case *object.Date:
+		return left.Compare(right.(*object.Date)), true
+	case *object.Time:
+		return left.Compare(right.(*object.Time)), true
+	}
+	return 0, false
+}
+
+func compareResult(operator string, cmp int) bool {
+	switch operator {
+	case "<":
+		return cmp < 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	}
+	return cmp >= 0
+}
+
+// arithmetic returns nil and no error if the operator is not defined for the
+// values.
+func arithmetic(operator string, left, right object.Object) (object.Object, string) {
+	switch left := left.(type) {
+	case *object.Integer:
+		if right, ok := right.(*object.Integer); ok {
+			return integerArithmetic(operator, left.Value, right.Value)
+		}
+	case *object.Text:
+		if right, ok := right.(*object.Text); ok && operator == "+" {
+			return &object.Text{Value: left.Value + right.Value}, ""
+		}
+		return nil, ""
+	case *object.Money:
+		return moneyArithmetic(operator, left, right)
+	case *object.Date:
+		return dateArithmetic(operator, left, right)
+	case *object.Period:
+		return periodArithmetic(operator, left, right)
+	case *object.Percent:
+		if right, ok := right.(*object.Percent); ok {
+			switch operator {
+			case "+":
+				return &object.Percent{Value: left.Value + right.Value}, ""
+			case "-":
+				return &object.Percent{Value: left.Value - right.Value}, ""
+			case "*":
+				return &object.Percent{Value: left.Value * right.Ratio()}, ""
+			}
+			return nil, ""
+		}
+	}
+
+	switch right := right.(type) {
+	case *object.Money:
+		if operator == "*" {
+			return moneyArithmetic(operator, right, left)
+		}
+		return nil, ""
+	case *object.Period:
+		if operator == "*" {
+			return periodArithmetic(operator, right, left)
+		}
+		return nil, ""
+	}
+
+	// The remaining operations are on integers, decimals and percentages. A
+	// percentage is used as a fraction, so 10 * 15% is 1.5.
+	l, ok := fraction(left)
+	if !ok {
+		return nil, ""
+	}
+	r, ok := fraction(right)
+	if !ok {
+		return nil, ""
+	}
+	if _, ok := left.(*object.Percent); ok && operator != "*" && operator != "/" {
+		return nil, ""
+	}
+	if _, ok := right.(*object.Percent); ok && operator != "*" && operator != "/" {
+		return nil, ""
+	}
+
+	switch operator {
+	case "+":
+		return &object.Decimal{Value: l + r}, ""
+	case "-":
+		return &object.Decimal{Value: l - r}, ""
+	case "*":
+		return &object.Decimal{Value: l * r}, ""
+	}
+	if r == 0 {
+		return nil, "division by zero"
+	}
+	return &object.Decimal{Value: l / r}, ""
+}
+
+func integerArithmetic(operator string, l, r int) (object.Object, string) {
+	switch operator {
+	case "+":
+		return &object.Integer{Value: l + r}, ""
+	case "-":
+		return &object.Integer{Value: l - r}, ""
+	case "*":
+		return &object.Integer{Value: l * r}, ""
+	}
+	if r == 0 {
+		return nil, "division by zero"
+	}
+	if l%r == 0 {
+		return &object.Integer{Value: l / r}, ""
+	}
+	return &object.Decimal{Value: float64(l) / float64(r)}, ""
+}
+
+func moneyArithmetic(operator string, left *object.Money, right object.Object) (object.Object, string) {
+	if right, ok := right.(*object.Money); ok {
+		if left.Symbol != right.Symbol {
+			return nil, fmt.Sprintf("can not combine %s and %s money", left.Symbol, right.Symbol)
+		}
+		switch operator {
+		case "+":
+			return &object.Money{Cents: left.Cents + right.Cents, Symbol: left.Symbol}, ""
+		case "-":
+			return &object.Money{Cents: left.Cents - right.Cents, Symbol: left.Symbol}, ""
+		case "/":
+			if right.Cents == 0 {
+				return nil, "division by zero"
+			}
+			return &object.Decimal{Value: float64(left.Cents) / float64(right.Cents)}, ""
+		}
+		return nil, ""
+	}
+
+	factor, ok := fraction(right)
+	if !ok {
+		return nil, ""
+	}
+	switch operator {
+	case "*":
+		return object.NewMoney(left.Amount()*factor, left.Symbol), ""
+	case "/":
+		if factor == 0 {
+			return nil, "division by zero"
+		}
+		return object.NewMoney(left.Amount()/factor, left.Symbol), ""
+	}
+	return nil, ""
+}
+
+func dateArithmetic(operator string, left *object.Date, right object.Object) (object.Object, string) {
+	switch right := right.(type) {
+	case *object.Period:
+		switch operator {
+		case "+":
+			return addPeriod(left, right), ""
+		case "-":
+			return addPeriod(left, scalePeriod(right, -1)), ""
+		}
+	case *object.Date:
+		if operator == "-" {
+			return between(right, left), ""
+		}
+	}
+	return nil, ""
+}
+
+func periodArithmetic(operator string, left *object.Period, right object.Object) (object.Object, string) {
+	switch right := right.(type) {
+	case *object.Period:
+		switch operator {
+		case "+":
+			return &object.Period{
+				Years:   left.Years + right.Years,
+				Months:  left.Months + right.Months,
+				Days:    left.Days + right.Days,
+				Seconds: left.Seconds + right.Seconds,
+			}, ""
+		case "-":
+			return periodArithmetic("+", left, scalePeriod(right, -1))
+		}
+	case *object.Date:
+		if operator == "+" {
+			return addPeriod(right, left), ""
+		}
+	case *object.Integer:
+		if operator == "*" {
+			return scalePeriod(left, right.Value), ""
+		}
+	}
+	return nil, ""
+}
+
+func addPeriod(date *object.Date, period *object.Period) *object.Date {
+	t := date.Time().AddDate(period.Years, period.Months, period.Days)
+	return object.NewDate(t.Add(time.Duration(period.Seconds) * time.Second))
+}
+
+func scalePeriod(period *object.Period, n int) *object.Period {
+	return &object.Period{
+		Years:   period.Years * n,
+		Months:  period.Months * n,
+		Days:    period.Days * n,
+		Seconds: period.Seconds * n,
+	}
+}
+
+// between returns the calendar period from one date to another, ex: from
+// |2019/01/20| to |2021/01/15| is 1 year, 11 months and 26 days.
+func between(from, to *object.Date) *object.Period {
+	if from.Compare(to) > 0 {
+		return scalePeriod(between(to, from), -1)
+	}
+
+	years := to.Year - from.Year
+	months := to.Month - from.Month
+	days := to.Day - from.Day
+	if days < 0 {
+		months--
+		// Borrow the days of the month of the start date, which is never
+		// fewer than its day, so the days are never negative.
+		days += time.Date(from.Year, time.Month(from.Month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	}
+	if months < 0 {
+		years--
+		months += 12
+	}
+	return &object.Period{Years: years, Months: months, Days: days}
+}
+
+// number returns the value of an integer or decimal.
+func number(o object.Object) (float64, bool) {
+	switch o := o.(type) {
+	case *object.Integer:
+		return float64(o.Value), true
+	case *object.Decimal:
+		return o.Value, true
+	}
+	return 0, false
+}
+
+// fraction returns the value of an integer, decimal or percentage, where a
+// percentage is a fraction of 1.
+func fraction(o object.Object) (float64, bool) {
+	if p, ok := o.(*object.Percent); ok {
+		return p.Ratio(), true
+	}
+	return number(o)
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
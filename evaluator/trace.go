@@ -0,0 +1,119 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/policyscript/policyscript/citation"
+	"github.com/policyscript/policyscript/util"
+)
+
+// StepKind is the kind of a step in a trace.
+type StepKind string
+
+const (
+	BlockStep     StepKind = "block"     // an @code block
+	ConditionStep StepKind = "condition" // an if or else if condition
+	ElseStep      StepKind = "else"      // an else without a condition
+	LoopStep      StepKind = "loop"      // a for statement
+	IterationStep StepKind = "iteration" // one item of a for statement
+	SetStep       StepKind = "set"       // a set statement
+)
+
+// Trace records why each output was computed. It is a tree of steps, where
+// the steps inside a block, branch or loop are its children.
+type Trace struct {
+	Steps []*Step `json:"steps"`
+}
+
+// Step is a single evaluated block, condition, loop or set.
+type Step struct {
+	Kind StepKind `json:"kind"`
+
+	// Range of the evaluated source.
+	Range util.Range `json:"range"`
+
+	// Citation path of the enclosing heading, ex: "121(b)(1)".
+	Citation string `json:"citation,omitempty"`
+
+	// Name of the set field or loop variable.
+	Name string `json:"name,omitempty"`
+
+	// Value of a condition, the new value of a set, or the item of an
+	// iteration.
+	Value string `json:"value,omitempty"`
+
+	// Old value of a set, or empty if it was not set before.
+	Old string `json:"old,omitempty"`
+
+	Steps []*Step `json:"steps,omitempty"`
+}
+
+// Narrative returns the trace as indented sentences. The files are used to
+// quote conditions and loops, and may be nil.
+func (t *Trace) Narrative(files *util.FileSet) string {
+	var b strings.Builder
+	q := &quoter{files: files, sources: map[string][]rune{}}
+	for _, step := range t.Steps {
+		step.narrate(&b, q, 0)
+	}
+	return b.String()
+}
+
+func (s *Step) narrate(b *strings.Builder, source *quoter, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+
+	switch s.Kind {
+	case BlockStep:
+		if cite := citation.Cite(s.Citation); cite != "" {
+			fmt.Fprintf(b, "Under %s:\n", cite)
+		} else {
+			fmt.Fprintf(b, "In the code at line %d:\n", s.Range.Start.Line)
+		}
+	case ConditionStep:
+		fmt.Fprintf(b, "Checked whether %s, which was %s.\n", quote(source, s.Range), s.Value)
+	case ElseStep:
+		fmt.Fprintf(b, "Otherwise, at line %d:\n", s.Range.Start.Line)
+	case LoopStep:
+		fmt.Fprintf(b, "For each %s in %s:\n", s.Name, quote(source, s.Range))
+	case IterationStep:
+		fmt.Fprintf(b, "With %s as %s:\n", s.Name, s.Value)
+	case SetStep:
+		old := "unset"
+		if s.Old != "" {
+			old = s.Old
+		}
+		fmt.Fprintf(b, "Set %s to %s (was %s)", s.Name, s.Value, old)
+		if cite := citation.Cite(s.Citation); cite != "" {
+			fmt.Fprintf(b, " by %s", cite)
+		}
+		b.WriteString(".\n")
+	}
+
+	for _, step := range s.Steps {
+		step.narrate(b, source, depth+1)
+	}
+}
+
+// quoter quotes the source of ranges in files, which it decodes once each.
+type quoter struct {
+	files   *util.FileSet
+	sources map[string][]rune
+}
+
+// quote returns the source of a range, or its position if the source is not
+// available.
+func quote(q *quoter, rng util.Range) string {
+	source, ok := q.sources[rng.Start.Filename]
+	if !ok && q.files != nil {
+		if file := q.files.Lookup(rng.Start.Filename); file != nil {
+			source = []rune(string(file.Source()))
+		}
+		q.sources[rng.Start.Filename] = source
+	}
+	start, end := rng.Start.Offset, rng.End.Offset
+	if start < 0 || end > len(source) || start >= end {
+		return "the expression at " + rng.Start.String()
+	}
+	return "\"" + string(source[start:end]) + "\""
+}
@@ -0,0 +1,334 @@
+// Package format writes PolicyScript in a canonical layout, so that a file
+// reads the same whoever edited it last.
+//
+// The layout is:
+//
+//   - top level statements are separated by one blank line, except that a
+//     comment stays directly above or below what it was written next to;
+//   - statements in blocks are indented by two spaces per level, and one blank
+//     line is kept wherever the source had at least one;
+//   - declarations are written "name: type", without aligning their types, so
+//     that adding a field does not change its neighbours;
+//   - operators are surrounded by single spaces, and only the parentheses
+//     needed by precedence are kept;
+//   - headings are written with a "_ " per level, and trailing whitespace is
+//     removed from the text of paragraphs and comments, which is otherwise
+//     kept as written.
+//
+// Comments are always on lines of their own, since a comment at the end of a
+// statement is a syntax error, so files with one are not formatted. Nor are
+// files with a paragraph which would be read as something else once it starts
+// a line, such as "@outputs" written directly after the "}" of a block.
+//
+// Formatting is idempotent: formatting a formatted file does not change it.
+package format
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/parser"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Indentation of each level of a block.
+const indent = "  "
+
+// Source formats the source of a file. Files which can not be parsed are not
+// formatted, and their errors are returned instead.
+func Source(file *util.File) ([]byte, util.ErrorList) {
+	var errs util.ErrorList
+	s := scanner.New(file, func(err *util.Error) {
+		errs = append(errs, err)
+	})
+	parse := parser.New(*s)
+	program := parse.ParseProgram()
+	if errs = append(errs, parse.Errors()...); errs.HasErrors() {
+		return nil, errs
+	}
+	for _, stmt := range program.Stmts {
+		if paragraph, ok := stmt.(*ast.ParagraphStatement); ok {
+			unmoved(file, paragraph, &errs)
+		}
+	}
+	if errs.HasErrors() {
+		return nil, errs
+	}
+
+	p := &printer{}
+	p.program(program)
+	return []byte(p.b.String()), nil
+}
+
+// unmoved reports an error unless a paragraph is read as the same paragraph
+// once it is written at the start of a line, as the printer writes it.
+func unmoved(file *util.File, paragraph *ast.ParagraphStatement, errs *util.ErrorList) {
+	p := &printer{}
+	p.lines(paragraph.Value)
+	text := strings.TrimSuffix(p.b.String(), "\n")
+
+	tok := scanner.New(util.NewFile(file.Name(), []byte(text)), nil).NextToken()
+	if tok.Type != token.PARAGRAPH || tok.Literal != text {
+		errs.Add(fmt.Sprintf("%q would not be read as a paragraph at the start of a line",
+			strings.SplitN(text, "\n", 2)[0]), paragraph.Range()).WithCode(util.CodeUnformattable)
+	}
+}
+
+// Program writes a program in the canonical layout. Comments are kept next to
+// the statements they were written next to, using the ranges of the
+// statements.
+func Program(w io.Writer, program *ast.Program) error {
+	return Node(w, program)
+}
+
+// Node writes any node of an ast in the canonical layout, such as a program
+// built by a code generator. Statements are written as at the top level, with
+// their line breaks, and expressions without one. Literals without the text
+// of their token are written from their values, and nodes without ranges are
+// written without blank lines between them, except at the top level.
+func Node(w io.Writer, node ast.Node) error {
+	p := &printer{}
+	switch node := node.(type) {
+	case *ast.Program:
+		p.program(node)
+	case *ast.ScopeStatement:
+		p.stmts(node.Stmts, nil)
+	case ast.Stmt:
+		p.stmt(node, nil)
+	case ast.Expr:
+		p.b.WriteString(p.expr(node, parser.LOWEST))
+	}
+	_, err := io.WriteString(w, p.b.String())
+	return err
+}
+
+// Check reports whether the source of a file is formatted. Files which can
+// not be parsed are reported with their errors.
+func Check(file *util.File) (bool, util.ErrorList) {
+	formatted, errs := Source(file)
+	if errs != nil {
+		return false, errs
+	}
+	return string(formatted) == string(file.Source()), nil
+}
+
+type printer struct {
+	b     strings.Builder
+	depth int
+}
+
+func (p *printer) program(program *ast.Program) {
+	for i, stmt := range program.Stmts {
+		if i > 0 {
+			prev := program.Stmts[i-1]
+			if !isComment(prev) && !isComment(stmt) || apart(prev, stmt) {
+				p.b.WriteString("\n")
+			}
+		}
+		p.stmt(stmt, nil)
+	}
+}
+
+// stmt writes a statement, and its line break. The block is the innermost
+// block holding the statement, or nil at the top level.
+func (p *printer) stmt(stmt ast.Stmt, block *ast.BlockStatement) {
+	switch stmt := stmt.(type) {
+	case *ast.HeadingStatement:
+		p.lines(strings.Repeat("_ ", stmt.Depth) + stmt.Value)
+	case *ast.ParagraphStatement:
+		p.lines(stmt.Value)
+	case *ast.CommentStatement:
+		for _, line := range strings.Split(stmt.Value, "\n") {
+			p.line("#" + line)
+		}
+	case *ast.BlockStatement:
+		head := string(stmt.Token.Type)
+		if stmt.Ident != nil {
+			head += " " + stmt.Ident.Value
+		}
+		p.line(head + " {")
+		p.depth++
+		p.stmts(stmt.Stmts, stmt)
+		p.depth--
+		p.line("}")
+	case *ast.ExpressionStatement:
+		p.line(p.statementExpr(stmt.Expr, block))
+	case *ast.IfStatement:
+		p.line("if " + p.condition(stmt.Condition) + ":")
+		p.scope(stmt.Block, block)
+	case *ast.ElseStatement:
+		if stmt.Condition == nil {
+			p.line("else:")
+		} else {
+			p.line("else if " + p.condition(stmt.Condition) + ":")
+		}
+		p.scope(stmt.Block, block)
+	case *ast.ForStatement:
+		p.line("for " + stmt.Ident.Value + " in " + p.condition(stmt.Iter) + ":")
+		p.scope(stmt.Block, block)
+	case *ast.ScopeStatement:
+		p.stmts(stmt.Stmts, block)
+	}
+}
+
+// stmts writes the statements of a block or scope, keeping a blank line
+// wherever there was at least one.
+func (p *printer) stmts(stmts []ast.Stmt, block *ast.BlockStatement) {
+	for i, stmt := range stmts {
+		if i > 0 && apart(stmts[i-1], stmt) {
+			p.b.WriteString("\n")
+		}
+		p.stmt(stmt, block)
+	}
+}
+
+func (p *printer) scope(scope *ast.ScopeStatement, block *ast.BlockStatement) {
+	p.depth++
+	p.stmts(scope.Stmts, block)
+	p.depth--
+}
+
+// statementExpr returns the expression of a statement. The members of an
+// @enum are written as a list.
+func (p *printer) statementExpr(exp ast.Expr, block *ast.BlockStatement) string {
+	if prefix, ok := exp.(*ast.PrefixExpression); ok && block != nil && block.Token.Type == token.ENUM {
+		return prefix.Operator + " " + p.expr(prefix.Right, parser.PREFIX)
+	}
+	return p.expr(exp, parser.LOWEST)
+}
+
+// condition returns the expression before the ":" of an if, else or for
+// statement, where a declaration would need parentheses.
+func (p *printer) condition(exp ast.Expr) string {
+	return p.expr(exp, parser.ASSIGN+1)
+}
+
+// expr returns an expression which binds at least as tightly as precedence,
+// in parentheses if it would not otherwise.
+func (p *printer) expr(exp ast.Expr, precedence int) string {
+	var s string
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return exp.Value
+	case *ast.Condition:
+		if exp.Value {
+			return string(token.TRUE)
+		}
+		return string(token.FALSE)
+	case *ast.Unknown:
+		return string(token.UNKNOWN)
+	case *ast.TextLiteral:
+		return "`" + exp.Value + "`"
+	case *ast.PeriodLiteral:
+		if exp.Token.Literal == "" {
+			return fmt.Sprintf("%d %s", exp.Value, exp.Symbol)
+		}
+		return strings.Join(strings.Fields(exp.Token.Literal), " ")
+	case *ast.IntegerLiteral:
+		return literal(exp.Token, strconv.Itoa(exp.Value))
+	case *ast.DecimalLiteral:
+		value := number(exp.Value)
+		if !strings.Contains(value, ".") {
+			value += ".0"
+		}
+		return literal(exp.Token, value)
+	case *ast.MoneyLiteral:
+		return literal(exp.Token, exp.Symbol+number(exp.Value))
+	case *ast.PercentLiteral:
+		return literal(exp.Token, number(exp.Value)+"%")
+	case *ast.DateLiteral:
+		return literal(exp.Token, fmt.Sprintf("|%04d/%02d/%02d|", exp.Year, exp.Month, exp.Day))
+	case *ast.TimeLiteral:
+		return literal(exp.Token, fmt.Sprintf("|%02d:%02d:%02d|", exp.Hours, exp.Minutes, exp.Seconds))
+	case *ast.MemberExpression:
+		return p.expr(exp.Left, parser.MEMBER) + "." + exp.Ident.Value
+	case *ast.ListType:
+		s = p.expr(exp.Elem, parser.POSTFIX) + " " + string(token.LIST)
+	case *ast.PrefixExpression:
+		// An operand starting with the same operator keeps its parentheses,
+		// since "--1" would not be read as "-(-1)".
+		right := p.expr(exp.Right, parser.PREFIX)
+		if strings.HasPrefix(right, exp.Operator) {
+			right = "(" + right + ")"
+		}
+		s = exp.Operator + right
+	case *ast.InfixExpression:
+		// Operators are left-associative, so an operand on the right with the
+		// same precedence needs parentheses.
+		op := parser.Precedence(token.Type(exp.Operator))
+		s = p.expr(exp.Left, op) + " " + exp.Operator + " " + p.expr(exp.Right, op+1)
+	case *ast.DeclareExpression:
+		s = exp.Ident.Value + ": " + p.expr(exp.Value, parser.OR+1)
+		if exp.Default != nil {
+			s += " " + string(token.OR) + " " + p.expr(exp.Default, parser.OR+1)
+		}
+	case *ast.SetExpression:
+		s = string(token.SET) + " " + exp.Ident.Value + " " + string(token.TO) + " " +
+			p.expr(exp.Value, parser.LOWEST)
+	}
+
+	if binding(exp) < precedence {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// literal returns the text of a literal's token, or value if the literal was
+// not scanned from source.
+func literal(tok token.Token, value string) string {
+	if tok.Literal == "" {
+		return value
+	}
+	return tok.Literal
+}
+
+func number(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', -1, 32)
+}
+
+// binding returns how tightly an expression binds, which is the precedence
+// of its operator.
+func binding(exp ast.Expr) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(token.Type(exp.Operator))
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.ListType:
+		return parser.POSTFIX
+	case *ast.DeclareExpression:
+		return parser.ASSIGN
+	case *ast.SetExpression:
+		return parser.LOWEST
+	}
+	return parser.MEMBER + 1
+}
+
+// line writes a line at the current depth.
+func (p *printer) line(text string) {
+	p.b.WriteString(strings.Repeat(indent, p.depth))
+	p.b.WriteString(strings.TrimRight(text, " \t\r"))
+	p.b.WriteString("\n")
+}
+
+// lines writes text of several lines, without their trailing whitespace.
+func (p *printer) lines(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		p.line(line)
+	}
+}
+
+// apart reports whether there is a blank line between two statements.
+func apart(prev, next ast.Stmt) bool {
+	return next.Range().Start.Line > prev.Range().End.Line+1
+}
+
+func isComment(stmt ast.Stmt) bool {
+	_, ok := stmt.(*ast.CommentStatement)
+	return ok
+}
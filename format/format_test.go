@@ -0,0 +1,208 @@
+package format_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/format"
+	"github.com/policyscript/policyscript/parser"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/util"
+)
+
+var _ = Describe("Format", func() {
+	util.Each("formats expression", [][2]string{
+		{"a+b*c", "a + b * c"},
+		{"(a + b) * c", "(a + b) * c"},
+		{"a - (b - c)", "a - (b - c)"},
+		{"(a - b) - c", "a - b - c"},
+		{"-(a + b)", "-(a + b)"},
+		{"-(-1)", "-(-1)"},
+		{"- -a", "-(-a)"},
+		{"-(-(-a))", "-(-(-a))"},
+		{"(a.b).c", "a.b.c"},
+		{"(a < b) and (b < c or d)", "a < b and (b < c or d)"},
+		{"4   days", "4 days"},
+		{"$5_000.50 * 15%", "$5_000.50 * 15%"},
+		{"|2021/01/15|", "|2021/01/15|"},
+		{"`A  b`", "`A  b`"},
+	}, func(input, expects string) {
+		Expect(formatted("@code {\n  set value to " + input + "\n}\n")).To(
+			Equal("@code {\n  set value to " + expects + "\n}\n"))
+	})
+
+	util.Each("formats markup", [][2]string{
+		{"_  _ (a) A  \n\n\n\nSome   text.  \n  More.", "_ _ (a) A\n\nSome   text.\n  More.\n"},
+		{"# A\n_ B\n\n# C\n\nD\n# E", "# A\n_ B\n\n# C\n\nD\n# E\n"},
+		{"@inputs {\n}\n_ A", "@inputs {\n}\n\n_ A\n"},
+		{"", ""},
+	}, func(input, expects string) {
+		Expect(formatted(input)).To(Equal(expects))
+	})
+
+	util.Each("formats block", [][2]string{
+		{"@define   Person{\n  # Age.   \n  age :integer\n\n\n  kids:Person   list\n}",
+			"@define Person {\n  # Age.\n  age: integer\n\n  kids: Person list\n}\n"},
+		{"@enum Age {\n  -young\n  -   old\n}", "@enum Age {\n  - young\n  - old\n}\n"},
+		{"@inputs {\n  rate:percent   or(10% + 5%)\n  sold : date or  unknown\n}",
+			"@inputs {\n  rate: percent or 10% + 5%\n  sold: date or unknown\n}\n"},
+		{"@code {\n  if  a>1 :\n      set b to 1\n\n      set c to 2\n  else if (a = 1):\n    set b to 2\n  else:\n" +
+			"   # None.\n   set b to 3\n}",
+			"@code {\n  if a > 1:\n    set b to 1\n\n    set c to 2\n  else if a = 1:\n    set b to 2\n  else:\n" +
+				"    # None.\n    set b to 3\n}\n"},
+		{"@code {\n  for a in (b.c):\n    for d in a:\n     set e to d\n}",
+			"@code {\n  for a in b.c:\n    for d in a:\n      set e to d\n}\n"},
+	}, func(input, expects string) {
+		Expect(formatted(input)).To(Equal(expects))
+	})
+
+	It("is idempotent and keeps every statement", func() {
+		inputs := []string{
+			"# A\n_ (a) B  \n\nText\n# C\n\n@code {\n  if (a or b) and c:\n\n\n    set d to -(e - f)\n}\n",
+			"@meta {\n  set path      to `121`\n}\n@enum A {\n  -b\n}\n",
+			"Intro.\n\n@todo\nCheck the rates.\n",
+			"@todo   \n\n\n@unknown words\n",
+		}
+		files, err := filepath.Glob("../*/*/*.law")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).NotTo(BeEmpty())
+		for _, name := range files {
+			if filepath.Base(name) == "bad.law" {
+				continue
+			}
+			source, err := ioutil.ReadFile(name)
+			Expect(err).NotTo(HaveOccurred())
+			inputs = append(inputs, string(source))
+		}
+
+		for _, input := range inputs {
+			once := formatted(input)
+			Expect(formatted(once)).To(Equal(once))
+			Expect(statements(once)).To(Equal(statements(input)))
+		}
+	})
+
+	It("keeps paragraphs which start like a block keyword", func() {
+		Expect(formatted("Intro.\n\n@todo\nCheck the rates.\n")).To(
+			Equal("Intro.\n\n@todo\nCheck the rates.\n"))
+	})
+
+	util.Each("does not format paragraphs which would be read differently", [][2]string{
+		{"@code {\n  set a to 1\n}@outputs {\n  b: integer\n}\n", `"@outputs {" would not be read as a paragraph at the start of a line`},
+		{"@code {\n  set a to 1\n}_ A\n", `"_ A" would not be read as a paragraph at the start of a line`},
+	}, func(input, expects string) {
+		out, errs := format.Source(util.NewFile("a.law", []byte(input)))
+		Expect(out).To(BeNil())
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Code).To(Equal(util.CodeUnformattable))
+		Expect(errs[0].Msg).To(Equal(expects))
+	})
+
+	It("writes programs", func() {
+		var b bytes.Buffer
+		Expect(format.Program(&b, &ast.Program{Stmts: []ast.Stmt{
+			&ast.HeadingStatement{Depth: 1, Value: "A"},
+			&ast.BlockStatement{Token: token.Token{Type: token.OUTPUTS}, Stmts: []ast.Stmt{
+				&ast.ExpressionStatement{Expr: &ast.DeclareExpression{
+					Ident: &ast.Identifier{Value: "b"},
+					Value: &ast.ListType{Elem: &ast.Identifier{Value: "text"}},
+				}},
+			}},
+		}})).To(Succeed())
+		Expect(b.String()).To(Equal("_ A\n\n@outputs {\n  b: text list\n}\n"))
+	})
+
+	It("writes any node", func() {
+		ident := func(name string) *ast.Identifier { return &ast.Identifier{Value: name} }
+		infix := func(left ast.Expr, op string, right ast.Expr) ast.Expr {
+			return &ast.InfixExpression{Left: left, Operator: op, Right: right}
+		}
+
+		for _, test := range []struct {
+			node    ast.Node
+			expects string
+		}{
+			{infix(infix(ident("a"), "+", ident("b")), "*", ident("c")), "(a + b) * c"},
+			{infix(ident("a"), "-", infix(ident("b"), "-", ident("c"))), "a - (b - c)"},
+			{infix(ident("a"), "or", infix(ident("b"), "and", ident("c"))), "a or b and c"},
+			{&ast.PrefixExpression{Operator: "-", Right: infix(ident("a"), "*", ident("b"))}, "-(a * b)"},
+			{&ast.MemberExpression{Left: infix(ident("a"), "+", ident("b")), Ident: ident("c")}, "(a + b).c"},
+			{&ast.IntegerLiteral{Value: 12}, "12"},
+			{&ast.DecimalLiteral{Value: 2}, "2.0"},
+			{&ast.MoneyLiteral{Value: 1.5, Symbol: "$"}, "$1.5"},
+			{&ast.PercentLiteral{Value: 15}, "15%"},
+			{&ast.PeriodLiteral{Value: 3, Symbol: "days"}, "3 days"},
+			{&ast.DateLiteral{Year: 2021, Month: 1, Day: 5}, "|2021/01/05|"},
+			{&ast.TimeLiteral{Hours: 9, Minutes: 30}, "|09:30:00|"},
+			{&ast.Condition{Value: true}, "true"},
+			{&ast.ExpressionStatement{Expr: &ast.SetExpression{Ident: ident("a"), Value: &ast.TextLiteral{Value: "b"}}},
+				"set a to `b`\n"},
+			{&ast.IfStatement{Condition: ident("a"), Block: &ast.ScopeStatement{Stmts: []ast.Stmt{
+				&ast.ExpressionStatement{Expr: &ast.SetExpression{Ident: ident("b"), Value: ident("c")}},
+			}}}, "if a:\n  set b to c\n"},
+		} {
+			var b bytes.Buffer
+			Expect(format.Node(&b, test.node)).To(Succeed())
+			Expect(b.String()).To(Equal(test.expects))
+
+			// Expressions are written as they would be parsed.
+			if _, ok := test.node.(ast.Expr); ok {
+				source := "@code {\n  set value to " + b.String() + "\n}\n"
+				Expect(formatted(source)).To(Equal(source))
+			}
+		}
+	})
+
+	It("checks files", func() {
+		ok, errs := format.Check(util.NewFile("a.law", []byte("@inputs {\n  a: integer\n}\n")))
+		Expect(errs).To(BeEmpty())
+		Expect(ok).To(BeTrue())
+
+		ok, _ = format.Check(util.NewFile("a.law", []byte("@inputs {\n  a : integer\n}\n")))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("does not format files with errors", func() {
+		out, errs := format.Source(util.NewFile("a.law", []byte("@code {\n  set a to\n}\n")))
+		Expect(out).To(BeNil())
+		Expect(errs).NotTo(BeEmpty())
+
+		out, errs = format.Source(util.NewFile("a.law", []byte("@code {\n  set a to 1 # one\n}\n")))
+		Expect(out).To(BeNil())
+		Expect(errs[0].Msg).To(Equal("a comment must be on a line of its own"))
+
+		ok, errs := format.Check(util.NewFile("a.law", []byte("@code {\n")))
+		Expect(ok).To(BeFalse())
+		Expect(errs[0].Code).To(Equal(util.CodeUnclosedBlock))
+	})
+})
+
+// statements returns the kinds of the top level statements of a source, which
+// must parse.
+func statements(input string) []string {
+	var errs util.ErrorList
+	s := scanner.New(util.NewFile("", []byte(input)), func(err *util.Error) {
+		errs = append(errs, err)
+	})
+	p := parser.New(*s)
+	program := p.ParseProgram()
+	Expect(append(errs, p.Errors()...)).To(BeEmpty())
+
+	var kinds []string
+	for _, stmt := range program.Stmts {
+		kinds = append(kinds, fmt.Sprintf("%T", stmt))
+	}
+	return kinds
+}
+
+func formatted(input string) string {
+	out, errs := format.Source(util.NewFile("", []byte(input)))
+	Expect(errs).To(BeEmpty())
+	return string(out)
+}
@@ -0,0 +1,28 @@
+import {Exports, PolicyScript} from './policyscript';
+
+// Exports which echo their requests, in place of the module.
+function echo(name: string): (request: string) => string {
+	return (request: string): string => JSON.stringify({diagnostics: [], [name]: JSON.parse(request)});
+}
+
+const exports: Exports = {
+	scan: echo('tokens'),
+	parse: echo('program'),
+	check: echo('check'),
+	evaluate: echo('outputs'),
+	render: echo('markdown'),
+};
+
+describe('PolicyScript', () => {
+	it('sends requests as JSON and reads the responses', () => {
+		const policyscript = new PolicyScript(exports);
+		const request = {filename: 'a.law', source: '@inputs {}', inputs: {age: 3}, trace: true};
+		expect(policyscript.evaluate(request)).toEqual({diagnostics: [], outputs: request});
+		expect(policyscript.scan({source: '_ A'})).toEqual({diagnostics: [], tokens: {source: '_ A'}});
+	});
+
+	it('throws the errors of the module', () => {
+		const policyscript = new PolicyScript({...exports, check: () => '{"error": "invalid request"}'});
+		expect(() => policyscript.check({source: ''})).toThrow('policyscript: invalid request');
+	});
+});
@@ -0,0 +1,177 @@
+/**
+ * Typings and a wrapper for the WebAssembly build of PolicyScript, so that the
+ * playground runs the real scanner, parser, checker and evaluator.
+ *
+ * Build the module with `make wasm`, which also copies the `wasm_exec.js` of
+ * the Go distribution next to it. Load `wasm_exec.js` before calling `load`,
+ * since it defines the global `Go` class which runs the module.
+ */
+
+export interface Position {
+	filename?: string;
+	/** 1-indexed line number. */
+	line: number;
+	/** 0-indexed column, counted in runes. */
+	column: number;
+	/** 0-indexed offset, counted in runes. */
+	offset: number;
+	/** 0-indexed offset, counted in bytes of UTF-8. */
+	byteOffset: number;
+}
+
+export interface Range {
+	start: Position;
+	end: Position;
+}
+
+export type Severity = 'error' | 'warning' | 'info' | 'hint';
+
+export interface Edit {
+	range: Range;
+	newText: string;
+}
+
+export interface Diagnostic {
+	message: string;
+	range: Range;
+	severity: Severity;
+	/** Stable code of the diagnostic, ex: "PS4006". */
+	code?: string;
+	related?: {message: string; range: Range}[];
+	fixes?: {title: string; edits: Edit[]}[];
+}
+
+export interface Token {
+	type: string;
+	literal: string;
+	range: Range;
+}
+
+/**
+ * A node of the syntax tree. Every node has a kind, such as "IfStatement", and
+ * a range. Its other fields depend on its kind, and are those of the Go type of
+ * the same name, with the first letter in lower case.
+ */
+export interface Node {
+	kind: string;
+	range: Range;
+	[field: string]: unknown;
+}
+
+/** The versioned encoding of a syntax tree. */
+export interface Tree {
+	version: number;
+	node: Node;
+}
+
+/**
+ * The value of an input or output, as in JSON inputs: money, percentages,
+ * periods, dates and times are text, ex: "$1,000.50", "15%", "2 years",
+ * "2021-01-15" and "23:59:59", groups are objects and enum members are text.
+ */
+export type Value = string | number | boolean | Value[] | {[field: string]: Value};
+
+export interface Step {
+	kind: string;
+	range: Range;
+	citation?: string;
+	name?: string;
+	value?: string;
+	old?: string;
+	steps?: Step[];
+}
+
+export interface Response {
+	diagnostics: Diagnostic[];
+}
+
+export interface ScanResponse extends Response {
+	tokens?: Token[];
+}
+
+export interface ParseResponse extends Response {
+	program?: Tree;
+}
+
+export interface EvaluateResponse extends Response {
+	/** Missing if the file has errors. */
+	outputs?: {[name: string]: Value};
+	/** The path of the heading above where each output was set, ex: "121(a)". */
+	citations?: {[name: string]: string};
+	trace?: {steps: Step[]};
+}
+
+export interface RenderResponse extends Response {
+	markdown?: string;
+}
+
+export interface Request {
+	/** Name of the file, given in diagnostics. */
+	filename?: string;
+	source: string;
+	/** Values of the inputs of evaluate, by name. */
+	inputs?: {[name: string]: Value};
+	/** Whether evaluate explains how each output was computed. */
+	trace?: boolean;
+}
+
+/** The functions set on the global `policyscript` object by the module. */
+export interface Exports {
+	scan(request: string): string;
+	parse(request: string): string;
+	check(request: string): string;
+	evaluate(request: string): string;
+	render(request: string): string;
+}
+
+/** Wraps the functions of the module with typed requests and responses. */
+export class PolicyScript {
+	constructor(private readonly exports: Exports) {}
+
+	scan(request: Request): ScanResponse {
+		return call(this.exports.scan, request);
+	}
+
+	parse(request: Request): ParseResponse {
+		return call(this.exports.parse, request);
+	}
+
+	check(request: Request): Response {
+		return call(this.exports.check, request);
+	}
+
+	evaluate(request: Request): EvaluateResponse {
+		return call(this.exports.evaluate, request);
+	}
+
+	render(request: Request): RenderResponse {
+		return call(this.exports.render, request);
+	}
+}
+
+/**
+ * Runs the module and returns its functions. The module keeps running, so it
+ * only needs to be loaded once.
+ */
+export async function load(bytes: ArrayBuffer | Uint8Array): Promise<PolicyScript> {
+	const scope = globalThis as any;
+	if (typeof scope.Go !== 'function') {
+		throw new Error('policyscript: load wasm_exec.js before the module');
+	}
+
+	const go = new scope.Go();
+	const {instance} = await scope.WebAssembly.instantiate(bytes, go.importObject);
+	go.run(instance);
+	if (!scope.policyscript) {
+		throw new Error('policyscript: the module did not set its functions');
+	}
+	return new PolicyScript(scope.policyscript);
+}
+
+function call<T extends Response>(f: (request: string) => string, request: Request): T {
+	const response = JSON.parse(f(JSON.stringify(request)));
+	if (response.error) {
+		throw new Error(`policyscript: ${response.error}`);
+	}
+	return response;
+}
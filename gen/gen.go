@@ -0,0 +1,82 @@
+// Package gen generates code from the declarations of a policy, so that
+// programs which evaluate it use its @define and @enum types and its inputs
+// and outputs by name, and fail to build when they change.
+package gen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/policyscript/policyscript/evaluator"
+	"github.com/policyscript/policyscript/types"
+)
+
+// named returns the types declared with @define and @enum, in order of name.
+func named(info *types.Info) []types.Type {
+	names := make([]string, 0, len(info.Named))
+	for name := range info.Named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	named := make([]types.Type, len(names))
+	for i, name := range names {
+		named[i] = info.Named[name]
+	}
+	return named
+}
+
+// defaultOf returns the default of an optional input in the form written by
+// types.Encode, or false if it has none or it is unknown.
+func defaultOf(info *types.Info, field *types.Field) (interface{}, bool) {
+	if field.Default == nil {
+		return nil, false
+	}
+	enums := map[string]map[string]bool{}
+	for name, t := range info.Named {
+		if enum, ok := t.(*types.Enum); ok {
+			enums[name] = map[string]bool{}
+			for _, member := range enum.Members {
+				enums[name][member] = true
+			}
+		}
+	}
+	value, err := evaluator.Constant(field.Default, enums)
+	if err != nil || value.Type() == types.Unknown.Kind() {
+		return nil, false
+	}
+	return types.Encode(value), true
+}
+
+// camel converts a name to camel case with a leading capital, ex:
+// "countries_lived_in" to "CountriesLivedIn".
+func camel(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// unique returns an error if two names are the same, where what tells what
+// each name is, ex: "type Person".
+type unique map[string]string
+
+func (u unique) add(name, what string) error {
+	if other, ok := u[name]; ok {
+		return fmt.Errorf("gen: %s and %s are both named %s", other, what, name)
+	}
+	u[name] = what
+	return nil
+}
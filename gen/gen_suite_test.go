@@ -0,0 +1,13 @@
+package gen_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestGen(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Gen Suite")
+}
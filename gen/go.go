@@ -0,0 +1,219 @@
+package gen
+
+import (
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/policyscript/policyscript/types"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Go generates a Go package named pkg with a struct for each @define type, a
+// string type with a constant for each member of each @enum, an Inputs and an
+// Outputs struct, and a function which evaluates the policy:
+//
+//	func Evaluate(ctx context.Context, inputs Inputs) (Outputs, error)
+//
+// The source of the files is part of the package, which compiles it and binds
+// it to Inputs and Outputs on the first call. Fields are tagged for
+// policyscript.Binding, and are given the types it binds to, with money as
+// policyscript.Money, decimals and percentages as float64 and periods as
+// policyscript.Period.
+// Optional inputs are pointers, which are nil for their defaults.
+func Go(files *util.FileSet, info *types.Info, pkg string) ([]byte, error) {
+	g := &goGen{names: unique{}}
+	for _, name := range []string{"Inputs", "Outputs", "Evaluate"} {
+		g.names.add(name, "generated "+name)
+	}
+
+	var body strings.Builder
+	for _, t := range named(info) {
+		if err := g.named(&body, t); err != nil {
+			return nil, err
+		}
+	}
+	g.fields(&body, "Inputs", "", "Inputs are the @inputs of the policy.", info.Inputs)
+	g.fields(&body, "Outputs", "", "Outputs are the @outputs of the policy, which are left as their zero\n"+
+		"value if the policy does not set them. If one is unknown, Evaluate fails\n"+
+		"with the inputs it needs.", info.Outputs)
+	g.evaluate(&body, files)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by \"policyscript gen go\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\nimport (\n\t\"context\"\n\t\"sync\"\n", pkg)
+	if g.time {
+		b.WriteString("\t\"time\"\n")
+	}
+	b.WriteString("\n\t\"github.com/policyscript/policyscript\"\n" +
+		"\t\"github.com/policyscript/policyscript/util\"\n)\n")
+	b.WriteString(body.String())
+
+	source, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("gen: %s", err)
+	}
+	return source, nil
+}
+
+type goGen struct {
+	names unique
+
+	// Whether the package imports time.
+	time bool
+}
+
+func (g *goGen) named(b *strings.Builder, t types.Type) error {
+	switch t := t.(type) {
+	case *types.Enum:
+		name := camel(t.Name)
+		if err := g.names.add(name, "@enum "+t.Name); err != nil {
+			return err
+		}
+		b.WriteString("\n")
+		comment(b, "", t.Doc, fmt.Sprintf("%s is the @enum %s.", name, t.Name))
+		fmt.Fprintf(b, "type %s string\n\n", name)
+		if len(t.Members) == 0 {
+			return nil
+		}
+		fmt.Fprintf(b, "// Members of %s.\nconst (\n", name)
+		for _, member := range t.Members {
+			constant := name + camel(member)
+			if err := g.names.add(constant, fmt.Sprintf("%s.%s", t.Name, member)); err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "\t%s %s = %q\n", constant, name, member)
+		}
+		b.WriteString(")\n")
+
+	case *types.Group:
+		name := camel(t.Name)
+		if err := g.names.add(name, "@define "+t.Name); err != nil {
+			return err
+		}
+		g.fields(b, name, t.Doc, fmt.Sprintf("%s is the @define %s.", name, t.Name), t.Fields)
+	}
+	return nil
+}
+
+// fields writes a struct with a field for each declared field, commented
+// with doc, or fallback if there is no documentation.
+func (g *goGen) fields(b *strings.Builder, name, doc, fallback string, fields []*types.Field) {
+	b.WriteString("\n")
+	comment(b, "", doc, fallback)
+	fmt.Fprintf(b, "type %s struct {\n", name)
+	for i, field := range fields {
+		if i > 0 && field.Doc != "" {
+			b.WriteString("\n")
+		}
+		comment(b, "\t", field.Doc, "")
+		t := g.goType(field.Type)
+		if field.Default != nil {
+			t = "*" + t
+		}
+		fmt.Fprintf(b, "\t%s %s `law:%q`\n", camel(field.Name), t, field.Name)
+	}
+	b.WriteString("}\n")
+}
+
+// goType returns the Go type which a type is bound to.
+func (g *goGen) goType(t types.Type) string {
+	switch t := t.(type) {
+	case *types.List:
+		return "[]" + g.goType(t.Elem)
+	case *types.Group:
+		return camel(t.Name)
+	case *types.Enum:
+		return camel(t.Name)
+	}
+
+	switch t {
+	case types.Text:
+		return "string"
+	case types.Integer:
+		return "int"
+	case types.Decimal, types.Percent:
+		return "float64"
+	case types.Money:
+		return "policyscript.Money"
+	case types.Condition:
+		return "bool"
+	case types.Date, types.Time:
+		g.time = true
+		return "time.Time"
+	case types.Period:
+		return "policyscript.Period"
+	}
+	return "interface{}"
+}
+
+// evaluate writes the source of the files, and Evaluate.
+func (g *goGen) evaluate(b *strings.Builder, files *util.FileSet) {
+	b.WriteString("\n// sources are the files of the policy.\nvar sources = []struct{ name, source string }{\n")
+	for _, file := range files.Files() {
+		fmt.Fprintf(b, "\t{%q, ", filepath.Base(file.Name()))
+		lines := strings.SplitAfter(string(file.Source()), "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		if len(lines) == 0 {
+			lines = []string{""}
+		}
+		for i, line := range lines {
+			if i > 0 {
+				b.WriteString(" +\n\t\t")
+			}
+			b.WriteString(strconv.Quote(line))
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("}\n")
+
+	b.WriteString(`
+var policy struct {
+	once    sync.Once
+	binding *policyscript.Binding
+	err     error
+}
+
+// Evaluate runs the policy with inputs, and returns its outputs. Errors are
+// those of policyscript.Binding.
+func Evaluate(ctx context.Context, inputs Inputs) (Outputs, error) {
+	policy.once.Do(func() {
+		files := util.NewFileSet()
+		for _, file := range sources {
+			files.AddFile(file.name, []byte(file.source))
+		}
+		compiled, err := policyscript.Compile(files)
+		if err != nil {
+			policy.err = err
+			return
+		}
+		policy.binding, policy.err = compiled.Bind(Inputs{}, Outputs{})
+	})
+
+	var outputs Outputs
+	if policy.err != nil {
+		return outputs, policy.err
+	}
+	err := policy.binding.Evaluate(ctx, inputs, &outputs)
+	return outputs, err
+}
+`)
+}
+
+// comment writes a comment with the lines of doc, or of fallback if doc is
+// empty, indented by indent.
+func comment(b *strings.Builder, indent, doc, fallback string) {
+	if doc == "" {
+		doc = fallback
+	}
+	if doc == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSpace(doc), "\n") {
+		b.WriteString(strings.TrimRight(indent+"// "+line, " ") + "\n")
+	}
+}
@@ -0,0 +1,92 @@
+package gen_test
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript"
+	"github.com/policyscript/policyscript/gen"
+	"github.com/policyscript/policyscript/gen/testdata/benefits"
+	"github.com/policyscript/policyscript/util"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var _ = Describe("Go", func() {
+	It("generates types and Evaluate", func() {
+		files, policy := compile("testdata/benefits.law")
+		code, err := gen.Go(files, policy.Info(), "benefits")
+		Expect(err).NotTo(HaveOccurred())
+		if *update {
+			Expect(ioutil.WriteFile("testdata/benefits/benefits.go", code, 0644)).To(Succeed())
+		}
+		golden, err := ioutil.ReadFile("testdata/benefits/benefits.go")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(code)).To(Equal(string(golden)))
+	})
+
+	It("generates code which evaluates the policy", func() {
+		rate, moved := 10.0, date(2010, time.May, 1)
+		inputs := benefits.Inputs{
+			Applicant: benefits.Person{
+				Status:   benefits.StatusCommonLaw,
+				Salary:   40_000_00,
+				Children: []benefits.Child{{Name: "Ann", Born: date(2015, time.March, 1)}},
+			},
+			Today: date(2021, time.January, 1),
+			Rate:  &rate,
+			Moved: &moved,
+		}
+		outputs, err := benefits.Evaluate(context.Background(), inputs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outputs).To(Equal(benefits.Outputs{
+			Eligible: true,
+			Duration: policyscript.Period{Years: 1},
+			Amount:   4_000_00,
+			Children: 1,
+			Resident: true,
+		}))
+
+		// Optional inputs which are nil have their defaults, and outputs
+		// which are unknown are errors.
+		inputs.Rate, inputs.Moved = nil, nil
+		outputs, err = benefits.Evaluate(context.Background(), inputs)
+		Expect(err).To(MatchError(ContainSubstring("resident is undetermined: need moved")))
+		Expect(err.(*policyscript.Error).Diagnostics[0].Code).To(Equal(util.CodeUndetermined))
+		Expect(outputs.Amount).To(Equal(policyscript.Money(2_000_00)))
+
+		_, err = benefits.Evaluate(context.Background(), benefits.Inputs{
+			Applicant: benefits.Person{Status: "divorced"},
+		})
+		Expect(err).To(MatchError(ContainSubstring(`expected one of single, married, common_law, got "divorced"`)))
+	})
+
+	It("reports names which are the same in Go", func() {
+		files := util.NewFileSet()
+		files.AddFile("inputs.law", []byte("@define Inputs {\n  a: text\n}\n"))
+		policy, err := policyscript.Compile(files)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = gen.Go(files, policy.Info(), "policy")
+		Expect(err).To(MatchError("gen: generated Inputs and @define Inputs are both named Inputs"))
+	})
+})
+
+func compile(paths ...string) (*util.FileSet, *policyscript.Policy) {
+	files := util.NewFileSet()
+	for _, path := range paths {
+		source, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		files.AddFile(path, source)
+	}
+	policy, err := policyscript.Compile(files)
+	Expect(err).NotTo(HaveOccurred())
+	return files, policy
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
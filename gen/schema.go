@@ -0,0 +1,263 @@
+package gen
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/policyscript/policyscript/types"
+)
+
+// Draft is the version of JSON Schema written by JSONSchema, which OpenAPI
+// 3.1 also uses.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Patterns of the values which are written as text, as read by types.Decode.
+const (
+	moneyPattern   = `^-?[^0-9-]?[0-9][0-9,_]*(\.[0-9]+)?$`
+	percentPattern = `^-?[0-9]+(\.[0-9]+)?%?$`
+	timePattern    = `^[0-9]{2}:[0-9]{2}(:[0-9]{2})?$`
+	periodUnit     = `-?[0-9]+ (years?|months?|days?|hours?|minutes?|seconds?)`
+	periodPattern  = `^` + periodUnit + `((,| and|, and)? ` + periodUnit + `)*$`
+)
+
+// schema is a JSON Schema, or an OpenAPI document.
+type schema map[string]interface{}
+
+// JSONSchema generates a JSON Schema of the inputs of a policy. The schema
+// of the outputs is in its definitions as "Outputs", along with the @define
+// and @enum types, ex: "#/$defs/Person". Money, percentages, periods and
+// times are strings with a pattern, where money and percentages may also be
+// numbers, and dates are strings of the "date" format. Optional inputs are
+// not required, may be null for unknown, and have their defaults.
+// Documentation comments are descriptions.
+func JSONSchema(info *types.Info) ([]byte, error) {
+	defs, err := definitions(info, "#/$defs/")
+	if err != nil {
+		return nil, err
+	}
+	return encodeSchema(schema{
+		"$schema": Draft,
+		"$ref":    "#/$defs/Inputs",
+		"$defs":   defs,
+	})
+}
+
+// OpenAPI generates an OpenAPI 3.1 document with the title, describing an
+// endpoint which evaluates the policy: "POST /evaluate" with the inputs as
+// its body, which responds with the outputs and the citation of each, as
+// written by "policyscript run -json", or with the diagnostics of inputs
+// which are not valid.
+func OpenAPI(info *types.Info, title string) ([]byte, error) {
+	schemas, err := definitions(info, "#/components/schemas/")
+	if err != nil {
+		return nil, err
+	}
+	for name, s := range diagnosticSchemas {
+		if _, ok := schemas[name]; ok {
+			return nil, fmt.Errorf("gen: generated %s and a type are both named %s", name, name)
+		}
+		schemas[name] = s
+	}
+
+	ref := func(name string) schema {
+		return schema{"$ref": "#/components/schemas/" + name}
+	}
+	content := func(s schema) schema {
+		return schema{"application/json": schema{"schema": s}}
+	}
+	diagnostics := schema{"type": "array", "items": ref("Diagnostic")}
+
+	return encodeSchema(schema{
+		"openapi":           "3.1.0",
+		"jsonSchemaDialect": Draft,
+		"info":              schema{"title": title, "version": "1"},
+		"paths": schema{
+			"/evaluate": schema{
+				"post": schema{
+					"operationId": "evaluate",
+					"summary":     "Evaluate the policy with inputs",
+					"requestBody": schema{"required": true, "content": content(ref("Inputs"))},
+					"responses": schema{
+						"200": schema{
+							"description": "The outputs of the policy.",
+							"content": content(schema{
+								"type":     "object",
+								"required": []string{"outputs", "citations", "diagnostics"},
+								"properties": schema{
+									"outputs": ref("Outputs"),
+									"citations": schema{
+										"description":          "The citation of the section which set each output, ex: \"121(a)\".",
+										"type":                 "object",
+										"additionalProperties": schema{"type": "string"},
+									},
+									"diagnostics": diagnostics,
+								},
+							}),
+						},
+						"422": schema{
+							"description": "The inputs are not valid, or the policy failed while running.",
+							"content": content(schema{
+								"type":       "object",
+								"required":   []string{"diagnostics"},
+								"properties": schema{"diagnostics": diagnostics},
+							}),
+						},
+					},
+				},
+			},
+		},
+		"components": schema{"schemas": schemas},
+	})
+}
+
+// diagnosticSchemas are the schemas of diagnostics, as written by -json.
+var diagnosticSchemas = schema{
+	"Diagnostic": schema{
+		"type":     "object",
+		"required": []string{"message", "range", "severity"},
+		"properties": schema{
+			"message":  schema{"type": "string"},
+			"range":    schema{"$ref": "#/components/schemas/Range"},
+			"severity": schema{"enum": []string{"error", "warning", "info", "hint"}},
+			"code":     schema{"description": "The stable code of the diagnostic, ex: \"PS5004\".", "type": "string"},
+		},
+	},
+	"Range": schema{
+		"type":     "object",
+		"required": []string{"start", "end"},
+		"properties": schema{
+			"start": schema{"$ref": "#/components/schemas/Position"},
+			"end":   schema{"$ref": "#/components/schemas/Position"},
+		},
+	},
+	"Position": schema{
+		"type":     "object",
+		"required": []string{"line", "column", "offset", "byteOffset"},
+		"properties": schema{
+			"filename":   schema{"type": "string"},
+			"line":       schema{"type": "integer"},
+			"column":     schema{"type": "integer"},
+			"offset":     schema{"type": "integer"},
+			"byteOffset": schema{"type": "integer"},
+		},
+	},
+}
+
+// definitions returns the schemas of the @define and @enum types, and of the
+// inputs and outputs, by name, where ref is the prefix of references to them.
+func definitions(info *types.Info, ref string) (schema, error) {
+	names := unique{}
+	for _, name := range []string{"Inputs", "Outputs"} {
+		names.add(name, "generated "+name)
+	}
+
+	defs := schema{}
+	for _, t := range named(info) {
+		switch t := t.(type) {
+		case *types.Enum:
+			if err := names.add(t.Name, "@enum "+t.Name); err != nil {
+				return nil, err
+			}
+			defs[t.Name] = describe(schema{"enum": t.Members}, t.Doc)
+		case *types.Group:
+			if err := names.add(t.Name, "@define "+t.Name); err != nil {
+				return nil, err
+			}
+			defs[t.Name] = describe(object(info, t.Fields, ref, true), t.Doc)
+		}
+	}
+	defs["Inputs"] = describe(object(info, info.Inputs, ref, true), "The @inputs of the policy.")
+	defs["Outputs"] = describe(object(info, info.Outputs, ref, false),
+		"The @outputs of the policy, which are missing if the policy does not set them, "+
+			"and null if they are unknown.")
+	return defs, nil
+}
+
+// object returns the schema of an object with a property for each field. If
+// required is true, every field which is not optional is required, otherwise
+// every field may be null.
+func object(info *types.Info, fields []*types.Field, ref string, required bool) schema {
+	properties := schema{}
+	names := []string{}
+	for _, field := range fields {
+		property := valueSchema(field.Type, ref)
+		if !required || field.Default != nil {
+			property = nullable(property)
+		}
+		if value, ok := defaultOf(info, field); ok {
+			property["default"] = value
+		}
+		if field.Default == nil {
+			names = append(names, field.Name)
+		}
+		properties[field.Name] = describe(property, field.Doc)
+	}
+	s := schema{"type": "object", "properties": properties, "additionalProperties": false}
+	if required {
+		s["required"] = names
+	}
+	return s
+}
+
+// nullable returns a schema which also allows null.
+func nullable(s schema) schema {
+	switch t := s["type"].(type) {
+	case string:
+		s["type"] = []string{t, "null"}
+		return s
+	case []string:
+		s["type"] = append(t, "null")
+		return s
+	}
+	return schema{"anyOf": []schema{s, {"type": "null"}}}
+}
+
+// valueSchema returns the schema of the values of a type.
+func valueSchema(t types.Type, ref string) schema {
+	switch t := t.(type) {
+	case *types.List:
+		return schema{"type": "array", "items": valueSchema(t.Elem, ref)}
+	case *types.Group:
+		return schema{"$ref": ref + t.Name}
+	case *types.Enum:
+		return schema{"$ref": ref + t.Name}
+	}
+
+	switch t {
+	case types.Text:
+		return schema{"type": "string"}
+	case types.Integer:
+		return schema{"type": "integer"}
+	case types.Decimal:
+		return schema{"type": "number"}
+	case types.Condition:
+		return schema{"type": "boolean"}
+	case types.Money:
+		return schema{"type": []string{"string", "number"}, "pattern": moneyPattern, "examples": []string{"$1,000.50"}}
+	case types.Percent:
+		return schema{"type": []string{"string", "number"}, "pattern": percentPattern, "examples": []string{"15%"}}
+	case types.Period:
+		return schema{"type": "string", "pattern": periodPattern, "examples": []string{"2 years 3 months"}}
+	case types.Date:
+		return schema{"type": "string", "format": "date"}
+	case types.Time:
+		return schema{"type": "string", "pattern": timePattern, "examples": []string{"23:59:59"}}
+	}
+	return schema{}
+}
+
+// describe adds a description to a schema, if doc is not empty.
+func describe(s schema, doc string) schema {
+	if doc != "" {
+		s["description"] = doc
+	}
+	return s
+}
+
+func encodeSchema(s schema) ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("gen: %s", err)
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,72 @@
+package gen_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/gen"
+	"github.com/policyscript/policyscript/object"
+	"github.com/policyscript/policyscript/types"
+)
+
+var _ = Describe("JSONSchema", func() {
+	It("generates the schema of the inputs and outputs", func() {
+		_, policy := compile("testdata/benefits.law")
+		code, err := gen.JSONSchema(policy.Info())
+		Expect(err).NotTo(HaveOccurred())
+		golden(code, "testdata/benefits.schema.json")
+	})
+
+	It("has patterns which match the values written as text", func() {
+		_, policy := compile("testdata/benefits.law")
+		code, err := gen.JSONSchema(policy.Info())
+		Expect(err).NotTo(HaveOccurred())
+
+		var doc struct {
+			Defs map[string]struct {
+				Properties map[string]struct{ Pattern string }
+			} `json:"$defs"`
+		}
+		Expect(json.Unmarshal(code, &doc)).To(Succeed())
+		pattern := func(def, property string) *regexp.Regexp {
+			return regexp.MustCompile(doc.Defs[def].Properties[property].Pattern)
+		}
+
+		money := pattern("Person", "salary")
+		for _, value := range []object.Object{object.NewMoney(1000.5, "$"), object.NewMoney(-5, "$")} {
+			Expect(types.Encode(value)).To(MatchRegexp(money.String()))
+		}
+		Expect("$1,000.50").To(MatchRegexp(money.String()))
+		Expect("1_000").To(MatchRegexp(money.String()))
+		Expect("$1.000.50").NotTo(MatchRegexp(money.String()))
+
+		period := pattern("Outputs", "duration")
+		Expect(types.Encode(&object.Period{Years: 1, Months: -2, Seconds: 30})).To(MatchRegexp(period.String()))
+		Expect("2 years, 3 months and 1 day").To(MatchRegexp(period.String()))
+		Expect("2 fortnights").NotTo(MatchRegexp(period.String()))
+
+		Expect("15%").To(MatchRegexp(pattern("Inputs", "rate").String()))
+	})
+})
+
+var _ = Describe("OpenAPI", func() {
+	It("generates a document with an endpoint which evaluates the policy", func() {
+		_, policy := compile("testdata/benefits.law")
+		code, err := gen.OpenAPI(policy.Info(), "Benefits")
+		Expect(err).NotTo(HaveOccurred())
+		golden(code, "testdata/benefits.openapi.json")
+	})
+})
+
+// golden compares code with a golden file, which it rewrites with -update.
+func golden(code []byte, path string) {
+	if *update {
+		Expect(ioutil.WriteFile(path, code, 0644)).To(Succeed())
+	}
+	golden, err := ioutil.ReadFile(path)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(string(code)).To(Equal(string(golden)))
+}
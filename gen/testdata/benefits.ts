@@ -0,0 +1,59 @@
+// Code generated by "policyscript gen ts"; DO NOT EDIT.
+
+/** Money with its symbol and two decimals, ex: "$1000.50" or "-$5.00". */
+export type Money = `$${number}` | `-$${number}`;
+
+/** A percentage, ex: "15%". */
+export type Percent = `${number}%`;
+
+/** A length of time in units, ex: "1 year 6 months" or "10 days". */
+export type Period = `${number} ${string}`;
+
+/** A date as year, month and day, ex: "2021-01-15". */
+export type CalendarDate = `${number}-${number}-${number}`;
+
+/** A time of day as hours, minutes and seconds, ex: "23:59:59". */
+export type TimeOfDay = `${number}:${number}:${number}`;
+
+/** Child is the @define Child. */
+export interface Child {
+	name: string;
+	born: CalendarDate;
+}
+
+/** A person who applies for the benefit. */
+export interface Person {
+	status: Status;
+	born: CalendarDate;
+	/** Yearly salary, before tax. */
+	salary: Money;
+	children: Child[];
+}
+
+/** The members of Status, in order. */
+export const StatusMembers = ['single', 'married', 'common_law'] as const;
+
+/** Status is the @enum Status. */
+export type Status = typeof StatusMembers[number];
+
+/** Inputs are the @inputs of the policy. */
+export interface Inputs {
+	applicant: Person;
+	today: CalendarDate;
+	rate?: Percent | null;
+	/** When the applicant moved here, if known. */
+	moved?: CalendarDate | null;
+}
+
+/**
+ * Outputs are the @outputs of the policy, which are missing if
+ * the policy does not set them, and null if they are unknown.
+ */
+export interface Outputs {
+	eligible?: boolean | null;
+	/** How long the benefit is paid for. */
+	duration?: Period | null;
+	amount?: Money | null;
+	children?: number | null;
+	resident?: boolean | null;
+}
@@ -0,0 +1,144 @@
+// Code generated by "policyscript gen go"; DO NOT EDIT.
+
+package benefits
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/policyscript/policyscript"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Child is the @define Child.
+type Child struct {
+	Name string    `law:"name"`
+	Born time.Time `law:"born"`
+}
+
+// A person who applies for the benefit.
+type Person struct {
+	Status Status    `law:"status"`
+	Born   time.Time `law:"born"`
+
+	// Yearly salary, before tax.
+	Salary   policyscript.Money `law:"salary"`
+	Children []Child            `law:"children"`
+}
+
+// Status is the @enum Status.
+type Status string
+
+// Members of Status.
+const (
+	StatusSingle    Status = "single"
+	StatusMarried   Status = "married"
+	StatusCommonLaw Status = "common_law"
+)
+
+// Inputs are the @inputs of the policy.
+type Inputs struct {
+	Applicant Person    `law:"applicant"`
+	Today     time.Time `law:"today"`
+	Rate      *float64  `law:"rate"`
+
+	// When the applicant moved here, if known.
+	Moved *time.Time `law:"moved"`
+}
+
+// Outputs are the @outputs of the policy, which are left as their zero
+// value if the policy does not set them. If one is unknown, Evaluate fails
+// with the inputs it needs.
+type Outputs struct {
+	Eligible bool `law:"eligible"`
+
+	// How long the benefit is paid for.
+	Duration policyscript.Period `law:"duration"`
+	Amount   policyscript.Money  `law:"amount"`
+	Children int                 `law:"children"`
+	Resident bool                `law:"resident"`
+}
+
+// sources are the files of the policy.
+var sources = []struct{ name, source string }{
+	{"benefits.law", "@enum Status {\n" +
+		"  - single\n" +
+		"  - married\n" +
+		"  - common_law\n" +
+		"}\n" +
+		"\n" +
+		"# A person who applies for the benefit.\n" +
+		"@define Person {\n" +
+		"  status: Status\n" +
+		"  born: date\n" +
+		"  # Yearly salary, before tax.\n" +
+		"  salary: money\n" +
+		"  children: Child list\n" +
+		"}\n" +
+		"\n" +
+		"@define Child {\n" +
+		"  name: text\n" +
+		"  born: date\n" +
+		"}\n" +
+		"\n" +
+		"@inputs {\n" +
+		"  applicant: Person\n" +
+		"  today: date\n" +
+		"  rate: percent or 5%\n" +
+		"  # When the applicant moved here, if known.\n" +
+		"  moved: date or unknown\n" +
+		"}\n" +
+		"\n" +
+		"@outputs {\n" +
+		"  eligible: condition\n" +
+		"  # How long the benefit is paid for.\n" +
+		"  duration: period\n" +
+		"  amount: money\n" +
+		"  children: integer\n" +
+		"  resident: condition\n" +
+		"}\n" +
+		"\n" +
+		"_ 12 Benefit\n" +
+		"\n" +
+		"@code {\n" +
+		"  set children to 0\n" +
+		"  for child in applicant.children:\n" +
+		"    if today - child.born < 18 years:\n" +
+		"      set children to children + 1\n" +
+		"  set eligible to children > 0\n" +
+		"  set duration to 1 year\n" +
+		"  set amount to applicant.salary * rate\n" +
+		"  set resident to moved < today - 2 years\n" +
+		"}\n"},
+}
+
+var policy struct {
+	once    sync.Once
+	binding *policyscript.Binding
+	err     error
+}
+
+// Evaluate runs the policy with inputs, and returns its outputs. Errors are
+// those of policyscript.Binding.
+func Evaluate(ctx context.Context, inputs Inputs) (Outputs, error) {
+	policy.once.Do(func() {
+		files := util.NewFileSet()
+		for _, file := range sources {
+			files.AddFile(file.name, []byte(file.source))
+		}
+		compiled, err := policyscript.Compile(files)
+		if err != nil {
+			policy.err = err
+			return
+		}
+		policy.binding, policy.err = compiled.Bind(Inputs{}, Outputs{})
+	})
+
+	var outputs Outputs
+	if policy.err != nil {
+		return outputs, policy.err
+	}
+	err := policy.binding.Evaluate(ctx, inputs, &outputs)
+	return outputs, err
+}
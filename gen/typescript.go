@@ -0,0 +1,152 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/policyscript/policyscript/types"
+)
+
+// tsValues are the types of the values which are written as text, in the
+// form read and written by types.Decode and types.Encode.
+const tsValues = `/** Money with its symbol and two decimals, ex: "$1000.50" or "-$5.00". */
+export type Money = ` + "`$${number}` | `-$${number}`" + `;
+
+/** A percentage, ex: "15%". */
+export type Percent = ` + "`${number}%`" + `;
+
+/** A length of time in units, ex: "1 year 6 months" or "10 days". */
+export type Period = ` + "`${number} ${string}`" + `;
+
+/** A date as year, month and day, ex: "2021-01-15". */
+export type CalendarDate = ` + "`${number}-${number}-${number}`" + `;
+
+/** A time of day as hours, minutes and seconds, ex: "23:59:59". */
+export type TimeOfDay = ` + "`${number}:${number}:${number}`" + `;
+`
+
+// TypeScript generates TypeScript types for the values of a policy, as they
+// are written in JSON: an interface for each @define type, a union of the
+// members of each @enum with an array of them, and Inputs and Outputs
+// interfaces. Money, percentages, periods, dates and times are text, whose
+// types are template literals such as `${number}%`. Optional inputs and
+// outputs may be missing, or null if they are unknown. The code is a module
+// without imports, so it can be copied next to the code which uses it.
+func TypeScript(info *types.Info) ([]byte, error) {
+	names := unique{}
+	for _, name := range []string{"Money", "Percent", "Period", "CalendarDate", "TimeOfDay", "Inputs", "Outputs"} {
+		names.add(name, "generated "+name)
+	}
+
+	var b strings.Builder
+	b.WriteString("// Code generated by \"policyscript gen ts\"; DO NOT EDIT.\n\n")
+	b.WriteString(tsValues)
+
+	for _, t := range named(info) {
+		switch t := t.(type) {
+		case *types.Enum:
+			name := camel(t.Name)
+			members := name + "Members"
+			if err := names.add(name, "@enum "+t.Name); err != nil {
+				return nil, err
+			}
+			if err := names.add(members, "members of @enum "+t.Name); err != nil {
+				return nil, err
+			}
+
+			quoted := make([]string, len(t.Members))
+			for i, member := range t.Members {
+				quoted[i] = "'" + member + "'"
+			}
+			fmt.Fprintf(&b, "\n/** The members of %s, in order. */\n", name)
+			fmt.Fprintf(&b, "export const %s = [%s] as const;\n\n", members, strings.Join(quoted, ", "))
+			tsComment(&b, "", t.Doc, fmt.Sprintf("%s is the @enum %s.", name, t.Name))
+			fmt.Fprintf(&b, "export type %s = typeof %s[number];\n", name, members)
+
+		case *types.Group:
+			name := camel(t.Name)
+			if err := names.add(name, "@define "+t.Name); err != nil {
+				return nil, err
+			}
+			tsInterface(&b, name, t.Doc, fmt.Sprintf("%s is the @define %s.", name, t.Name), t.Fields, false)
+		}
+	}
+
+	tsInterface(&b, "Inputs", "", "Inputs are the @inputs of the policy.", info.Inputs, false)
+	tsInterface(&b, "Outputs", "", "Outputs are the @outputs of the policy, which are missing if\n"+
+		"the policy does not set them, and null if they are unknown.", info.Outputs, true)
+	return []byte(b.String()), nil
+}
+
+// tsInterface writes an interface with a property for each field, commented
+// with doc, or fallback if there is no documentation. Fields are optional and
+// may be null if optional is true, or if they have a default.
+func tsInterface(b *strings.Builder, name, doc, fallback string, fields []*types.Field, optional bool) {
+	b.WriteString("\n")
+	tsComment(b, "", doc, fallback)
+	fmt.Fprintf(b, "export interface %s {\n", name)
+	for _, field := range fields {
+		tsComment(b, "\t", field.Doc, "")
+		if optional || field.Default != nil {
+			fmt.Fprintf(b, "\t%s?: %s | null;\n", field.Name, tsType(field.Type))
+		} else {
+			fmt.Fprintf(b, "\t%s: %s;\n", field.Name, tsType(field.Type))
+		}
+	}
+	b.WriteString("}\n")
+}
+
+// tsType returns the TypeScript type of the values of a type.
+func tsType(t types.Type) string {
+	switch t := t.(type) {
+	case *types.List:
+		return tsType(t.Elem) + "[]"
+	case *types.Group:
+		return camel(t.Name)
+	case *types.Enum:
+		return camel(t.Name)
+	}
+
+	switch t {
+	case types.Text:
+		return "string"
+	case types.Integer, types.Decimal:
+		return "number"
+	case types.Condition:
+		return "boolean"
+	case types.Money:
+		return "Money"
+	case types.Percent:
+		return "Percent"
+	case types.Period:
+		return "Period"
+	case types.Date:
+		return "CalendarDate"
+	case types.Time:
+		return "TimeOfDay"
+	}
+	return "unknown"
+}
+
+// tsComment writes a documentation comment with the lines of doc, or of
+// fallback if doc is empty, indented by indent.
+func tsComment(b *strings.Builder, indent, doc, fallback string) {
+	if doc == "" {
+		doc = fallback
+	}
+	if doc == "" {
+		return
+	}
+	// The end of a comment in the documentation would end this one.
+	doc = strings.ReplaceAll(doc, "*/", "*\\/")
+	lines := strings.Split(strings.TrimSpace(doc), "\n")
+	if len(lines) == 1 {
+		fmt.Fprintf(b, "%s/** %s */\n", indent, lines[0])
+		return
+	}
+	fmt.Fprintf(b, "%s/**\n", indent)
+	for _, line := range lines {
+		b.WriteString(strings.TrimRight(indent+" * "+line, " ") + "\n")
+	}
+	fmt.Fprintf(b, "%s */\n", indent)
+}
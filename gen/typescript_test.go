@@ -0,0 +1,34 @@
+package gen_test
+
+import (
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript"
+	"github.com/policyscript/policyscript/gen"
+	"github.com/policyscript/policyscript/util"
+)
+
+var _ = Describe("TypeScript", func() {
+	It("generates types for values as they are written in JSON", func() {
+		_, policy := compile("testdata/benefits.law")
+		code, err := gen.TypeScript(policy.Info())
+		Expect(err).NotTo(HaveOccurred())
+		if *update {
+			Expect(ioutil.WriteFile("testdata/benefits.ts", code, 0644)).To(Succeed())
+		}
+		golden, err := ioutil.ReadFile("testdata/benefits.ts")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(code)).To(Equal(string(golden)))
+	})
+
+	It("reports names which are the same in TypeScript", func() {
+		files := util.NewFileSet()
+		files.AddFile("money.law", []byte("@enum Money {\n  - cash\n}\n"))
+		policy, err := policyscript.Compile(files)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = gen.TypeScript(policy.Info())
+		Expect(err).To(MatchError("gen: generated Money and @enum Money are both named Money"))
+	})
+})
@@ -5,4 +5,5 @@ go 1.15
 require (
 	github.com/onsi/ginkgo v1.14.2
 	github.com/onsi/gomega v1.10.4
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,125 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// message is a JSON-RPC 2.0 request, notification or response. Requests have
+// an ID and a method, notifications only a method, and responses only an ID.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string { return e.Message }
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidParams  = -32602
+	codeMethodNotFound = -32601
+	codeInvalidRequest = -32600
+)
+
+// maxContentLength is the length of the largest message which is read, so
+// that a client can not make the server allocate any amount of memory.
+const maxContentLength = 64 << 20
+
+// conn reads and writes messages framed by a Content-Length header, as used by
+// the stdio transport.
+type conn struct {
+	r *textproto.Reader
+	w io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: textproto.NewReader(bufio.NewReader(r)), w: w}
+}
+
+// read returns the next message. It returns io.EOF when the input is closed.
+func (c *conn) read() (*message, error) {
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 || length > maxContentLength {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r.R, body); err != nil {
+		return nil, err
+	}
+
+	msg := &message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, &responseError{Code: codeParseError, Message: err.Error()}
+	}
+	return msg, nil
+}
+
+// write sends a message.
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+// reply sends the response to a request. The ID of a request which could not
+// be read is nil, and is sent as null, since a response must have an ID.
+func (c *conn) reply(id *json.RawMessage, result interface{}, err error) error {
+	if id == nil {
+		null := json.RawMessage("null")
+		id = &null
+	}
+	msg := &message{ID: id}
+	if err != nil {
+		rerr, ok := err.(*responseError)
+		if !ok {
+			rerr = &responseError{Code: codeInvalidRequest, Message: err.Error()}
+		}
+		msg.Error = rerr
+		return c.write(msg)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	raw := json.RawMessage(data)
+	msg.Result = &raw
+	return c.write(msg)
+}
+
+// notify sends a notification.
+func (c *conn) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{Method: method, Params: data})
+}
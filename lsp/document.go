@@ -0,0 +1,251 @@
+package lsp
+
+import (
+	"math"
+
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/citation"
+	"github.com/policyscript/policyscript/parser"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/types"
+	"github.com/policyscript/policyscript/util"
+)
+
+// document is an open document and what is known about it.
+type document struct {
+	uri     string
+	version int
+	text    string
+	runes   []rune
+	file    *util.File
+	lines   *util.LineIndex
+
+	program *ast.Program
+	errs    util.ErrorList
+
+	// info is from the last version of the document without syntax errors, so
+	// that completion still works while a statement is being written.
+	info *types.Info
+
+	// Declarations by name.
+	types   map[string]*ast.BlockStatement        // @define and @enum blocks
+	fields  map[string]*ast.DeclareExpression     // @inputs, @outputs and @locals
+	kinds   map[string]token.Type                 // block keyword of each field
+	members map[string]map[string]*ast.Identifier // fields of groups and members of enums
+
+	// docs are the documentation of each declaration, by its name.
+	docs map[*ast.Identifier]string
+}
+
+// analyze parses and checks a version of a document. The previous version, if
+// any, provides type information while the new version has syntax errors.
+func analyze(uri string, version int, text string, prev *document) *document {
+	d := &document{
+		uri:     uri,
+		version: version,
+		text:    text,
+		runes:   []rune(text),
+		types:   map[string]*ast.BlockStatement{},
+		fields:  map[string]*ast.DeclareExpression{},
+		kinds:   map[string]token.Type{},
+		members: map[string]map[string]*ast.Identifier{},
+		docs:    map[*ast.Identifier]string{},
+	}
+	d.file = util.NewFile(uri, []byte(text))
+	d.lines = d.file.Lines()
+	if prev != nil {
+		d.info = prev.info
+	}
+
+	s := scanner.New(d.file, func(err *util.Error) {
+		d.errs = append(d.errs, err)
+	})
+	p := parser.New(*s)
+	d.program = p.ParseProgram()
+	d.errs = append(d.errs, p.Errors()...)
+	d.index()
+
+	if len(d.errs) == 0 {
+		d.errs = citation.Resolve(d.program)
+		info, errs := types.Check(d.program)
+		d.info = info
+		d.errs = append(d.errs, errs...)
+	}
+	d.errs.Dedupe()
+	return d
+}
+
+// index records the declarations of the document and their comments.
+func (d *document) index() {
+	d.collectDocs(d.program.Stmts)
+
+	for _, stmt := range d.program.Stmts {
+		block, ok := stmt.(*ast.BlockStatement)
+		if !ok {
+			continue
+		}
+		d.collectDocs(block.Stmts)
+
+		switch block.Token.Type {
+		case token.DEFINE, token.ENUM:
+			if block.Ident == nil {
+				continue
+			}
+			name := block.Ident.Value
+			if _, ok := d.types[name]; !ok {
+				d.types[name] = block
+				d.members[name] = map[string]*ast.Identifier{}
+			}
+			for _, ident := range declaredNames(block) {
+				if _, ok := d.members[name][ident.Value]; !ok && d.types[name] == block {
+					d.members[name][ident.Value] = ident
+				}
+			}
+		case token.INPUTS, token.OUTPUTS, token.LOCALS:
+			for _, stmt := range block.Stmts {
+				if decl := declaration(stmt); decl != nil {
+					if _, ok := d.fields[decl.Ident.Value]; !ok {
+						d.fields[decl.Ident.Value] = decl
+						d.kinds[decl.Ident.Value] = block.Token.Type
+					}
+				}
+			}
+		}
+	}
+}
+
+// collectDocs records the documentation of each declaration in stmts.
+func (d *document) collectDocs(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.BlockStatement:
+			if stmt.Ident != nil && stmt.Doc != "" {
+				d.docs[stmt.Ident] = stmt.Doc
+			}
+		case *ast.ExpressionStatement:
+			if stmt.Doc == "" {
+				continue
+			}
+			if decl := declaration(stmt); decl != nil {
+				d.docs[decl.Ident] = stmt.Doc
+			} else if member := enumMember(stmt); member != nil {
+				d.docs[member] = stmt.Doc
+			}
+		}
+	}
+}
+
+// declaredNames returns the field names of a @define block, or the members
+// of an @enum block.
+func declaredNames(block *ast.BlockStatement) []*ast.Identifier {
+	var names []*ast.Identifier
+	for _, stmt := range block.Stmts {
+		if decl := declaration(stmt); decl != nil {
+			names = append(names, decl.Ident)
+		} else if member := enumMember(stmt); member != nil {
+			names = append(names, member)
+		}
+	}
+	return names
+}
+
+func declaration(stmt ast.Stmt) *ast.DeclareExpression {
+	if exp, ok := stmt.(*ast.ExpressionStatement); ok {
+		if decl, ok := exp.Expr.(*ast.DeclareExpression); ok && decl.Ident != nil && decl.Value != nil {
+			return decl
+		}
+	}
+	return nil
+}
+
+// enumMember returns the name of a member written as "- member".
+func enumMember(stmt ast.Stmt) *ast.Identifier {
+	if exp, ok := stmt.(*ast.ExpressionStatement); ok {
+		if prefix, ok := exp.Expr.(*ast.PrefixExpression); ok && prefix.Operator == "-" {
+			if ident, ok := prefix.Right.(*ast.Identifier); ok {
+				return ident
+			}
+		}
+	}
+	return nil
+}
+
+// source returns the text of a range.
+func (d *document) source(rng *util.Range) string {
+	start, end := rng.Start.Offset, rng.End.Offset
+	if start < 0 || end > len(d.runes) || start > end {
+		return ""
+	}
+	return string(d.runes[start:end])
+}
+
+// linePrefix returns the text of a line up to a position.
+func (d *document) linePrefix(pos Position) string {
+	if pos.Line < 0 || pos.Line >= d.lines.Lines() {
+		return ""
+	}
+	start := d.fromPosition(Position{Line: pos.Line})
+	end := d.fromPosition(pos)
+	return string(d.runes[start.Offset:end.Offset])
+}
+
+// lineLength returns the number of characters in a 0-indexed line.
+func (d *document) lineLength(line int) int {
+	end := d.lines.AtUTF16(util.UTF16Position{Line: line + 1, Column: math.MaxInt32})
+	return d.lines.UTF16(end).Column
+}
+
+// identAt returns the identifier at a position, and the nodes which contain
+// it, outermost first.
+func (d *document) identAt(pos util.Position) (*ast.Identifier, []ast.Node) {
+	var (
+		found *ast.Identifier
+		path  []ast.Node
+	)
+
+	var visit func(node ast.Node, parents []ast.Node)
+	visit = func(node ast.Node, parents []ast.Node) {
+		if found != nil {
+			return
+		}
+		if ident, ok := node.(*ast.Identifier); ok {
+			if contains(ident.Range(), pos) {
+				found = ident
+				path = parents
+			}
+			return
+		}
+		parents = append(parents[:len(parents):len(parents)], node)
+		for _, child := range ast.Children(node) {
+			visit(child, parents)
+		}
+	}
+	visit(d.program, nil)
+	return found, path
+}
+
+// contains reports whether a position is inside a range, including its end.
+func contains(rng *util.Range, pos util.Position) bool {
+	return !before(pos, rng.Start) && !before(rng.End, pos)
+}
+
+func before(a, b util.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// toPosition converts a position of the scanner to an LSP position, whose
+// characters are UTF-16 code units.
+func (d *document) toPosition(pos util.Position) Position {
+	p := d.lines.UTF16(pos)
+	return Position{Line: p.Line - 1, Character: p.Column}
+}
+
+func (d *document) toRange(rng *util.Range) Range {
+	return Range{Start: d.toPosition(rng.Start), End: d.toPosition(rng.End)}
+}
+
+// fromPosition converts an LSP position to a position of the scanner.
+func (d *document) fromPosition(pos Position) util.Position {
+	return d.lines.AtUTF16(util.UTF16Position{Line: pos.Line + 1, Column: pos.Character})
+}
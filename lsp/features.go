@@ -0,0 +1,557 @@
+package lsp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/citation"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/types"
+)
+
+// symbol is what an identifier names.
+type symbol struct {
+	ident *ast.Identifier // where it is declared
+	code  string          // the declaration, ex: "person: Person"
+	note  string          // what kind of name it is, ex: "Input."
+	doc   string
+}
+
+// resolve returns the symbol named by an identifier, or nil if not found.
+// The path is the nodes containing the identifier, outermost first.
+func (d *document) resolve(ident *ast.Identifier, path []ast.Node) *symbol {
+	if len(path) == 0 {
+		return nil
+	}
+	name := ident.Value
+
+	switch parent := path[len(path)-1].(type) {
+	case *ast.BlockStatement:
+		return d.typeSymbol(name)
+	case *ast.DeclareExpression:
+		if parent.Ident != ident {
+			return d.typeSymbol(name)
+		}
+		if block := enclosingBlock(path); block != nil && block.Ident != nil {
+			return d.memberSymbol(block.Ident.Value, name)
+		}
+		return d.fieldSymbol(name)
+	case *ast.ListType:
+		return d.typeSymbol(name)
+	case *ast.MemberExpression:
+		if parent.Ident != ident {
+			break
+		}
+		if left, ok := parent.Left.(*ast.Identifier); ok && d.isEnum(left.Value) {
+			return d.memberSymbol(left.Value, name)
+		}
+		if d.info != nil {
+			if group, ok := d.info.Exprs[parent.Left].(*types.Group); ok {
+				return d.memberSymbol(group.Name, name)
+			}
+		}
+		return nil
+	case *ast.PrefixExpression:
+		if block := enclosingBlock(path); block != nil && block.Token.Type == token.ENUM &&
+			block.Ident != nil {
+			return d.memberSymbol(block.Ident.Value, name)
+		}
+	}
+
+	// A name used in code is the innermost loop variable, field or type of
+	// that name.
+	for i := len(path) - 1; i >= 0; i-- {
+		if loop, ok := path[i].(*ast.ForStatement); ok && loop.Ident != nil && loop.Ident.Value == name {
+			return d.loopSymbol(loop)
+		}
+	}
+	if sym := d.fieldSymbol(name); sym != nil {
+		return sym
+	}
+	return d.typeSymbol(name)
+}
+
+func (d *document) isEnum(name string) bool {
+	block, ok := d.types[name]
+	return ok && block.Token.Type == token.ENUM
+}
+
+func (d *document) typeSymbol(name string) *symbol {
+	block, ok := d.types[name]
+	if !ok {
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s {\n", block.Token.Literal, name)
+	for _, ident := range declaredNames(block) {
+		if block.Token.Type == token.ENUM {
+			fmt.Fprintf(&b, "  - %s\n", ident.Value)
+		} else {
+			fmt.Fprintf(&b, "  %s\n", d.declarationSource(d.members[name][ident.Value]))
+		}
+	}
+	b.WriteString("}")
+
+	return &symbol{ident: block.Ident, code: b.String(), doc: d.docs[block.Ident]}
+}
+
+func (d *document) fieldSymbol(name string) *symbol {
+	decl, ok := d.fields[name]
+	if !ok {
+		return nil
+	}
+
+	var note string
+	switch d.kinds[name] {
+	case token.INPUTS:
+		note = "Input."
+	case token.OUTPUTS:
+		note = "Output."
+	case token.LOCALS:
+		note = "Local."
+	}
+	return &symbol{ident: decl.Ident, code: d.source(decl.Range()), note: note, doc: d.docs[decl.Ident]}
+}
+
+func (d *document) memberSymbol(typeName, name string) *symbol {
+	ident, ok := d.members[typeName][name]
+	if !ok {
+		return nil
+	}
+
+	if d.isEnum(typeName) {
+		return &symbol{
+			ident: ident,
+			code:  typeName + "." + name,
+			note:  fmt.Sprintf("Member of `%s`.", typeName),
+			doc:   d.docs[ident],
+		}
+	}
+	return &symbol{
+		ident: ident,
+		code:  d.declarationSource(ident),
+		note:  fmt.Sprintf("Field of `%s`.", typeName),
+		doc:   d.docs[ident],
+	}
+}
+
+func (d *document) loopSymbol(loop *ast.ForStatement) *symbol {
+	code := loop.Ident.Value
+	if d.info != nil {
+		if list, ok := d.info.Exprs[loop.Iter].(*types.List); ok {
+			code += ": " + list.Elem.String()
+		}
+	}
+	return &symbol{ident: loop.Ident, code: code, note: "Loop variable."}
+}
+
+// declarationSource returns the source of the declaration of a field name,
+// ex: "age: Age".
+func (d *document) declarationSource(ident *ast.Identifier) string {
+	for _, stmt := range d.program.Stmts {
+		block, ok := stmt.(*ast.BlockStatement)
+		if !ok {
+			continue
+		}
+		for _, stmt := range block.Stmts {
+			if decl := declaration(stmt); decl != nil && decl.Ident == ident {
+				return d.source(decl.Range())
+			}
+		}
+	}
+	return ident.Value
+}
+
+func enclosingBlock(path []ast.Node) *ast.BlockStatement {
+	for i := len(path) - 1; i >= 0; i-- {
+		if block, ok := path[i].(*ast.BlockStatement); ok {
+			return block
+		}
+	}
+	return nil
+}
+
+// definition returns where the name at a position is declared.
+func (d *document) definition(pos Position) *Location {
+	ident, path := d.identAt(d.fromPosition(pos))
+	if ident == nil {
+		return nil
+	}
+	sym := d.resolve(ident, path)
+	if sym == nil {
+		return nil
+	}
+	return &Location{URI: d.uri, Range: d.toRange(sym.ident.Range())}
+}
+
+// hover describes the name at a position.
+func (d *document) hover(pos Position) *Hover {
+	ident, path := d.identAt(d.fromPosition(pos))
+	if ident == nil {
+		return nil
+	}
+	sym := d.resolve(ident, path)
+	if sym == nil {
+		return nil
+	}
+
+	parts := []string{"```policyscript\n" + sym.code + "\n```"}
+	if sym.note != "" {
+		parts = append(parts, sym.note)
+	}
+	if sym.doc != "" {
+		parts = append(parts, sym.doc)
+	}
+	rng := d.toRange(ident.Range())
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: strings.Join(parts, "\n\n")},
+		Range:    &rng,
+	}
+}
+
+var (
+	// A member being written, ex: "person.ag".
+	memberPrefix = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*)\.[A-Za-z0-9_]*$`)
+
+	// A period unit being written, ex: "18 ye".
+	periodPrefix = regexp.MustCompile(`[0-9][0-9_]*\s+[a-z]*$`)
+)
+
+// completion returns the names which can be written at a position.
+func (d *document) completion(pos Position) []CompletionItem {
+	prefix := d.linePrefix(pos)
+
+	if match := memberPrefix.FindStringSubmatch(prefix); match != nil {
+		return d.memberCompletion(strings.Split(match[1], "."))
+	}
+
+	if periodPrefix.MatchString(prefix) {
+		var items []CompletionItem
+		for _, unit := range token.PeriodKeywords() {
+			items = append(items, CompletionItem{Label: unit, Kind: CompletionUnit})
+		}
+		return items
+	}
+
+	var items []CompletionItem
+	if strings.TrimSpace(prefix) == "" || strings.HasPrefix(strings.TrimSpace(prefix), "@") {
+		for _, keyword := range token.BlockKeywords() {
+			items = append(items, CompletionItem{Label: keyword, Kind: CompletionKeyword})
+		}
+	}
+	for _, keyword := range token.Keywords() {
+		items = append(items, CompletionItem{Label: keyword, Kind: CompletionKeyword})
+	}
+
+	for _, name := range sortedNames(d.fields) {
+		sym := d.fieldSymbol(name)
+		items = append(items, CompletionItem{
+			Label:         name,
+			Kind:          CompletionVariable,
+			Detail:        sym.code,
+			Documentation: markdown(sym.doc),
+		})
+	}
+
+	for _, name := range sortedNames(d.types) {
+		kind := CompletionStruct
+		if d.isEnum(name) {
+			kind = CompletionEnum
+		}
+		items = append(items, CompletionItem{
+			Label:         name,
+			Kind:          kind,
+			Documentation: markdown(d.docs[d.types[name].Ident]),
+		})
+	}
+
+	for _, name := range []string{"text", "integer", "decimal", "money", "percent", "period",
+		"date", "time", "condition"} {
+		items = append(items, CompletionItem{Label: name, Kind: CompletionType})
+	}
+	return items
+}
+
+// memberCompletion returns the members of an enum, or the fields of the value
+// named by a chain of names, ex: ["person", "address"].
+func (d *document) memberCompletion(names []string) []CompletionItem {
+	var items []CompletionItem
+
+	if len(names) == 1 && d.isEnum(names[0]) {
+		for _, ident := range declaredNames(d.types[names[0]]) {
+			sym := d.memberSymbol(names[0], ident.Value)
+			items = append(items, CompletionItem{
+				Label:         ident.Value,
+				Kind:          CompletionEnumMember,
+				Documentation: markdown(sym.doc),
+			})
+		}
+		return items
+	}
+
+	t := d.typeOfName(names[0])
+	for _, name := range names[1:] {
+		group, ok := t.(*types.Group)
+		if !ok {
+			return nil
+		}
+		field := group.Field(name)
+		if field == nil {
+			return nil
+		}
+		t = field.Type
+	}
+
+	group, ok := t.(*types.Group)
+	if !ok {
+		return nil
+	}
+	for _, field := range group.Fields {
+		sym := d.memberSymbol(group.Name, field.Name)
+		item := CompletionItem{Label: field.Name, Kind: CompletionField, Detail: field.Type.String()}
+		if sym != nil {
+			item.Documentation = markdown(sym.doc)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// typeOfName returns the type of a field or loop variable, or nil if not
+// known.
+func (d *document) typeOfName(name string) types.Type {
+	if d.info == nil {
+		return nil
+	}
+	if field := d.info.Lookup(name); field != nil {
+		return field.Type
+	}
+
+	var t types.Type
+	ast.Inspect(d.program, func(node ast.Node) bool {
+		if loop, ok := node.(*ast.ForStatement); ok && loop.Ident != nil && loop.Ident.Value == name {
+			if list, ok := d.info.Exprs[loop.Iter].(*types.List); ok {
+				t = list.Elem
+			}
+		}
+		return true
+	})
+	return t
+}
+
+func markdown(value string) *MarkupContent {
+	if value == "" {
+		return nil
+	}
+	return &MarkupContent{Kind: "markdown", Value: value}
+}
+
+func sortedNames(m interface{}) []string {
+	var names []string
+	switch m := m.(type) {
+	case map[string]*ast.DeclareExpression:
+		for name := range m {
+			names = append(names, name)
+		}
+	case map[string]*ast.BlockStatement:
+		for name := range m {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// symbols returns the headings of the document as a tree, with the types
+// declared under each heading as its children.
+func (d *document) symbols() []*DocumentSymbol {
+	var (
+		roots []*DocumentSymbol
+
+		// Open headings by depth, and the nodes which end each one.
+		open []*DocumentSymbol
+	)
+
+	add := func(sym *DocumentSymbol) {
+		if len(open) == 0 {
+			roots = append(roots, sym)
+		} else {
+			parent := open[len(open)-1]
+			parent.Children = append(parent.Children, sym)
+		}
+	}
+	extend := func(end Position) {
+		for _, sym := range open {
+			sym.Range.End = end
+		}
+	}
+
+	for _, stmt := range d.program.Stmts {
+		switch stmt := stmt.(type) {
+		case *ast.HeadingStatement:
+			if stmt.Depth < len(open) {
+				open = open[:stmt.Depth]
+			}
+			name := stmt.Value
+			if cite := citation.Cite(stmt.Path); cite != "" {
+				name = cite + " " + name
+			}
+			rng := d.toRange(stmt.Range())
+			sym := &DocumentSymbol{Name: name, Kind: SymbolNamespace, Range: rng, SelectionRange: rng}
+			add(sym)
+			open = append(open, sym)
+			continue
+		case *ast.BlockStatement:
+			if stmt.Ident != nil && (stmt.Token.Type == token.DEFINE || stmt.Token.Type == token.ENUM) {
+				kind := SymbolStruct
+				if stmt.Token.Type == token.ENUM {
+					kind = SymbolEnum
+				}
+				add(&DocumentSymbol{
+					Name:           stmt.Ident.Value,
+					Detail:         stmt.Token.Literal,
+					Kind:           kind,
+					Range:          d.toRange(stmt.Range()),
+					SelectionRange: d.toRange(stmt.Ident.Range()),
+				})
+			}
+		}
+		extend(d.toPosition(stmt.Range().End))
+	}
+	return roots
+}
+
+// codeActions returns the fixes of the diagnostics which overlap a range.
+func (d *document) codeActions(rng Range) []CodeAction {
+	start, end := d.fromPosition(rng.Start), d.fromPosition(rng.End)
+
+	var actions []CodeAction
+	for _, err := range d.errs {
+		if before(err.Rng.End, start) || before(end, err.Rng.Start) {
+			continue
+		}
+		diagnostic := d.diagnostic(err)
+		for _, fix := range err.Fixes {
+			edits := make([]TextEdit, len(fix.Edits))
+			for i, edit := range fix.Edits {
+				edits[i] = TextEdit{Range: d.toRange(&edit.Rng), NewText: edit.NewText}
+			}
+			actions = append(actions, CodeAction{
+				Title:       fix.Title,
+				Kind:        CodeActionQuickFix,
+				Diagnostics: []Diagnostic{diagnostic},
+				IsPreferred: len(err.Fixes) == 1,
+				Edit:        &WorkspaceEdit{Changes: map[string][]TextEdit{d.uri: edits}},
+			})
+		}
+	}
+	return actions
+}
+
+// The semantic token types, in the order of the legend.
+var tokenTypes = []string{
+	"keyword", "string", "number", "operator", "comment",
+	"variable", "type", "enumMember", "property", "namespace",
+}
+
+const (
+	semanticKeyword = iota
+	semanticString
+	semanticNumber
+	semanticOperator
+	semanticComment
+	semanticVariable
+	semanticType
+	semanticEnumMember
+	semanticProperty
+	semanticNamespace
+)
+
+// semanticTokens returns the tokens of the document, encoded as relative
+// positions as described by the protocol.
+func (d *document) semanticTokens() *SemanticTokens {
+	tokens := scanner.New(d.file, nil).Scan()
+
+	var (
+		data               []int
+		prevLine, prevChar int
+	)
+	emit := func(line, char, length, kind int) {
+		if length <= 0 {
+			return
+		}
+		deltaChar := char
+		if line == prevLine {
+			deltaChar = char - prevChar
+		}
+		data = append(data, line-prevLine, deltaChar, length, kind, 0)
+		prevLine, prevChar = line, char
+	}
+
+	for i, tok := range tokens {
+		kind, ok := d.semanticType(tokens, i)
+		if !ok {
+			continue
+		}
+
+		// Tokens which span lines, such as comments, are split into one token
+		// per line.
+		start, end := d.toPosition(tok.Range.Start), d.toPosition(tok.Range.End)
+		for line := start.Line; line <= end.Line && line < d.lines.Lines(); line++ {
+			char, stop := 0, d.lineLength(line)
+			if line == start.Line {
+				char = start.Character
+			}
+			if line == end.Line {
+				stop = end.Character
+			}
+			emit(line, char, stop-char, kind)
+		}
+	}
+	return &SemanticTokens{Data: data}
+}
+
+// semanticType returns the semantic token type of a token, or false if it is
+// not highlighted.
+func (d *document) semanticType(tokens []token.Token, i int) (int, bool) {
+	tok := tokens[i]
+	switch tok.Type {
+	case token.IF, token.ELSE, token.FOR, token.IN, token.SET, token.TO, token.TRUE,
+		token.FALSE, token.AND, token.OR, token.LIST, token.UNKNOWN, token.META, token.DEFINE, token.ENUM,
+		token.INPUTS, token.OUTPUTS, token.LOCALS, token.CODE:
+		return semanticKeyword, true
+	case token.TEXT:
+		return semanticString, true
+	case token.INTEGER, token.DECIMAL, token.MONEY, token.PERCENT, token.PERIOD,
+		token.DATE, token.TIME:
+		return semanticNumber, true
+	case token.EQ, token.NOT_EQ, token.PLUS, token.MINUS, token.MULT, token.DIV,
+		token.LT, token.GT, token.LT_EQ, token.GT_EQ:
+		return semanticOperator, true
+	case token.COMMENT:
+		return semanticComment, true
+	case token.HEADING:
+		// Headings name sections, which is the closest standard type.
+		return semanticNamespace, true
+	case token.IDENT:
+		if i >= 2 && tokens[i-1].Type == token.DOT {
+			if d.isEnum(tokens[i-2].Literal) {
+				return semanticEnumMember, true
+			}
+			return semanticProperty, true
+		}
+		if _, ok := d.types[tok.Literal]; ok {
+			return semanticType, true
+		}
+		if _, ok := types.LookupBasic(tok.Literal); ok {
+			return semanticType, true
+		}
+		return semanticVariable, true
+	}
+	return 0, false
+}
@@ -0,0 +1,13 @@
+package lsp_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLsp(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Lsp Suite")
+}
@@ -0,0 +1,292 @@
+// Package lsp is a Language Server Protocol server for PolicyScript, which
+// gives editors diagnostics, quick fixes, go to definition, hover,
+// completion, document symbols and semantic highlighting.
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/policyscript/policyscript/util"
+)
+
+// Server answers the requests of one client.
+type Server struct {
+	conn *conn
+	docs map[string]*document
+
+	// Whether the client asked the server to shut down.
+	shutdown bool
+}
+
+// Serve runs a server over a reader and writer, such as stdin and stdout,
+// until the client exits or the input is closed.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &Server{conn: newConn(r, w), docs: map[string]*document{}}
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if rerr, ok := err.(*responseError); ok {
+			// A message which is not JSON can not be answered by ID.
+			if err := s.conn.reply(nil, nil, rerr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit before shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers a request or notification. It only returns an error if the
+// connection failed.
+func (s *Server) handle(msg *message) error {
+	handler, ok := handlers[msg.Method]
+	if !ok {
+		if msg.ID == nil {
+			// Notifications which are not understood are ignored.
+			return nil
+		}
+		return s.conn.reply(msg.ID, nil, &responseError{
+			Code:    codeMethodNotFound,
+			Message: fmt.Sprintf("method %s is not supported", msg.Method),
+		})
+	}
+
+	result, err := handler(s, msg.Params)
+	if msg.ID == nil {
+		return nil
+	}
+	return s.conn.reply(msg.ID, result, err)
+}
+
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers map[string]handler
+
+func init() {
+	handlers = map[string]handler{
+		"initialize":                       (*Server).initialize,
+		"initialized":                      nothing,
+		"shutdown":                         (*Server).shutdownRequest,
+		"textDocument/didOpen":             (*Server).didOpen,
+		"textDocument/didChange":           (*Server).didChange,
+		"textDocument/didClose":            (*Server).didClose,
+		"textDocument/definition":          (*Server).definition,
+		"textDocument/hover":               (*Server).hover,
+		"textDocument/completion":          (*Server).completion,
+		"textDocument/documentSymbol":      (*Server).documentSymbol,
+		"textDocument/codeAction":          (*Server).codeAction,
+		"textDocument/semanticTokens/full": (*Server).semanticTokens,
+	}
+}
+
+func nothing(*Server, json.RawMessage) (interface{}, error) { return nil, nil }
+
+func (s *Server) initialize(json.RawMessage) (interface{}, error) {
+	return &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:       SyncFull,
+			DefinitionProvider:     true,
+			HoverProvider:          true,
+			CompletionProvider:     CompletionOptions{TriggerCharacters: []string{"."}},
+			DocumentSymbolProvider: true,
+			CodeActionProvider:     true,
+			SemanticTokensProvider: SemanticTokensOptions{
+				Legend: SemanticTokensLegend{TokenTypes: tokenTypes, TokenModifiers: []string{}},
+				Full:   true,
+			},
+		},
+		ServerInfo: ServerInfo{Name: "policyscript"},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(raw json.RawMessage) (interface{}, error) {
+	var params DidOpenTextDocumentParams
+	if err := unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+	doc := params.TextDocument
+	return nil, s.update(analyze(doc.URI, doc.Version, doc.Text, nil))
+}
+
+func (s *Server) didChange(raw json.RawMessage) (interface{}, error) {
+	var params DidChangeTextDocumentParams
+	if err := unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+	if len(params.ContentChanges) == 0 {
+		return nil, nil
+	}
+
+	// Only full changes are asked for, so the last change is the new text.
+	uri := params.TextDocument.URI
+	text := params.ContentChanges[len(params.ContentChanges)-1].Text
+	return nil, s.update(analyze(uri, params.TextDocument.Version, text, s.docs[uri]))
+}
+
+func (s *Server) didClose(raw json.RawMessage) (interface{}, error) {
+	var params DidCloseTextDocumentParams
+	if err := unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+	delete(s.docs, params.TextDocument.URI)
+
+	// Clear the diagnostics of the closed document.
+	return nil, s.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         params.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// update stores a new version of a document and publishes its diagnostics.
+func (s *Server) update(doc *document) error {
+	s.docs[doc.uri] = doc
+	return s.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Version:     doc.version,
+		Diagnostics: doc.diagnostics(),
+	})
+}
+
+func (d *document) diagnostics() []Diagnostic {
+	diagnostics := make([]Diagnostic, len(d.errs))
+	for i, err := range d.errs {
+		diagnostics[i] = d.diagnostic(err)
+	}
+	return diagnostics
+}
+
+// The LSP severity of each severity of util.Error.
+var severities = map[util.Severity]int{
+	util.SeverityError:   SeverityError,
+	util.SeverityWarning: SeverityWarning,
+	util.SeverityInfo:    SeverityInformation,
+	util.SeverityHint:    SeverityHint,
+}
+
+func (d *document) diagnostic(err *util.Error) Diagnostic {
+	diagnostic := Diagnostic{
+		Range:    d.toRange(&err.Rng),
+		Severity: severities[err.Severity],
+		Code:     string(err.Code),
+		Source:   "policyscript",
+		Message:  err.Msg,
+	}
+	for _, related := range err.Related {
+		diagnostic.RelatedInformation = append(diagnostic.RelatedInformation,
+			DiagnosticRelatedInformation{
+				Location: Location{URI: d.uri, Range: d.toRange(&related.Rng)},
+				Message:  related.Msg,
+			})
+	}
+	return diagnostic
+}
+
+func (s *Server) definition(raw json.RawMessage) (interface{}, error) {
+	doc, params, err := s.position(raw)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return doc.definition(params.Position), nil
+}
+
+func (s *Server) hover(raw json.RawMessage) (interface{}, error) {
+	doc, params, err := s.position(raw)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return doc.hover(params.Position), nil
+}
+
+func (s *Server) completion(raw json.RawMessage) (interface{}, error) {
+	doc, params, err := s.position(raw)
+	if err != nil || doc == nil {
+		return []CompletionItem{}, err
+	}
+	items := doc.completion(params.Position)
+	if items == nil {
+		items = []CompletionItem{}
+	}
+	return items, nil
+}
+
+func (s *Server) documentSymbol(raw json.RawMessage) (interface{}, error) {
+	doc, err := s.document(raw)
+	if err != nil || doc == nil {
+		return []*DocumentSymbol{}, err
+	}
+	symbols := doc.symbols()
+	if symbols == nil {
+		symbols = []*DocumentSymbol{}
+	}
+	return symbols, nil
+}
+
+func (s *Server) semanticTokens(raw json.RawMessage) (interface{}, error) {
+	doc, err := s.document(raw)
+	if err != nil || doc == nil {
+		return &SemanticTokens{Data: []int{}}, err
+	}
+	return doc.semanticTokens(), nil
+}
+
+func (s *Server) codeAction(raw json.RawMessage) (interface{}, error) {
+	var params CodeActionParams
+	if err := unmarshal(raw, &params); err != nil {
+		return []CodeAction{}, err
+	}
+	doc := s.docs[params.TextDocument.URI]
+	if doc == nil {
+		return []CodeAction{}, nil
+	}
+	actions := doc.codeActions(params.Range)
+	if actions == nil {
+		actions = []CodeAction{}
+	}
+	return actions, nil
+}
+
+// document returns the open document of a request, or nil if it is not open.
+func (s *Server) document(raw json.RawMessage) (*document, error) {
+	var params DocumentParams
+	if err := unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+	return s.docs[params.TextDocument.URI], nil
+}
+
+// position returns the open document and position of a request.
+func (s *Server) position(raw json.RawMessage) (*document, *TextDocumentPositionParams, error) {
+	var params TextDocumentPositionParams
+	if err := unmarshal(raw, &params); err != nil {
+		return nil, nil, err
+	}
+	return s.docs[params.TextDocument.URI], &params, nil
+}
+
+func unmarshal(raw json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/lsp"
+	"github.com/policyscript/policyscript/util"
+)
+
+const uri = "file:///demo.law"
+
+const demo = `@enum Age {
+  - young
+  - old
+}
+
+@define Person {
+  # How old the person is.
+  age: Age
+  countries_lived_in: text list
+}
+
+@inputs {
+  person: Person
+}
+
+@outputs {
+  can_read: condition
+}
+
+_ Ability to read
+
+@code {
+  for country in person.countries_lived_in:
+    set can_read to person.age = Age.old
+}
+`
+
+var _ = Describe("Server", func() {
+	var c *client
+
+	BeforeEach(func() {
+		c = newClient()
+		c.request("initialize", map[string]interface{}{}, nil)
+		c.notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "languageId": "policyscript", "version": 1, "text": demo},
+		})
+		Expect(c.diagnostics()).To(BeEmpty())
+	})
+
+	AfterEach(func() {
+		c.request("shutdown", nil, nil)
+		c.notify("exit", nil)
+		Eventually(c.done).Should(Receive(BeNil()))
+	})
+
+	It("publishes diagnostics", func() {
+		c.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []map[string]interface{}{{"text": "@inputs {\n  a: number\n}\n"}},
+		})
+		Expect(c.diagnostics()).To(Equal([]lsp.Diagnostic{{
+			Range:    lsp.Range{Start: lsp.Position{Line: 1, Character: 5}, End: lsp.Position{Line: 1, Character: 11}},
+			Severity: lsp.SeverityError,
+			Code:     "PS4006",
+			Source:   "policyscript",
+			Message:  "unknown type number",
+		}}))
+	})
+
+	It("offers quick fixes and related locations", func() {
+		c.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []map[string]interface{}{{
+				"text": "@inputs {\n  a: integr\n}\n\n@outputs {\n  b: text\n  b: text\n}\n",
+			}},
+		})
+		diagnostics := c.diagnostics()
+		Expect(diagnostics).To(HaveLen(2))
+		Expect(diagnostics[0].Code).To(Equal("PS4006"))
+		Expect(diagnostics[1].Code).To(Equal("PS4003"))
+		Expect(diagnostics[1].RelatedInformation).To(Equal([]lsp.DiagnosticRelatedInformation{{
+			Location: lsp.Location{URI: uri, Range: lsp.Range{
+				Start: lsp.Position{Line: 5, Character: 2}, End: lsp.Position{Line: 5, Character: 9},
+			}},
+			Message: "first declared here",
+		}}))
+
+		var actions []lsp.CodeAction
+		c.request("textDocument/codeAction", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+			"range":        lsp.Range{Start: lsp.Position{Line: 1, Character: 6}, End: lsp.Position{Line: 1, Character: 6}},
+			"context":      map[string]interface{}{"diagnostics": []interface{}{}},
+		}, &actions)
+		Expect(actions).To(HaveLen(1))
+		Expect(actions[0].Title).To(Equal("Change to integer"))
+		Expect(actions[0].Kind).To(Equal(lsp.CodeActionQuickFix))
+		Expect(actions[0].Edit.Changes[uri]).To(Equal([]lsp.TextEdit{{
+			Range:   lsp.Range{Start: lsp.Position{Line: 1, Character: 5}, End: lsp.Position{Line: 1, Character: 11}},
+			NewText: "integer",
+		}}))
+
+		c.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 3},
+			"contentChanges": []map[string]interface{}{{"text": "@outputs {\n  b: text\n}\n\n@code {\n}\n"}},
+		})
+		diagnostics = c.diagnostics()
+		Expect(diagnostics).To(HaveLen(1))
+		Expect(diagnostics[0].Severity).To(Equal(lsp.SeverityWarning))
+		Expect(diagnostics[0].Message).To(Equal("output b is never set"))
+	})
+
+	It("counts characters in UTF-16 code units", func() {
+		c.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []map[string]interface{}{{"text": "@outputs {\n  b: text\n}\n\n@code {\n  set b to `😀€` + nope\n}\n"}},
+		})
+		Expect(c.diagnostics()).To(Equal([]lsp.Diagnostic{{
+			Range:    lsp.Range{Start: lsp.Position{Line: 5, Character: 19}, End: lsp.Position{Line: 5, Character: 23}},
+			Severity: lsp.SeverityError,
+			Code:     "PS4013",
+			Source:   "policyscript",
+			Message:  "nope is not declared",
+		}}))
+
+		var location *lsp.Location
+		c.request("textDocument/definition", at(5, 7), &location)
+		Expect(location.Range).To(Equal(lsp.Range{
+			Start: lsp.Position{Line: 1, Character: 2},
+			End:   lsp.Position{Line: 1, Character: 3},
+		}))
+	})
+
+	It("goes to definitions", func() {
+		definition := func(line, character int) string {
+			var location *lsp.Location
+			c.request("textDocument/definition", at(line, character), &location)
+			if location == nil {
+				return "none"
+			}
+			return fmt.Sprintf("%d:%d-%d", location.Range.Start.Line, location.Range.Start.Character,
+				location.Range.End.Character)
+		}
+
+		Expect(definition(12, 11)).To(Equal("5:8-14")) // Person in @inputs
+		Expect(definition(22, 18)).To(Equal("12:2-8")) // person in code
+		Expect(definition(22, 27)).To(Equal("8:2-20")) // countries_lived_in
+		Expect(definition(23, 28)).To(Equal("7:2-5"))  // age
+		Expect(definition(23, 34)).To(Equal("0:6-9"))  // Age
+		Expect(definition(23, 38)).To(Equal("2:4-7"))  // old
+		Expect(definition(22, 8)).To(Equal("22:6-13")) // country
+		Expect(definition(20, 0)).To(Equal("none"))
+	})
+
+	It("hovers with types and comments", func() {
+		var hover *lsp.Hover
+		c.request("textDocument/hover", at(23, 28), &hover)
+		Expect(hover.Contents.Value).To(Equal("```policyscript\nage: Age\n```\n\nField of `Person`.\n\nHow old the person is."))
+
+		c.request("textDocument/hover", at(22, 8), &hover)
+		Expect(hover.Contents.Value).To(Equal("```policyscript\ncountry: text\n```\n\nLoop variable."))
+
+		c.request("textDocument/hover", at(0, 7), &hover)
+		Expect(hover.Contents.Value).To(Equal("```policyscript\n@enum Age {\n  - young\n  - old\n}\n```"))
+	})
+
+	It("completes names, members and periods", func() {
+		labels := func(text string, line, character int) []string {
+			c.notify("textDocument/didChange", map[string]interface{}{
+				"textDocument":   map[string]interface{}{"uri": uri, "version": 3},
+				"contentChanges": []map[string]interface{}{{"text": text}},
+			})
+			c.diagnostics()
+
+			var items []lsp.CompletionItem
+			c.request("textDocument/completion", at(line, character), &items)
+			var labels []string
+			for _, item := range items {
+				labels = append(labels, item.Label)
+			}
+			return labels
+		}
+
+		Expect(labels(demo+"@code {\n  set can_read to person.\n}\n", 26, 25)).To(
+			Equal([]string{"age", "countries_lived_in"}))
+		Expect(labels(demo+"@code {\n  set can_read to Age.yo\n}\n", 26, 24)).To(
+			Equal([]string{"young", "old"}))
+		Expect(labels(demo+"@code {\n  set can_read to 18 ye\n}\n", 26, 23)).To(
+			ContainElements("year", "years", "seconds"))
+		Expect(labels(demo+"@code {\n  set can_read to \n}\n", 26, 18)).To(
+			ContainElements("person", "can_read", "Age", "Person", "if", "money"))
+	})
+
+	It("lists headings and types as symbols", func() {
+		var symbols []*lsp.DocumentSymbol
+		c.request("textDocument/documentSymbol", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+		}, &symbols)
+		Expect(symbols).To(HaveLen(3))
+		Expect(symbols[0].Name).To(Equal("Age"))
+		Expect(symbols[1].Name).To(Equal("Person"))
+		Expect(symbols[2].Name).To(Equal("Ability to read"))
+		Expect(symbols[2].Range.End).To(Equal(lsp.Position{Line: 24, Character: 1}))
+	})
+
+	It("highlights tokens", func() {
+		var tokens lsp.SemanticTokens
+		c.request("textDocument/semanticTokens/full", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+		}, &tokens)
+
+		// "@enum" is a keyword, "Age" a type and "-" an operator.
+		Expect(tokens.Data[:15]).To(Equal([]int{
+			0, 0, 5, 0, 0,
+			0, 6, 3, 6, 0,
+			1, 2, 1, 3, 0,
+		}))
+	})
+})
+
+var _ = Describe("Server input", func() {
+	util.Each("rejects an invalid Content-Length", [][2]string{
+		{"-5", `invalid Content-Length "-5"`},
+		{"1000000000000", `invalid Content-Length "1000000000000"`},
+		{"five", `invalid Content-Length "five"`},
+	}, func(input, expects string) {
+		var out bytes.Buffer
+		err := lsp.Serve(strings.NewReader("Content-Length: "+input+"\r\n\r\n{}"), &out)
+		Expect(err).To(MatchError(expects))
+		Expect(out.String()).To(BeEmpty())
+	})
+
+	It("answers a message which is not JSON with a null ID", func() {
+		var out bytes.Buffer
+		Expect(lsp.Serve(strings.NewReader("Content-Length: 3\r\n\r\n{x}"), &out)).To(Succeed())
+		Expect(out.String()).To(HavePrefix("Content-Length: "))
+		Expect(out.String()).To(ContainSubstring(`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,`))
+	})
+})
+
+func at(line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}
+}
+
+// client talks to a server over pipes.
+type client struct {
+	w      io.Writer
+	r      *textproto.Reader
+	nextID int
+	done   chan error
+
+	// Notifications received while waiting for a response.
+	pending []map[string]json.RawMessage
+}
+
+func newClient() *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{w: clientOut, r: textproto.NewReader(bufio.NewReader(clientIn)), done: make(chan error, 1)}
+	go func() {
+		c.done <- lsp.Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+	return c
+}
+
+func (c *client) send(msg map[string]interface{}) {
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	Expect(err).NotTo(HaveOccurred())
+	_, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func (c *client) read() map[string]json.RawMessage {
+	header, err := c.r.ReadMIMEHeader()
+	Expect(err).NotTo(HaveOccurred())
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	Expect(err).NotTo(HaveOccurred())
+	body := make([]byte, length)
+	_, err = io.ReadFull(c.r.R, body)
+	Expect(err).NotTo(HaveOccurred())
+
+	var msg map[string]json.RawMessage
+	Expect(json.Unmarshal(body, &msg)).To(Succeed())
+	return msg
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.send(map[string]interface{}{"method": method, "params": params})
+}
+
+// request sends a request and decodes the result into result, if not nil.
+func (c *client) request(method string, params interface{}, result interface{}) {
+	c.nextID++
+	c.send(map[string]interface{}{"id": c.nextID, "method": method, "params": params})
+	for {
+		msg := c.read()
+		if _, ok := msg["id"]; !ok {
+			c.pending = append(c.pending, msg)
+			continue
+		}
+		Expect(msg).NotTo(HaveKey("error"))
+		if result != nil {
+			Expect(json.Unmarshal(msg["result"], result)).To(Succeed())
+		}
+		return
+	}
+}
+
+// diagnostics returns the next published diagnostics.
+func (c *client) diagnostics() []lsp.Diagnostic {
+	var msg map[string]json.RawMessage
+	if len(c.pending) > 0 {
+		msg, c.pending = c.pending[0], c.pending[1:]
+	} else {
+		msg = c.read()
+	}
+	Expect(string(msg["method"])).To(Equal(`"textDocument/publishDiagnostics"`))
+
+	var params lsp.PublishDiagnosticsParams
+	Expect(json.Unmarshal(msg["params"], &params)).To(Succeed())
+	return params.Diagnostics
+}
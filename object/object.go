@@ -0,0 +1,393 @@
+// Package object defines the values produced when evaluating PolicyScript.
+package object
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Type is the identifier for the type of an object.
+	Type string
+
+	// Object is implemented by all values.
+	Object interface {
+		Type() Type
+
+		// Inspect returns the value written as PolicyScript source.
+		Inspect() string
+	}
+)
+
+const (
+	TEXT      Type = "text"
+	INTEGER   Type = "integer"
+	DECIMAL   Type = "decimal"
+	MONEY     Type = "money"
+	PERCENT   Type = "percent"
+	PERIOD    Type = "period"
+	DATE      Type = "date"
+	TIME      Type = "time"
+	CONDITION Type = "condition"
+	LIST      Type = "list"
+	GROUP     Type = "group"
+	ENUM      Type = "enum"
+	UNKNOWN   Type = "unknown"
+)
+
+// Text is a text value.
+type Text struct {
+	Value string
+}
+
+func (o *Text) Type() Type      { return TEXT }
+func (o *Text) Inspect() string { return "`" + o.Value + "`" }
+
+// Integer is a whole number.
+type Integer struct {
+	Value int
+}
+
+func (o *Integer) Type() Type      { return INTEGER }
+func (o *Integer) Inspect() string { return strconv.Itoa(o.Value) }
+
+// Decimal is a number with decimal places.
+type Decimal struct {
+	Value float64
+}
+
+func (o *Decimal) Type() Type      { return DECIMAL }
+func (o *Decimal) Inspect() string { return formatFloat(o.Value) }
+
+// Money is an amount of currency, stored in cents to avoid rounding errors.
+type Money struct {
+	Cents  int64
+	Symbol string
+}
+
+// NewMoney returns money rounded to the nearest cent.
+func NewMoney(amount float64, symbol string) *Money {
+	return &Money{Cents: roundCents(amount), Symbol: symbol}
+}
+
+func (o *Money) Type() Type { return MONEY }
+func (o *Money) Inspect() string {
+	// The magnitude is unsigned, since -math.MinInt64 does not fit in an int64.
+	cents := uint64(o.Cents)
+	sign := ""
+	if o.Cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%s%s.%02d", sign, o.Symbol, groupThousands(cents/100), cents%100)
+}
+
+// Amount returns the amount in whole units of currency.
+func (o *Money) Amount() float64 { return float64(o.Cents) / 100 }
+
+// Percent is a percentage, where a Value of 55 is 55%.
+type Percent struct {
+	Value float64
+}
+
+func (o *Percent) Type() Type { return PERCENT }
+func (o *Percent) Inspect() string {
+	return strconv.FormatFloat(o.Value, 'f', -1, 64) + "%"
+}
+
+// Ratio returns the percentage as a fraction, where 55% is 0.55.
+func (o *Percent) Ratio() float64 { return o.Value / 100 }
+
+// Period is a length of time. Calendar units are kept apart since the length
+// of a month or year depends on the date it is added to.
+type Period struct {
+	Years   int
+	Months  int
+	Days    int
+	Seconds int
+}
+
+// NewPeriod returns a period of value units, where unit is a period keyword
+// such as "days". It returns false if the unit is unknown.
+func NewPeriod(value int, unit string) (*Period, bool) {
+	switch strings.TrimSuffix(unit, "s") {
+	case "year":
+		return &Period{Years: value}, true
+	case "month":
+		return &Period{Months: value}, true
+	case "day":
+		return &Period{Days: value}, true
+	case "hour":
+		return &Period{Seconds: value * 60 * 60}, true
+	case "minute":
+		return &Period{Seconds: value * 60}, true
+	case "second":
+		return &Period{Seconds: value}, true
+	}
+	return nil, false
+}
+
+func (o *Period) Type() Type { return PERIOD }
+func (o *Period) Inspect() string {
+	var parts []string
+	add := func(value int, unit string) {
+		if value == 0 {
+			return
+		}
+		if value != 1 && value != -1 {
+			unit += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", value, unit))
+	}
+	add(o.Years, "year")
+	add(o.Months, "month")
+	add(o.Days, "day")
+	add(o.Seconds, "second")
+	if len(parts) == 0 {
+		return "0 days"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Compare returns -1, 0 or 1 by the length of each period, where a year is
+// 365 days and a month is a twelfth of a year.
+func (o *Period) Compare(other *Period) int {
+	return compareInts(o.length(), other.length())
+}
+
+// length returns the length of the period in twelfths of a second, so that a
+// month is a whole number.
+func (o *Period) length() int {
+	const day = 12 * 24 * 60 * 60
+	return o.Years*365*day + o.Months*365*day/12 + o.Days*day + o.Seconds*12
+}
+
+// Date is a calendar date.
+type Date struct {
+	Year  int
+	Month int
+	Day   int
+}
+
+// NewDate returns the date of t.
+func NewDate(t time.Time) *Date {
+	return &Date{Year: t.Year(), Month: int(t.Month()), Day: t.Day()}
+}
+
+func (o *Date) Type() Type      { return DATE }
+func (o *Date) Inspect() string { return fmt.Sprintf("|%04d/%02d/%02d|", o.Year, o.Month, o.Day) }
+
+// Time returns the date at midnight UTC.
+func (o *Date) Time() time.Time {
+	return time.Date(o.Year, time.Month(o.Month), o.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// Compare returns -1, 0 or 1.
+func (o *Date) Compare(other *Date) int {
+	if o.Year != other.Year {
+		return compareInts(o.Year, other.Year)
+	}
+	if o.Month != other.Month {
+		return compareInts(o.Month, other.Month)
+	}
+	return compareInts(o.Day, other.Day)
+}
+
+// Time is a time of day.
+type Time struct {
+	Hours   int
+	Minutes int
+	Seconds int
+}
+
+func (o *Time) Type() Type { return TIME }
+func (o *Time) Inspect() string {
+	return fmt.Sprintf("|%02d:%02d:%02d|", o.Hours, o.Minutes, o.Seconds)
+}
+
+// Compare returns -1, 0 or 1.
+func (o *Time) Compare(other *Time) int {
+	return compareInts(o.Hours*3600+o.Minutes*60+o.Seconds,
+		other.Hours*3600+other.Minutes*60+other.Seconds)
+}
+
+// Condition is true or false.
+type Condition struct {
+	Value bool
+}
+
+var (
+	TRUE  = &Condition{Value: true}
+	FALSE = &Condition{Value: false}
+)
+
+// NativeCondition returns the shared condition for value.
+func NativeCondition(value bool) *Condition {
+	if value {
+		return TRUE
+	}
+	return FALSE
+}
+
+func (o *Condition) Type() Type { return CONDITION }
+func (o *Condition) Inspect() string {
+	if o.Value {
+		return "true"
+	}
+	return "false"
+}
+
+// List is an ordered list of values.
+type List struct {
+	Elems []Object
+}
+
+func (o *List) Type() Type { return LIST }
+func (o *List) Inspect() string {
+	elems := make([]string, len(o.Elems))
+	for i, elem := range o.Elems {
+		elems[i] = elem.Inspect()
+	}
+	return "{" + strings.Join(elems, ", ") + "}"
+}
+
+// Group is a value of a type declared with @define.
+type Group struct {
+	Name   string
+	Fields map[string]Object
+}
+
+func (o *Group) Type() Type { return GROUP }
+func (o *Group) Inspect() string {
+	names := make([]string, 0, len(o.Fields))
+	for name := range o.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]string, len(names))
+	for i, name := range names {
+		fields[i] = name + ": " + o.Fields[name].Inspect()
+	}
+	return o.Name + " {" + strings.Join(fields, ", ") + "}"
+}
+
+// Enum is a member of a type declared with @enum.
+type Enum struct {
+	Name  string
+	Value string
+}
+
+func (o *Enum) Type() Type      { return ENUM }
+func (o *Enum) Inspect() string { return o.Name + "." + o.Value }
+
+// Unknown is a value which is not known, such as an optional input without
+// a value. Operations on unknown values are unknown, except where the other
+// value decides the result, as in "false and unknown".
+type Unknown struct {
+
+	// Needs are the inputs which would make the value known, sorted, ex:
+	// "date_of_sale". An unknown written in code needs none.
+	Needs []string
+}
+
+// NewUnknown returns an unknown value which needs the inputs that any of
+// values need.
+func NewUnknown(values ...Object) *Unknown {
+	seen := map[string]bool{}
+	unknown := &Unknown{}
+	for _, value := range values {
+		if value, ok := value.(*Unknown); ok {
+			for _, name := range value.Needs {
+				if !seen[name] {
+					seen[name] = true
+					unknown.Needs = append(unknown.Needs, name)
+				}
+			}
+		}
+	}
+	sort.Strings(unknown.Needs)
+	return unknown
+}
+
+func (o *Unknown) Type() Type      { return UNKNOWN }
+func (o *Unknown) Inspect() string { return "unknown" }
+
+// Equal reports whether two objects are of the same type and value.
+func Equal(a, b Object) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a := a.(type) {
+	case *Money:
+		b := b.(*Money)
+		return a.Cents == b.Cents && a.Symbol == b.Symbol
+	case *Period:
+		return a.Compare(b.(*Period)) == 0
+	case *Date:
+		return a.Compare(b.(*Date)) == 0
+	case *Time:
+		return a.Compare(b.(*Time)) == 0
+	case *List:
+		b := b.(*List)
+		if len(a.Elems) != len(b.Elems) {
+			return false
+		}
+		for i := range a.Elems {
+			if !Equal(a.Elems[i], b.Elems[i]) {
+				return false
+			}
+		}
+		return true
+	case *Group:
+		b := b.(*Group)
+		if a.Name != b.Name || len(a.Fields) != len(b.Fields) {
+			return false
+		}
+		for name, value := range a.Fields {
+			other, ok := b.Fields[name]
+			if !ok || !Equal(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Inspect() == b.Inspect()
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func roundCents(amount float64) int64 {
+	cents := amount * 100
+	if cents < 0 {
+		return int64(cents - 0.5)
+	}
+	return int64(cents + 0.5)
+}
+
+func formatFloat(value float64) string {
+	s := strconv.FormatFloat(value, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// groupThousands writes n with "_" between every group of 3 digits.
+func groupThousands(n uint64) string {
+	s := strconv.FormatUint(n, 10)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "_" + s[i:]
+	}
+	return s
+}
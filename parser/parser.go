@@ -2,6 +2,8 @@ package parser
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/policyscript/policyscript/ast"
 	"github.com/policyscript/policyscript/scanner"
@@ -24,6 +26,8 @@ const (
 	SUM             // + or -
 	PRODUCT         // * or /
 	PREFIX          // -X
+	POSTFIX         // X list
+	MEMBER          // X.Y
 )
 
 var precedences = map[token.Type]int{
@@ -40,6 +44,17 @@ var precedences = map[token.Type]int{
 	token.MINUS:  SUM,
 	token.DIV:    PRODUCT,
 	token.MULT:   PRODUCT,
+	token.LIST:   POSTFIX,
+	token.DOT:    MEMBER,
+}
+
+// Precedence returns how tightly an operator binds, from LOWEST to MEMBER.
+// Tokens which are not operators have the LOWEST precedence.
+func Precedence(t token.Type) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
 }
 
 type Parser struct {
@@ -62,9 +77,32 @@ func New(s scanner.Scanner) *Parser {
 
 	p.prefixParseFns = make(map[token.Type]prefixParseFn)
 	p.prefixParseFns[token.IDENT] = p.parseIdentifier
+	p.prefixParseFns[token.TEXT] = p.parseTextLiteral
+	p.prefixParseFns[token.INTEGER] = p.parseIntegerLiteral
+	p.prefixParseFns[token.DECIMAL] = p.parseDecimalLiteral
+	p.prefixParseFns[token.MONEY] = p.parseMoneyLiteral
+	p.prefixParseFns[token.PERCENT] = p.parsePercentLiteral
+	p.prefixParseFns[token.PERIOD] = p.parsePeriodLiteral
+	p.prefixParseFns[token.DATE] = p.parseDateLiteral
+	p.prefixParseFns[token.TIME] = p.parseTimeLiteral
+	p.prefixParseFns[token.TRUE] = p.parseConditionLiteral
+	p.prefixParseFns[token.FALSE] = p.parseConditionLiteral
+	p.prefixParseFns[token.UNKNOWN] = p.parseUnknown
+	p.prefixParseFns[token.MINUS] = p.parsePrefixExpression
+	p.prefixParseFns[token.LPAREN] = p.parseGroupedExpression
 
 	p.infixParseFns = make(map[token.Type]infixParseFn)
 	p.infixParseFns[token.COLON] = p.parseDeclare
+	p.infixParseFns[token.LIST] = p.parseListType
+	p.infixParseFns[token.DOT] = p.parseMemberExpression
+	for _, t := range []token.Type{
+		token.OR, token.AND,
+		token.EQ, token.NOT_EQ,
+		token.LT, token.GT, token.LT_EQ, token.GT_EQ,
+		token.PLUS, token.MINUS, token.DIV, token.MULT,
+	} {
+		p.infixParseFns[t] = p.parseInfixExpression
+	}
 
 	return p
 }
@@ -80,11 +118,35 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+	attachDocs(program.Stmts)
 	return program
 }
 
+// ParseStatements parses the statements of a file scanned by
+// scanner.NewBlock, which holds the inside of a block.
+func (p *Parser) ParseStatements() []ast.Stmt {
+	var stmts []ast.Stmt
+	for !p.curTokenIs(token.EOF) {
+		if !p.curTokenIs(token.SEMI) {
+			if stmt := p.parseStatement(); stmt != nil {
+				stmts = append(stmts, stmt)
+			}
+		}
+		p.nextToken()
+	}
+	attachDocs(stmts)
+	return stmts
+}
+
 func (p *Parser) parseStatement() ast.Stmt {
 	switch p.curToken.Type {
+	case token.HEADING:
+		return p.parseHeading()
+	case token.PARAGRAPH:
+		return p.parseParagraph()
+	case token.META, token.DEFINE, token.ENUM, token.INPUTS,
+		token.OUTPUTS, token.LOCALS, token.CODE:
+		return p.parseBlockStatement()
 	case token.COMMENT:
 		return p.parseComment()
 	case token.IF:
@@ -99,50 +161,417 @@ func (p *Parser) parseStatement() ast.Stmt {
 	return p.parseExpressionStatement()
 }
 
+func (p *Parser) parseHeading() ast.Stmt {
+	var (
+		stmt  = &ast.HeadingStatement{Token: p.curToken}
+		value = p.curToken.Literal
+	)
+
+	// Each "_ " prefix increases the depth by one.
+	for strings.HasPrefix(value, "_ ") {
+		stmt.Depth++
+		value = strings.TrimLeft(value[1:], " \t")
+	}
+
+	stmt.Value = value
+	return stmt
+}
+
+func (p *Parser) parseParagraph() ast.Stmt {
+	return &ast.ParagraphStatement{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseBlockStatement() ast.Stmt {
+	block := &ast.BlockStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		block.Ident = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	switch {
+	case block.Ident == nil && (block.Token.Type == token.DEFINE || block.Token.Type == token.ENUM):
+		p.errors.Add(fmt.Sprintf("%s must be followed by a type name", block.Token.Type),
+			&block.Token.Range).WithCode(util.CodeUnnamedType)
+	case block.Ident != nil && block.Token.Type != token.DEFINE && block.Token.Type != token.ENUM:
+		p.errors.Add(fmt.Sprintf("%s can not be named", block.Token.Type),
+			block.Ident.Range()).WithCode(util.CodeNamedBlock).
+			WithFix("Remove "+block.Ident.Value, util.Replace(&util.Range{
+				Start: block.Token.Range.End, End: block.Ident.Range().End,
+			}, ""))
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		if !p.curTokenIs(token.SEMI) {
+			if stmt := p.parseStatement(); stmt != nil {
+				block.Stmts = append(block.Stmts, stmt)
+			}
+		}
+		p.nextToken()
+	}
+
+	// A missing "}" is reported by the scanner.
+	block.End = p.curToken
+	attachDocs(block.Stmts)
+	return block
+}
+
 func (p *Parser) parseComment() ast.Stmt {
-	// TODO: implement
-	return nil
+	return &ast.CommentStatement{Token: p.curToken, Value: p.curToken.Literal}
 }
 
 func (p *Parser) parseIfStatement() ast.Stmt {
-	// TODO: implement
-	return nil
+	stmt := &ast.IfStatement{Token: p.curToken}
+
+	p.nextToken()
+	if stmt.Condition = p.parseCondition(); stmt.Condition == nil {
+		p.skipStatement()
+		return nil
+	}
+
+	if stmt.Block = p.parseScopeStatement(stmt.Token); stmt.Block == nil {
+		return nil
+	}
+	return stmt
 }
 
 func (p *Parser) parseElseStatement() ast.Stmt {
-	// TODO: implement
-	return nil
+	stmt := &ast.ElseStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		if stmt.Condition = p.parseCondition(); stmt.Condition == nil {
+			p.skipStatement()
+			return nil
+		}
+	}
+
+	if stmt.Block = p.parseScopeStatement(stmt.Token); stmt.Block == nil {
+		return nil
+	}
+	return stmt
 }
 
 func (p *Parser) parseForStatement() ast.Stmt {
-	// TODO: implement
-	return nil
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		p.skipStatement()
+		return nil
+	}
+	stmt.Ident = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		p.skipStatement()
+		return nil
+	}
+
+	p.nextToken()
+	if stmt.Iter = p.parseCondition(); stmt.Iter == nil {
+		p.skipStatement()
+		return nil
+	}
+
+	if stmt.Block = p.parseScopeStatement(stmt.Token); stmt.Block == nil {
+		return nil
+	}
+	return stmt
+}
+
+// parseCondition parses the expression before the ":" of an if, else or for
+// statement.
+func (p *Parser) parseCondition() ast.Expr {
+	return p.parseExpression(ASSIGN)
+}
+
+// parseScopeStatement parses a ":" followed by every statement which is
+// indented further than the statement that owns the scope.
+func (p *Parser) parseScopeStatement(owner token.Token) *ast.ScopeStatement {
+	if !p.expectPeek(token.COLON) {
+		p.skipStatement()
+		return nil
+	}
+
+	var (
+		scope  = &ast.ScopeStatement{}
+		column = owner.Range.Start.Column
+	)
+
+	for {
+		for p.peekTokenIs(token.SEMI) {
+			p.nextToken()
+		}
+		if p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) ||
+			p.peekToken.Range.Start.Column <= column {
+			break
+		}
+
+		p.nextToken()
+		if stmt := p.parseStatement(); stmt != nil {
+			scope.Stmts = append(scope.Stmts, stmt)
+		}
+	}
+
+	if len(scope.Stmts) == 0 {
+		p.errors.Add(fmt.Sprintf("expected an indented statement after %s", owner.Type),
+			&owner.Range).WithCode(util.CodeEmptyScope)
+		return nil
+	}
+	attachDocs(scope.Stmts)
+	return scope
+}
+
+// attachDocs sets the documentation of each statement directly below a
+// comment, without a blank line between them, to the text of the comment.
+func attachDocs(stmts []ast.Stmt) {
+	for i := 1; i < len(stmts); i++ {
+		comment, ok := stmts[i-1].(*ast.CommentStatement)
+		if !ok || comment.Range().End.Line+1 != stmts[i].Range().Start.Line {
+			continue
+		}
+
+		switch stmt := stmts[i].(type) {
+		case *ast.ExpressionStatement:
+			stmt.Doc = comment.Text()
+		case *ast.BlockStatement:
+			stmt.Doc = comment.Text()
+		case *ast.IfStatement:
+			stmt.Doc = comment.Text()
+		case *ast.ElseStatement:
+			stmt.Doc = comment.Text()
+		case *ast.ForStatement:
+			stmt.Doc = comment.Text()
+		}
+	}
 }
 
 func (p *Parser) parseSetStatement() ast.Stmt {
-	// TODO: implement
-	return nil
+	exp := &ast.SetExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		p.skipStatement()
+		return nil
+	}
+	exp.Ident = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.TO) {
+		p.skipStatement()
+		return nil
+	}
+	p.nextToken()
+
+	if exp.Value = p.parseExpression(LOWEST); exp.Value == nil {
+		p.skipStatement()
+		return nil
+	}
+	p.expectEnd()
+
+	return &ast.ExpressionStatement{Token: exp.Token, Expr: exp}
 }
 
 func (p *Parser) parseExpressionStatement() ast.Stmt {
-	// TODO: implement
-	return nil
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+
+	if stmt.Expr = p.parseExpression(LOWEST); stmt.Expr == nil {
+		p.skipStatement()
+		return nil
+	}
+	p.expectEnd()
+
+	return stmt
 }
 
 func (p *Parser) parseIdentifier() ast.Expr {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseTextLiteral() ast.Expr {
+	return &ast.TextLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseIntegerLiteral() ast.Expr {
+	lit := &ast.IntegerLiteral{Token: p.curToken}
+
+	value, err := strconv.Atoi(stripUnderscores(p.curToken.Literal))
+	if err != nil {
+		p.errors.Add(fmt.Sprintf("could not parse %q as integer", p.curToken.Literal),
+			&p.curToken.Range).WithCode(util.CodeInvalidNumber)
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseDecimalLiteral() ast.Expr {
+	lit := &ast.DecimalLiteral{Token: p.curToken}
+	lit.Value = p.parseFloat(p.curToken.Literal, "decimal")
+	return lit
+}
+
+func (p *Parser) parseMoneyLiteral() ast.Expr {
+	var (
+		lit    = &ast.MoneyLiteral{Token: p.curToken}
+		runes  = []rune(p.curToken.Literal)
+		symbol = string(runes[:1])
+	)
+
+	lit.Symbol = symbol
+	lit.Value = p.parseFloat(string(runes[1:]), "money")
+	return lit
+}
+
+func (p *Parser) parsePercentLiteral() ast.Expr {
+	lit := &ast.PercentLiteral{Token: p.curToken}
+	lit.Value = p.parseFloat(strings.TrimSuffix(p.curToken.Literal, "%"), "percent")
+	return lit
+}
+
+func (p *Parser) parsePeriodLiteral() ast.Expr {
+	var (
+		lit    = &ast.PeriodLiteral{Token: p.curToken}
+		fields = strings.Fields(p.curToken.Literal)
+	)
+
+	if len(fields) != 2 {
+		p.errors.Add(fmt.Sprintf("could not parse %q as period", p.curToken.Literal),
+			&p.curToken.Range).WithCode(util.CodeInvalidPeriod)
+		return lit
+	}
+
+	value, err := strconv.Atoi(stripUnderscores(fields[0]))
+	if err != nil {
+		p.errors.Add(fmt.Sprintf("could not parse %q as period", p.curToken.Literal),
+			&p.curToken.Range).WithCode(util.CodeInvalidPeriod)
+	}
+
+	lit.Value = value
+	lit.Symbol = fields[1]
+	return lit
+}
+
+func (p *Parser) parseDateLiteral() ast.Expr {
+	lit := &ast.DateLiteral{Token: p.curToken}
+
+	parts, ok := p.splitPiped(p.curToken.Literal, "/", 3, 3)
+	if !ok {
+		p.errors.Add(fmt.Sprintf("could not parse %q as date, expected |yyyy/mm/dd|",
+			p.curToken.Literal), &p.curToken.Range).WithCode(util.CodeInvalidDateValue)
+		return lit
+	}
+
+	lit.Year, lit.Month, lit.Day = parts[0], parts[1], parts[2]
+	if lit.Month < 1 || lit.Month > 12 || lit.Day < 1 || lit.Day > daysIn(lit.Year, lit.Month) {
+		p.errors.Add(fmt.Sprintf("%q is not a valid date", p.curToken.Literal),
+			&p.curToken.Range).WithCode(util.CodeInvalidDateValue)
+	}
+	return lit
+}
+
+func (p *Parser) parseTimeLiteral() ast.Expr {
+	lit := &ast.TimeLiteral{Token: p.curToken}
+
+	parts, ok := p.splitPiped(p.curToken.Literal, ":", 2, 3)
+	if !ok {
+		p.errors.Add(fmt.Sprintf("could not parse %q as time, expected |hh:mm:ss|",
+			p.curToken.Literal), &p.curToken.Range).WithCode(util.CodeInvalidTimeValue)
+		return lit
+	}
+
+	lit.Hours, lit.Minutes = parts[0], parts[1]
+	if len(parts) == 3 {
+		lit.Seconds = parts[2]
+	}
+	if lit.Hours > 23 || lit.Minutes > 59 || lit.Seconds > 59 {
+		p.errors.Add(fmt.Sprintf("%q is not a valid time", p.curToken.Literal),
+			&p.curToken.Range).WithCode(util.CodeInvalidTimeValue)
+	}
+	return lit
+}
+
+func (p *Parser) parseConditionLiteral() ast.Expr {
+	return &ast.Condition{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
+}
+
+func (p *Parser) parseUnknown() ast.Expr {
+	return &ast.Unknown{Token: p.curToken}
+}
+
+func (p *Parser) parsePrefixExpression() ast.Expr {
+	exp := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
+
+	p.nextToken()
+	if exp.Right = p.parseExpression(PREFIX); exp.Right == nil {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseGroupedExpression() ast.Expr {
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+	if exp == nil || !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseInfixExpression(left ast.Expr) ast.Expr {
+	exp := &ast.InfixExpression{Token: p.curToken, Left: left, Operator: p.curToken.Literal}
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	if exp.Right = p.parseExpression(precedence); exp.Right == nil {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseMemberExpression(left ast.Expr) ast.Expr {
+	exp := &ast.MemberExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Ident = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+func (p *Parser) parseListType(left ast.Expr) ast.Expr {
+	return &ast.ListType{Token: p.curToken, Elem: left}
+}
+
 func (p *Parser) parseDeclare(left ast.Expr) ast.Expr {
 	name, ok := left.(*ast.Identifier)
 	if !ok {
-		p.errors.Add("left side of : must be a variable", left.Range())
+		p.errors.Add("left side of : must be a variable", left.Range()).
+			WithCode(util.CodeInvalidDeclare)
 		return nil
 	}
 	exp := &ast.DeclareExpression{Token: p.curToken, Ident: name}
 
+	// The value of an optional input follows "or", ex: "rate: percent or 15%".
 	p.nextToken()
-	exp.Value = p.parseExpression(LOWEST)
+	if exp.Value = p.parseExpression(OR); exp.Value == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.OR) {
+		p.nextToken()
+		p.nextToken()
+		if exp.Default = p.parseExpression(OR); exp.Default == nil {
+			return nil
+		}
+	}
 
 	return exp
 }
@@ -151,12 +580,13 @@ func (p *Parser) parseExpression(precedence int) ast.Expr {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.errors.Add(fmt.Sprintf("no prefix parse function for %s", p.curToken.Type),
-			&p.curToken.Range)
+			&p.curToken.Range).WithCode(util.CodeUnexpectedToken)
+		return nil
 	}
 
 	leftExp := prefix()
 
-	for !p.peekTokenIs(token.SEMI) && precedence < p.peekPrecedence() {
+	for leftExp != nil && !p.peekTokenIs(token.SEMI) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
@@ -168,6 +598,58 @@ func (p *Parser) parseExpression(precedence int) ast.Expr {
 	return leftExp
 }
 
+func (p *Parser) parseFloat(literal string, kind string) float32 {
+	value, err := strconv.ParseFloat(stripUnderscores(literal), 32)
+	if err != nil {
+		p.errors.Add(fmt.Sprintf("could not parse %q as %s", p.curToken.Literal, kind),
+			&p.curToken.Range).WithCode(util.CodeInvalidNumber)
+	}
+	return float32(value)
+}
+
+// splitPiped splits a literal of the form |a<sep>b<sep>c| into integers.
+func (p *Parser) splitPiped(literal, sep string, min, max int) ([]int, bool) {
+	parts := strings.Split(strings.Trim(literal, "|"), sep)
+	if len(parts) < min || len(parts) > max {
+		return nil, false
+	}
+
+	values := make([]int, len(parts))
+	for i, part := range parts {
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		values[i] = value
+	}
+	return values, true
+}
+
+// expectEnd reports an error unless the current statement is followed by the
+// end of a line or block.
+func (p *Parser) expectEnd() {
+	switch p.peekToken.Type {
+	case token.SEMI, token.RBRACE, token.EOF:
+		return
+	case token.COMMENT:
+		// The comment is read as a statement of its own, which the
+		// scanner ends with the line.
+		p.errors.Add("a comment must be on a line of its own", &p.peekToken.Range).
+			WithCode(util.CodeUnexpectedEnd)
+		return
+	}
+	p.errors.Add(fmt.Sprintf("unexpected %s at end of statement", p.peekToken.Type),
+		&p.peekToken.Range).WithCode(util.CodeUnexpectedEnd)
+	p.skipStatement()
+}
+
+// skipStatement advances until the end of the current line or block.
+func (p *Parser) skipStatement() {
+	for !p.peekTokenIs(token.SEMI) && !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
+	}
+}
+
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = *p.s.NextToken()
@@ -181,10 +663,39 @@ func (p *Parser) peekTokenIs(t token.Type) bool {
 	return p.peekToken.Type == t
 }
 
-func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
-		return p
+func (p *Parser) expectPeek(t token.Type) bool {
+	if p.peekTokenIs(t) {
+		p.nextToken()
+		return true
 	}
+	p.errors.Add(fmt.Sprintf("expected %q, found %s", t, p.peekToken.Type),
+		&p.peekToken.Range).WithCode(util.CodeExpectedToken)
+	return false
+}
 
-	return LOWEST
+func (p *Parser) peekPrecedence() int {
+	return Precedence(p.peekToken.Type)
+}
+
+func (p *Parser) curPrecedence() int {
+	return Precedence(p.curToken.Type)
+}
+
+// stripUnderscores removes the underscores, and the commas of money, which
+// group the digits of a literal.
+func stripUnderscores(literal string) string {
+	return strings.NewReplacer("_", "", ",", "").Replace(literal)
+}
+
+func daysIn(year, month int) int {
+	switch month {
+	case 2:
+		if year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+			return 29
+		}
+		return 28
+	case 4, 6, 9, 11:
+		return 30
+	}
+	return 31
 }
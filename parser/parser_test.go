@@ -0,0 +1,259 @@
+package parser_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/parser"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/util"
+)
+
+var _ = Describe("Parser", func() {
+	util.Each("can parse heading", [][2]string{
+		{"_ A", "1 A"},
+		{"_ _ A", "2 A"},
+		{"_ _  A", "2 A"},
+		{"_ _A", "1 _A"},
+		{"_ (a) A\nB", "1 (a) A\nB"},
+	}, func(input, expects string) {
+		program, errs := parse(input)
+		Expect(errs).To(BeEmpty())
+		Expect(program.Stmts).To(HaveLen(1))
+
+		heading, ok := program.Stmts[0].(*ast.HeadingStatement)
+		Expect(ok).To(BeTrue())
+		Expect(fmt.Sprintf("%d %s", heading.Depth, heading.Value)).To(Equal(expects))
+	})
+
+	util.Each("can parse literal", [][2]string{
+		{"`A b`", "text A b"},
+		{"50_000_000", "integer 50000000"},
+		{"24.48", "decimal 24.48"},
+		{"$5_000.50", "money $ 5000.5"},
+		{"55%", "percent 55"},
+		{"4 days", "period 4 days"},
+		{"70_000  years", "period 70000 years"},
+		{"|2021/01/15|", "date 2021 1 15"},
+		{"|23:59:59|", "time 23 59 59"},
+		{"|08:30|", "time 8 30 0"},
+		{"true", "condition true"},
+		{"false", "condition false"},
+		{"unknown", "unknown"},
+	}, func(input, expects string) {
+		program, errs := parse("@meta {\n  set value to " + input + "\n}")
+		Expect(errs).To(BeEmpty())
+
+		Expect(describe(setValue(program))).To(Equal(expects))
+	})
+
+	util.Each("can parse expression", [][2]string{
+		{"a + b * c", "(a + (b * c))"},
+		{"(a + b) * c", "((a + b) * c)"},
+		{"-a * b", "((-a) * b)"},
+		{"a < b and b < c or d", "(((a < b) and (b < c)) or d)"},
+		{"a = b != c", "((a = b) != c)"},
+		{"person.age = Age.young", "((person.age) = (Age.young))"},
+		{"a.b.c * 2", "(((a.b).c) * integer 2)"},
+		{"a or unknown and b", "(a or (unknown and b))"},
+	}, func(input, expects string) {
+		program, errs := parse("@code {\n  set value to " + input + "\n}")
+		Expect(errs).To(BeEmpty())
+
+		Expect(describe(setValue(program))).To(Equal(expects))
+	})
+
+	util.Each("reports error", [][2]string{
+		{"@define {\n}", "@define must be followed by a type name"},
+		{"@inputs Person {\n}", "@inputs can not be named"},
+		{"@meta {\n  set date to |2021/02/30|\n}", `"|2021/02/30|" is not a valid date`},
+		{"@meta {\n  set time to |24:00:00|\n}", `"|24:00:00|" is not a valid time`},
+		{"@meta {\n  set a b\n}", `expected "to", found identifier`},
+		{"@meta {\n  set a to b c\n}", "unexpected identifier at end of statement"},
+		{"@inputs {\n  a: integer # A.\n}", "a comment must be on a line of its own"},
+		{"@code {\n  if a:\n  set b to c\n}", "expected an indented statement after if"},
+		{"@code {\n  for a b:\n    set b to c\n}", `expected "in", found identifier`},
+		{"@code {\n  if a\n    set b to c\n}", `expected ":", found ;`},
+	}, func(input, expects string) {
+		_, errs := parse(input)
+		Expect(errs).NotTo(BeEmpty())
+		Expect(errs[0].Msg).To(Equal(expects))
+	})
+
+	It("can parse a block with declarations", func() {
+		program, errs := parse("# A comment\n@define Person {\n  is_alive: condition\n  countries: text list\n}")
+		Expect(errs).To(BeEmpty())
+		Expect(program.Stmts).To(HaveLen(2))
+
+		Expect(program.Stmts[0]).To(BeAssignableToTypeOf(&ast.CommentStatement{}))
+
+		block, ok := program.Stmts[1].(*ast.BlockStatement)
+		Expect(ok).To(BeTrue())
+		Expect(block.Token.Type).To(Equal(token.DEFINE))
+		Expect(block.Ident.Value).To(Equal("Person"))
+		Expect(block.End.Type).To(Equal(token.RBRACE))
+		Expect(block.Stmts).To(HaveLen(2))
+
+		declares := []string{}
+		for _, stmt := range block.Stmts {
+			declares = append(declares, describe(stmt.(*ast.ExpressionStatement).Expr))
+		}
+		Expect(declares).To(Equal([]string{
+			"is_alive: condition",
+			"countries: (text list)",
+		}))
+	})
+})
+
+var _ = Describe("Parser defaults", func() {
+	It("can parse optional inputs with defaults", func() {
+		program, errs := parse("@inputs {\n  rate: percent or 10% + 5%\n  sold: date list or unknown\n  born: date\n}")
+		Expect(errs).To(BeEmpty())
+
+		block := program.Stmts[0].(*ast.BlockStatement)
+		declares := []string{}
+		for _, stmt := range block.Stmts {
+			declares = append(declares, describe(stmt.(*ast.ExpressionStatement).Expr))
+		}
+		Expect(declares).To(Equal([]string{
+			"rate: percent or (percent 10 + percent 5)",
+			"sold: (date list) or unknown",
+			"born: date",
+		}))
+		Expect(block.Stmts[0].Range().End.Column).To(Equal(27))
+	})
+})
+
+var _ = Describe("Parser statements", func() {
+	It("parses the inside of a block", func() {
+		s := scanner.NewBlock(util.NewFile("", []byte("n: integer\nif n > 1:\n  set n to 1\nn")), nil)
+		p := parser.New(*s)
+		stmts := p.ParseStatements()
+		Expect(p.Errors()).To(BeEmpty())
+		Expect(stmts).To(HaveLen(3))
+		Expect(stmts[0].(*ast.ExpressionStatement).Expr).To(BeAssignableToTypeOf(&ast.DeclareExpression{}))
+		Expect(stmts[1]).To(BeAssignableToTypeOf(&ast.IfStatement{}))
+		Expect(stmts[2].(*ast.ExpressionStatement).Expr).To(BeAssignableToTypeOf(&ast.Identifier{}))
+	})
+})
+
+var _ = Describe("Parser documentation", func() {
+	It("attaches the comment directly above a statement", func() {
+		program, errs := parse("# Type.\n@define A {\n  # Field\n  #  b.\n  b: text\n\n  # Apart.\n\n  c: text\n}\n\n" +
+			"@code {\n  # Loop.\n  for d in e:\n    # If.\n    if d:\n      set f to d\n    # Else.\n    else:\n      # Set.\n      set f to e\n}")
+		Expect(errs).To(BeEmpty())
+
+		block := program.Stmts[1].(*ast.BlockStatement)
+		Expect(block.Doc).To(Equal("Type."))
+		Expect(block.Stmts[1].(*ast.ExpressionStatement).Doc).To(Equal("Field\nb."))
+		Expect(block.Stmts[3].(*ast.ExpressionStatement).Doc).To(BeEmpty())
+
+		loop := program.Stmts[2].(*ast.BlockStatement).Stmts[1].(*ast.ForStatement)
+		Expect(loop.Doc).To(Equal("Loop."))
+		Expect(loop.Block.Stmts[1].(*ast.IfStatement).Doc).To(Equal("If."))
+		Expect(loop.Block.Stmts[3].(*ast.ElseStatement).Doc).To(Equal("Else."))
+		Expect(loop.Block.Stmts[3].(*ast.ElseStatement).Block.Stmts[1].(*ast.ExpressionStatement).Doc).To(
+			Equal("Set."))
+	})
+})
+
+var _ = Describe("Parser scopes", func() {
+	It("can parse indented if, else and for statements", func() {
+		program, errs := parse(`@code {
+  set found to false
+  for country in person.countries:
+    # Check the country.
+    if country = ` + "`Canada`" + `:
+      set found to true
+    else if country = ` + "`USA`" + `:
+      set found to false
+    else:
+      set other to true
+  set done to true
+}`)
+		Expect(errs).To(BeEmpty())
+
+		block := program.Stmts[0].(*ast.BlockStatement)
+		Expect(block.Stmts).To(HaveLen(3))
+
+		loop, ok := block.Stmts[1].(*ast.ForStatement)
+		Expect(ok).To(BeTrue())
+		Expect(loop.Ident.Value).To(Equal("country"))
+		Expect(describe(loop.Iter)).To(Equal("(person.countries)"))
+		Expect(loop.Block.Stmts).To(HaveLen(4))
+
+		ifStmt := loop.Block.Stmts[1].(*ast.IfStatement)
+		Expect(describe(ifStmt.Condition)).To(Equal("(country = text Canada)"))
+		Expect(ifStmt.Block.Stmts).To(HaveLen(1))
+
+		elseIf := loop.Block.Stmts[2].(*ast.ElseStatement)
+		Expect(describe(elseIf.Condition)).To(Equal("(country = text USA)"))
+
+		elseStmt := loop.Block.Stmts[3].(*ast.ElseStatement)
+		Expect(elseStmt.Condition).To(BeNil())
+		Expect(elseStmt.Block.Stmts).To(HaveLen(1))
+
+		Expect(block.Stmts[2]).To(BeAssignableToTypeOf(&ast.ExpressionStatement{}))
+	})
+})
+
+func parse(input string) (*ast.Program, util.ErrorList) {
+	var errs util.ErrorList
+	s := scanner.New(util.NewFile("", []byte(input)), func(err *util.Error) {
+		errs = append(errs, err)
+	})
+	p := parser.New(*s)
+	program := p.ParseProgram()
+	return program, append(errs, p.Errors()...)
+}
+
+// setValue returns the value of the first set statement in the first block.
+func setValue(program *ast.Program) ast.Expr {
+	block := program.Stmts[0].(*ast.BlockStatement)
+	return block.Stmts[0].(*ast.ExpressionStatement).Expr.(*ast.SetExpression).Value
+}
+
+// describe returns a short description of an expression for comparison.
+func describe(exp ast.Expr) string {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return exp.Value
+	case *ast.TextLiteral:
+		return "text " + exp.Value
+	case *ast.IntegerLiteral:
+		return fmt.Sprintf("integer %d", exp.Value)
+	case *ast.DecimalLiteral:
+		return fmt.Sprintf("decimal %v", exp.Value)
+	case *ast.MoneyLiteral:
+		return fmt.Sprintf("money %s %v", exp.Symbol, exp.Value)
+	case *ast.PercentLiteral:
+		return fmt.Sprintf("percent %v", exp.Value)
+	case *ast.PeriodLiteral:
+		return fmt.Sprintf("period %d %s", exp.Value, exp.Symbol)
+	case *ast.DateLiteral:
+		return fmt.Sprintf("date %d %d %d", exp.Year, exp.Month, exp.Day)
+	case *ast.TimeLiteral:
+		return fmt.Sprintf("time %d %d %d", exp.Hours, exp.Minutes, exp.Seconds)
+	case *ast.Condition:
+		return fmt.Sprintf("condition %t", exp.Value)
+	case *ast.Unknown:
+		return "unknown"
+	case *ast.MemberExpression:
+		return "(" + describe(exp.Left) + "." + describe(exp.Ident) + ")"
+	case *ast.PrefixExpression:
+		return "(" + exp.Operator + describe(exp.Right) + ")"
+	case *ast.InfixExpression:
+		return "(" + describe(exp.Left) + " " + exp.Operator + " " + describe(exp.Right) + ")"
+	case *ast.DeclareExpression:
+		if exp.Default != nil {
+			return describe(exp.Ident) + ": " + describe(exp.Value) + " or " + describe(exp.Default)
+		}
+		return describe(exp.Ident) + ": " + describe(exp.Value)
+	case *ast.ListType:
+		return "(" + describe(exp.Elem) + " list)"
+	}
+	return fmt.Sprintf("%T", exp)
+}
@@ -0,0 +1,155 @@
+// Package policyscript embeds PolicyScript in Go programs. A policy is
+// compiled once from its files, and then evaluated with many sets of inputs:
+//
+//	files := util.NewFileSet()
+//	files.AddFile("benefits.law", source)
+//	policy, err := policyscript.Compile(files)
+//	...
+//	outputs, err := policy.Evaluate(ctx, policyscript.Inputs{"age": 67})
+//
+// A compiled policy is never changed, so it may be evaluated by many
+// goroutines at once.
+package policyscript
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/citation"
+	"github.com/policyscript/policyscript/evaluator"
+	"github.com/policyscript/policyscript/object"
+	"github.com/policyscript/policyscript/types"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Inputs are the values of the inputs of a policy, by name, in the form read
+// from JSON or YAML by types.Decode, ex: {"salary": "$50_000.00"}.
+type Inputs map[string]interface{}
+
+// Outputs are the values of the outputs which a policy set, by name, in the
+// form written by types.Encode, where an output set to an unknown value is
+// nil.
+type Outputs map[string]interface{}
+
+// Policy is a compiled policy.
+type Policy struct {
+	program  *ast.Program
+	info     *types.Info
+	warnings util.ErrorList
+}
+
+// Error is the error of Compile and Evaluate, with the diagnostics which
+// caused it.
+type Error struct {
+	Diagnostics util.ErrorList
+}
+
+// Error returns the first diagnostic, and how many others there are.
+func (e *Error) Error() string {
+	switch len(e.Diagnostics) {
+	case 0:
+		return "policyscript: failed"
+	case 1:
+		return e.Diagnostics[0].Error()
+	default:
+		return fmt.Sprintf("%s (and %d more)", e.Diagnostics[0].Error(), len(e.Diagnostics)-1)
+	}
+}
+
+// Compile parses the files of a policy and checks its types. The files are
+// read in order, as if they were one file, so a type may be defined in one
+// file and used in another. If any file has an error, the error is an *Error
+// with every diagnostic, including warnings.
+func Compile(files *util.FileSet) (*Policy, error) {
+	program := &ast.Program{}
+	var errs util.ErrorList
+	for _, file := range files.Files() {
+		p, fileErrs := citation.Parse(file)
+		program.Stmts = append(program.Stmts, p.Stmts...)
+		errs = append(errs, fileErrs...)
+	}
+	if errs.HasErrors() {
+		return nil, fail(errs)
+	}
+
+	info, checkErrs := types.Check(program)
+	if errs = append(errs, checkErrs...); errs.HasErrors() {
+		return nil, fail(errs)
+	}
+	errs.Dedupe()
+	return &Policy{program: program, info: info, warnings: errs}, nil
+}
+
+func fail(errs util.ErrorList) *Error {
+	errs.Dedupe()
+	return &Error{Diagnostics: errs}
+}
+
+// Program returns the files of the policy as one program, with citations
+// resolved. It must not be changed.
+func (p *Policy) Program() *ast.Program {
+	return p.program
+}
+
+// Info returns the types of the policy, and its declared fields. It must not
+// be changed.
+func (p *Policy) Info() *types.Info {
+	return p.info
+}
+
+// Warnings returns the warnings of the files of the policy, ex: an output
+// which is never set.
+func (p *Policy) Warnings() util.ErrorList {
+	return append(util.ErrorList(nil), p.warnings...)
+}
+
+// Validate returns every problem with inputs, without running the policy,
+// each with a JSON pointer to the value, ex: "/person/children/2/born".
+func (p *Policy) Validate(inputs Inputs) []*types.Problem {
+	return types.Validate(p.info, inputs)
+}
+
+// Evaluate runs the policy with the given inputs and returns its outputs. If
+// an input is missing, unknown or of the wrong type, or the policy fails while
+// running, the error is an *Error, which has every problem with the inputs.
+// If an unknown value decides which branch to take, the error is an *Error
+// whose code is util.CodeUndetermined, ex: "outcome undetermined: need
+// date_of_sale". If ctx is done before the policy finishes, the error is
+// that of ctx.
+func (p *Policy) Evaluate(ctx context.Context, inputs Inputs) (Outputs, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	values, errs := types.DecodeInputs(p.info, inputs, p.program.Range())
+	if len(errs) > 0 {
+		return nil, fail(errs)
+	}
+	result, err := p.run(ctx, values)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := Outputs{}
+	for name, value := range result {
+		outputs[name] = types.Encode(value)
+	}
+	return outputs, nil
+}
+
+// run runs the policy with inputs which are objects of their declared types,
+// and returns the outputs which were set.
+func (p *Policy) run(ctx context.Context, inputs map[string]object.Object) (map[string]object.Object, error) {
+	result, errs := evaluator.EvaluateContext(ctx, p.program, inputs, nil)
+	for _, err := range errs {
+		if err.Code == util.CodeCanceled {
+			return nil, ctx.Err()
+		}
+	}
+	// Warnings are about outputs which are unknown, which are returned as
+	// they are.
+	if errs.HasErrors() {
+		return nil, fail(errs)
+	}
+	return result.Outputs, nil
+}
@@ -0,0 +1,13 @@
+package policyscript_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestPolicyScript(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "PolicyScript Suite")
+}
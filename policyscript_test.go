@@ -0,0 +1,182 @@
+package policyscript_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript"
+	"github.com/policyscript/policyscript/util"
+)
+
+const types = `@enum Status {
+  - single
+  - married
+}
+
+@define Person {
+  status: Status
+  salary: money
+}
+`
+
+const benefits = `@inputs {
+  person: Person
+}
+
+@outputs {
+  credit: money
+  eligible: condition
+}
+
+_ 12 Credit
+
+@code {
+  set eligible to person.status = Status.married
+  if eligible:
+    set credit to person.salary * 10%
+  else:
+    set credit to $0
+}
+`
+
+var _ = Describe("Policy", func() {
+	It("compiles files as one policy and evaluates it", func() {
+		policy := compile(types, benefits)
+		outputs, err := policy.Evaluate(context.Background(), policyscript.Inputs{
+			"person": map[string]interface{}{"status": "married", "salary": "$50_000.00"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outputs).To(Equal(policyscript.Outputs{"credit": "$5000.00", "eligible": true}))
+		Expect(policy.Warnings()).To(BeEmpty())
+
+		// The statements of both files, in order.
+		Expect(policy.Program().Stmts[0].Range().Start.Filename).To(Equal("0.law"))
+		Expect(policy.Program().Stmts[len(policy.Program().Stmts)-1].Range().Start.Filename).To(
+			Equal("1.law"))
+	})
+
+	It("reports the diagnostics of files which do not compile", func() {
+		files := util.NewFileSet()
+		files.AddFile("benefits.law", []byte(benefits))
+		_, err := policyscript.Compile(files)
+
+		var perr *policyscript.Error
+		Expect(errors.As(err, &perr)).To(BeTrue())
+		Expect(perr.Diagnostics).NotTo(BeEmpty())
+		Expect(perr.Diagnostics[0].Rng.Start.Filename).To(Equal("benefits.law"))
+		Expect(err.Error()).To(HavePrefix("benefits.law:"))
+	})
+
+	It("reports inputs which are missing or of the wrong type", func() {
+		policy := compile(types, benefits)
+		_, err := policy.Evaluate(context.Background(), policyscript.Inputs{})
+		Expect(err.(*policyscript.Error).Diagnostics[0].Code).To(Equal(util.CodeMissingInput))
+
+		_, err = policy.Evaluate(context.Background(), policyscript.Inputs{"person": 1})
+		Expect(err.(*policyscript.Error).Diagnostics[0].Code).To(Equal(util.CodeInvalidInput))
+	})
+
+	It("validates inputs without evaluating", func() {
+		policy := compile(types, benefits)
+		problems := policy.Validate(policyscript.Inputs{
+			"person": map[string]interface{}{"status": "widowed", "salary": "lots"},
+		})
+		Expect(problems).To(HaveLen(2))
+		Expect(problems[0].Path).To(Equal("/person/status"))
+		Expect(problems[1].Error()).To(Equal(`/person/salary: expected money, got "lots"`))
+
+		_, err := policy.Evaluate(context.Background(), policyscript.Inputs{
+			"person": map[string]interface{}{"status": "widowed", "salary": "lots"},
+		})
+		var msgs []string
+		for _, diagnostic := range err.(*policyscript.Error).Diagnostics {
+			msgs = append(msgs, diagnostic.Msg)
+		}
+		Expect(msgs).To(ConsistOf(`input /person/status: expected one of single, married, got "widowed"`,
+			`input /person/salary: expected money, got "lots"`))
+	})
+
+	It("evaluates with optional inputs, and reports undetermined outcomes", func() {
+		policy := compile(`@inputs {
+  price: money
+  rate: percent or 10%
+  date_of_sale: date or unknown
+}
+
+@outputs {
+  tax: money
+  early: condition
+  late: condition
+}
+
+@code {
+  set tax to price * rate
+  set early to date_of_sale < |2020/01/01|
+  if price > $100:
+    set late to date_of_sale > |2021/01/01|
+  else:
+    if date_of_sale > |2021/01/01|:
+      set late to true
+}
+`)
+		outputs, err := policy.Evaluate(context.Background(), policyscript.Inputs{"price": "$200"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outputs).To(Equal(policyscript.Outputs{"tax": "$20.00", "early": nil, "late": nil}))
+
+		outputs, err = policy.Evaluate(context.Background(), policyscript.Inputs{
+			"price": "$200", "rate": "5%", "date_of_sale": "2019-06-01",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outputs).To(Equal(policyscript.Outputs{"tax": "$10.00", "early": true, "late": false}))
+
+		_, err = policy.Evaluate(context.Background(), policyscript.Inputs{"price": "$50", "date_of_sale": nil})
+		Expect(err).To(MatchError(ContainSubstring("19:7-19:34: outcome undetermined: need date_of_sale")))
+		Expect(err.(*policyscript.Error).Diagnostics[0].Code).To(Equal(util.CodeUndetermined))
+	})
+
+	It("returns the error of a context which is done", func() {
+		policy := compile(types, benefits)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := policy.Evaluate(ctx, policyscript.Inputs{
+			"person": map[string]interface{}{"status": "single", "salary": "$1.00"},
+		})
+		Expect(err).To(Equal(context.Canceled))
+	})
+
+	It("can be evaluated by many goroutines at once", func() {
+		policy := compile(types, benefits)
+		var wg sync.WaitGroup
+		credits := make([]interface{}, 50)
+		for i := range credits {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				defer GinkgoRecover()
+				outputs, err := policy.Evaluate(context.Background(), policyscript.Inputs{
+					"person": map[string]interface{}{"status": "married", "salary": fmt.Sprintf("$%d", i*10)},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				credits[i] = outputs["credit"]
+			}(i)
+		}
+		wg.Wait()
+		for i, credit := range credits {
+			Expect(credit).To(Equal(fmt.Sprintf("$%d.00", i)))
+		}
+	})
+})
+
+func compile(sources ...string) *policyscript.Policy {
+	files := util.NewFileSet()
+	for i, source := range sources {
+		files.AddFile(fmt.Sprintf("%d.law", i), []byte(source))
+	}
+	policy, err := policyscript.Compile(files)
+	Expect(err).NotTo(HaveOccurred())
+	return policy
+}
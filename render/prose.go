@@ -0,0 +1,383 @@
+// Package render writes programs in forms meant to be read, rather than run.
+package render
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/evaluator"
+	"github.com/policyscript/policyscript/token"
+)
+
+// Prose renders the program as Markdown, with the text of the document kept
+// as written and each @code block explained in plain English beneath the text
+// it implements. The output only depends on the program, so it can be diffed.
+func Prose(program *ast.Program) string {
+	p := newProse(program)
+
+	for _, stmt := range program.Stmts {
+		switch stmt := stmt.(type) {
+		case *ast.HeadingStatement:
+			p.heading(stmt)
+		case *ast.ParagraphStatement:
+			p.paragraph(stmt.Value)
+		case *ast.BlockStatement:
+			p.block(stmt)
+		}
+	}
+	return strings.TrimRight(p.b.String(), "\n") + "\n"
+}
+
+// Explain returns an expression written in plain English, ex: "the person's
+// age is at least 18".
+func Explain(program *ast.Program, exp ast.Expr) string {
+	return newProse(program).expr(exp, 0)
+}
+
+type prose struct {
+	b strings.Builder
+
+	// Names declared as conditions, which read as statements on their own.
+	conditions map[string]bool
+
+	// Names of @enum types, whose members are written without the type.
+	enums map[string]bool
+}
+
+func newProse(program *ast.Program) *prose {
+	p := &prose{conditions: map[string]bool{}, enums: map[string]bool{}}
+
+	for _, stmt := range program.Stmts {
+		block, ok := stmt.(*ast.BlockStatement)
+		if !ok {
+			continue
+		}
+		if block.Token.Type == token.ENUM && block.Ident != nil {
+			p.enums[block.Ident.Value] = true
+		}
+		for _, decl := range evaluator.Declarations(block) {
+			if ident, ok := decl.Value.(*ast.Identifier); ok && ident.Value == "condition" {
+				p.conditions[decl.Ident.Value] = true
+			}
+		}
+	}
+	return p
+}
+
+func (p *prose) heading(heading *ast.HeadingStatement) {
+	title := heading.Value
+	if heading.Label != "" {
+		title = "(" + heading.Label + ") " + title
+	}
+	fmt.Fprintf(&p.b, "%s %s\n\n", strings.Repeat("#", heading.Depth+1), title)
+}
+
+func (p *prose) paragraph(value string) {
+	p.b.WriteString(value)
+	p.b.WriteString("\n\n")
+}
+
+func (p *prose) block(block *ast.BlockStatement) {
+	if block.Ident == nil && (block.Token.Type == token.DEFINE || block.Token.Type == token.ENUM) {
+		// The parser reports a type without a name, and there is nothing to
+		// call it here.
+		return
+	}
+
+	switch block.Token.Type {
+	case token.CODE:
+		p.b.WriteString("In plain English:\n\n")
+		p.stmts(block.Stmts, 0)
+		p.b.WriteString("\n")
+	case token.INPUTS:
+		p.fields("The inputs are:", block)
+	case token.OUTPUTS:
+		p.fields("The outputs are:", block)
+	case token.LOCALS:
+		p.fields("This section also uses:", block)
+	case token.DEFINE:
+		p.fields(fmt.Sprintf("%s %s has:", capitalize(article(block.Ident.Value)),
+			block.Ident.Value), block)
+	case token.ENUM:
+		var members []string
+		for _, stmt := range block.Stmts {
+			if exp, ok := stmt.(*ast.ExpressionStatement); ok {
+				if prefix, ok := exp.Expr.(*ast.PrefixExpression); ok {
+					members = append(members, words(describe(prefix.Right)))
+				}
+			}
+		}
+		fmt.Fprintf(&p.b, "%s %s is %s.\n\n", capitalize(article(block.Ident.Value)),
+			block.Ident.Value, list(members, "or"))
+	}
+}
+
+// fields writes the declarations of a block as a list, followed by their
+// documentation, ex: "- age, an Age: How old the person is."
+func (p *prose) fields(intro string, block *ast.BlockStatement) {
+	if len(evaluator.Declarations(block)) == 0 {
+		return
+	}
+
+	p.b.WriteString(intro + "\n\n")
+	for _, stmt := range block.Stmts {
+		exp, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			continue
+		}
+		decl, ok := exp.Expr.(*ast.DeclareExpression)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&p.b, "- %s, %s", words(decl.Ident.Value), typeName(decl.Value))
+		switch decl.Default.(type) {
+		case nil:
+		case *ast.Unknown:
+			p.b.WriteString(" if known")
+		default:
+			fmt.Fprintf(&p.b, " (%s if not given)", p.expr(decl.Default, 0))
+		}
+		if exp.Doc != "" {
+			fmt.Fprintf(&p.b, ": %s", strings.ReplaceAll(exp.Doc, "\n", " "))
+		}
+		p.b.WriteString("\n")
+	}
+	p.b.WriteString("\n")
+}
+
+// typeName returns a type written as a noun, ex: "a list of text".
+func typeName(exp ast.Expr) string {
+	switch exp := exp.(type) {
+	case *ast.ListType:
+		return "a list of " + strings.TrimPrefix(strings.TrimPrefix(typeName(exp.Elem), "an "), "a ")
+	case *ast.Identifier:
+		if exp.Value == "money" {
+			return "an amount of money"
+		}
+		return article(exp.Value) + " " + exp.Value
+	}
+	return describe(exp)
+}
+
+func (p *prose) stmts(stmts []ast.Stmt, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.CommentStatement:
+			for _, line := range strings.Split(stmt.Value, "\n") {
+				fmt.Fprintf(&p.b, "%s- Note: %s\n", indent, strings.TrimSpace(line))
+			}
+		case *ast.IfStatement:
+			p.branch(indent, "If "+p.expr(stmt.Condition, 0), stmt.Block, depth)
+		case *ast.ElseStatement:
+			if stmt.Condition == nil {
+				p.branch(indent, "Otherwise", stmt.Block, depth)
+			} else {
+				p.branch(indent, "Otherwise, if "+p.expr(stmt.Condition, 0), stmt.Block, depth)
+			}
+		case *ast.ForStatement:
+			intro := fmt.Sprintf("For each %s in %s", words(stmt.Ident.Value), p.expr(stmt.Iter, 0))
+			p.branch(indent, intro, stmt.Block, depth)
+		case *ast.ExpressionStatement:
+			fmt.Fprintf(&p.b, "%s- %s.\n", indent, capitalize(p.action(stmt.Expr)))
+		}
+	}
+}
+
+// branch writes a statement which owns a scope. A scope of a single action is
+// written as one sentence, otherwise its statements are nested beneath it.
+func (p *prose) branch(indent, intro string, scope *ast.ScopeStatement, depth int) {
+	if len(scope.Stmts) == 1 {
+		if exp, ok := scope.Stmts[0].(*ast.ExpressionStatement); ok {
+			fmt.Fprintf(&p.b, "%s- %s, %s.\n", indent, intro, p.action(exp.Expr))
+			return
+		}
+	}
+
+	fmt.Fprintf(&p.b, "%s- %s:\n", indent, intro)
+	p.stmts(scope.Stmts, depth+1)
+}
+
+// action returns a statement written as an instruction, ex: "set the
+// exclusion to $250,000".
+func (p *prose) action(exp ast.Expr) string {
+	set, ok := exp.(*ast.SetExpression)
+	if !ok {
+		return p.expr(exp, 0)
+	}
+
+	name := p.expr(set.Ident, 0)
+	if infix, ok := set.Value.(*ast.InfixExpression); ok {
+		if left, ok := infix.Left.(*ast.Identifier); ok && left.Value == set.Ident.Value {
+			switch infix.Operator {
+			case "+":
+				return fmt.Sprintf("add %s to %s", p.expr(infix.Right, precedences["+"]+1), name)
+			case "-":
+				return fmt.Sprintf("subtract %s from %s", p.expr(infix.Right, precedences["-"]+1), name)
+			}
+		}
+	}
+
+	return fmt.Sprintf("set %s to %s", name, p.expr(set.Value, 0))
+}
+
+// The precedence of operators in prose, used to decide where parentheses are
+// needed.
+var precedences = map[string]int{
+	"or":  1,
+	"and": 2,
+	"=":   3, "!=": 3,
+	"<": 4, ">": 4, "<=": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6,
+}
+
+var operators = map[string]string{
+	"or":  "or",
+	"and": "and",
+	"=":   "is",
+	"!=":  "is not",
+	"<":   "is less than",
+	">":   "is greater than",
+	"<=":  "is at most",
+	">=":  "is at least",
+	"+":   "plus",
+	"-":   "minus",
+	"*":   "times",
+	"/":   "divided by",
+}
+
+// expr returns an expression written in plain English. Parentheses are added
+// if the expression binds less tightly than its parent.
+func (p *prose) expr(exp ast.Expr, parent int) string {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if p.conditions[exp.Value] {
+			return words(exp.Value)
+		}
+		return "the " + words(exp.Value)
+	case *ast.MemberExpression:
+		if ident, ok := exp.Left.(*ast.Identifier); ok && p.enums[ident.Value] {
+			return words(exp.Ident.Value)
+		}
+		return p.expr(exp.Left, 0) + "'s " + words(exp.Ident.Value)
+	case *ast.PrefixExpression:
+		return "negative " + p.expr(exp.Right, 7)
+	case *ast.InfixExpression:
+		return p.infix(exp, parent)
+	case *ast.TextLiteral:
+		return "\"" + exp.Value + "\""
+	case *ast.IntegerLiteral:
+		return groupThousands(exp.Token.Literal)
+	case *ast.DecimalLiteral:
+		return groupThousands(exp.Token.Literal)
+	case *ast.MoneyLiteral:
+		return exp.Symbol + groupThousands(strings.TrimPrefix(exp.Token.Literal, exp.Symbol))
+	case *ast.PercentLiteral:
+		return exp.Token.Literal
+	case *ast.PeriodLiteral:
+		return fmt.Sprintf("%s %s", groupThousands(fmt.Sprint(exp.Value)), exp.Symbol)
+	case *ast.DateLiteral:
+		return fmt.Sprintf("%s %d, %d", time.Month(exp.Month), exp.Day, exp.Year)
+	case *ast.TimeLiteral:
+		return fmt.Sprintf("%02d:%02d:%02d", exp.Hours, exp.Minutes, exp.Seconds)
+	case *ast.Condition:
+		if exp.Value {
+			return "true"
+		}
+		return "false"
+	case *ast.Unknown:
+		return "unknown"
+	}
+	return describe(exp)
+}
+
+func (p *prose) infix(exp *ast.InfixExpression, parent int) string {
+	precedence := precedences[exp.Operator]
+	left := p.expr(exp.Left, precedence)
+	right := p.expr(exp.Right, precedence+1)
+
+	var text string
+	switch {
+	case exp.Operator == "*" && isPercent(exp.Right):
+		text = right + " of " + left
+	case exp.Operator == "*" && isPercent(exp.Left):
+		text = left + " of " + right
+	case exp.Operator == "-" && isPeriod(exp.Right):
+		text = right + " before " + left
+	case exp.Operator == "+" && isPeriod(exp.Right):
+		text = right + " after " + left
+	default:
+		text = left + " " + operators[exp.Operator] + " " + right
+	}
+
+	if precedence < parent {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+func isPercent(exp ast.Expr) bool {
+	_, ok := exp.(*ast.PercentLiteral)
+	return ok
+}
+
+func isPeriod(exp ast.Expr) bool {
+	_, ok := exp.(*ast.PeriodLiteral)
+	return ok
+}
+
+// words turns an identifier into words, ex: "sale_or_exchange" to "sale or
+// exchange".
+func words(ident string) string {
+	return strings.Join(strings.FieldsFunc(ident, func(r rune) bool { return r == '_' }), " ")
+}
+
+func article(noun string) string {
+	if noun != "" && strings.ContainsRune("aeiouAEIOU", rune(noun[0])) {
+		return "an"
+	}
+	return "a"
+}
+
+// list joins items as "a, b or c".
+func list(items []string, conjunction string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " " + conjunction + " " + items[len(items)-1]
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// groupThousands writes a number literal with "," between every group of 3
+// digits, ex: "250_000.00" to "250,000.00". Zero cents are removed.
+func groupThousands(literal string) string {
+	literal = strings.NewReplacer("_", "", ",", "").Replace(literal)
+	whole, fraction := literal, ""
+	if i := strings.IndexRune(literal, '.'); i >= 0 {
+		whole, fraction = literal[:i], literal[i:]
+	}
+	if strings.Trim(fraction, ".0") == "" {
+		fraction = ""
+	}
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	return whole + fraction
+}
+
+func describe(node ast.Node) string {
+	if ident, ok := node.(*ast.Identifier); ok {
+		return ident.Value
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
@@ -0,0 +1,106 @@
+package render_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/citation"
+	"github.com/policyscript/policyscript/parser"
+	"github.com/policyscript/policyscript/render"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/util"
+)
+
+const document = `@meta {
+  set path      to ` + "`121`" + `
+  set numbering to ` + "`legal`" + `
+}
+
+@enum Age {
+  - young
+  - old
+}
+
+@inputs {
+  taxpayer: Person
+  # When the home was
+  # sold.
+  sale_date: date or unknown
+  countries: text list
+  rate: percent or 15%
+}
+
+@outputs {
+  can_exclude: condition
+  exclusion: money
+}
+
+_ Exclusion
+
+Gross income shall not include gain from the sale or exchange of property.
+
+@code {
+  # Exclude if owned long enough.
+  if taxpayer.owned_for >= 2 years and taxpayer.age != Age.young:
+    set can_exclude to true
+    set exclusion to $250_000.00
+  else:
+    set can_exclude to false
+  for country in countries:
+    set exclusion to exclusion - (exclusion * 15% + $1_000)
+}
+`
+
+var _ = Describe("Prose", func() {
+	It("renders a document with code in plain English", func() {
+		Expect(render.Prose(parse(document))).To(Equal("" +
+			"An Age is young or old.\n\n" +
+			"The inputs are:\n\n" +
+			"- taxpayer, a Person\n" +
+			"- sale date, a date if known: When the home was sold.\n" +
+			"- countries, a list of text\n" +
+			"- rate, a percent (15% if not given)\n\n" +
+			"The outputs are:\n\n" +
+			"- can exclude, a condition\n" +
+			"- exclusion, an amount of money\n\n" +
+			"## (a) Exclusion\n\n" +
+			"Gross income shall not include gain from the sale or exchange of property.\n\n" +
+			"In plain English:\n\n" +
+			"- Note: Exclude if owned long enough.\n" +
+			"- If the taxpayer's owned for is at least 2 years and the taxpayer's age is not young:\n" +
+			"  - Set can exclude to true.\n" +
+			"  - Set the exclusion to $250,000.\n" +
+			"- Otherwise, set can exclude to false.\n" +
+			"- For each country in the countries, subtract (15% of the exclusion plus $1,000) from the exclusion.\n"))
+	})
+
+	It("skips types without a name", func() {
+		p := parser.New(*scanner.New(util.NewFile("", []byte("@define {\n  a: integer\n}\n@enum {\n  - b\n}")), nil))
+		program := p.ParseProgram()
+		Expect(p.Errors()).To(HaveLen(2))
+		Expect(render.Prose(program)).To(Equal("\n"))
+	})
+
+	util.Each("explains expression", [][2]string{
+		{"a + b * c", "the a plus the b times the c"},
+		{"(a + b) * c", "(the a plus the b) times the c"},
+		{"a - (b - c)", "the a minus (the b minus the c)"},
+		{"date - 5 years", "5 years before the date"},
+		{"|2021/01/15| + 4 days", "4 days after January 15, 2021"},
+		{"-$1_000_000.50", "negative $1,000,000.50"},
+		{"a.b.c = `Canada`", "the a's b's c is \"Canada\""},
+		{"a < 1_000 or b", "the a is less than 1,000 or the b"},
+	}, func(input, expects string) {
+		program := parse("@code {\n  set value to " + input + "\n}")
+		set := program.Stmts[0].(*ast.BlockStatement).Stmts[0].(*ast.ExpressionStatement).Expr
+		Expect(render.Explain(program, set.(*ast.SetExpression).Value)).To(Equal(expects))
+	})
+})
+
+func parse(input string) *ast.Program {
+	p := parser.New(*scanner.New(util.NewFile("", []byte(input)), nil))
+	program := p.ParseProgram()
+	Expect(p.Errors()).To(BeEmpty())
+	Expect(citation.Resolve(program)).To(BeEmpty())
+	return program
+}
@@ -0,0 +1,209 @@
+// Package repl evaluates PolicyScript statements one at a time, as typed into
+// "policyscript repl". Each entry is scanned as the inside of a @code block, so
+// an expression such as "|2020/01/01| + 2 years" prints its value and type.
+//
+// A session keeps what was entered: a declaration such as "price: money"
+// declares a variable for later entries, and the types of @define and @enum
+// blocks can be loaded from files. Entries with errors are forgotten.
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/diagnostic"
+	"github.com/policyscript/policyscript/evaluator"
+	"github.com/policyscript/policyscript/object"
+	"github.com/policyscript/policyscript/parser"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/types"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Prompts written before each entry, and before each further line of an
+// entry which ends with ":".
+const (
+	Prompt         = "> "
+	PromptContinue = "... "
+)
+
+// Session holds what has been entered so far.
+type Session struct {
+	files *util.FileSet
+
+	// Blocks of the types loaded from files.
+	types []ast.Stmt
+
+	// Declarations and other statements entered, which are run again with
+	// each entry, since values are only kept while a program runs.
+	decls []ast.Stmt
+	code  []ast.Stmt
+
+	entries int
+}
+
+// New returns an empty session, which adds the source of each entry to files.
+func New(files *util.FileSet) *Session {
+	return &Session{files: files}
+}
+
+// Load adds the @define and @enum types of a file to the session. The rest of
+// the file is ignored.
+func (s *Session) Load(file *util.File) util.ErrorList {
+	var errs util.ErrorList
+	scan := scanner.New(file, func(err *util.Error) {
+		errs = append(errs, err)
+	})
+	p := parser.New(*scan)
+	program := p.ParseProgram()
+	if errs = append(errs, p.Errors()...); errs.HasErrors() {
+		return errs
+	}
+
+	var loaded []ast.Stmt
+	for _, stmt := range program.Stmts {
+		if block, ok := stmt.(*ast.BlockStatement); ok &&
+			(block.Token.Type == token.DEFINE || block.Token.Type == token.ENUM) {
+			loaded = append(loaded, block)
+		}
+	}
+	if _, errs := s.check(append(s.types, loaded...), s.decls, s.code); errs.HasErrors() {
+		return errs
+	}
+	s.types = append(s.types, loaded...)
+	return nil
+}
+
+// Eval runs an entry, and returns a line for each expression with its value
+// and type, ex: "|2022/01/01| (date)", and for each variable it sets, ex:
+// "price = $100.00 (money)". If the entry has errors, they are returned and
+// the entry is forgotten.
+func (s *Session) Eval(source string) (string, util.ErrorList) {
+	s.entries++
+	file := s.files.AddFile(fmt.Sprintf("<entry %d>", s.entries), []byte(source))
+
+	var errs util.ErrorList
+	scan := scanner.NewBlock(file, func(err *util.Error) {
+		errs = append(errs, err)
+	})
+	p := parser.New(*scan)
+	stmts := p.ParseStatements()
+	if errs = append(errs, p.Errors()...); errs.HasErrors() {
+		return "", errs
+	}
+
+	decls, code := s.decls, s.code
+	var entered []ast.Stmt
+	for _, stmt := range stmts {
+		if exp, ok := stmt.(*ast.ExpressionStatement); ok {
+			if _, ok := exp.Expr.(*ast.DeclareExpression); ok {
+				decls = append(decls[:len(decls):len(decls)], stmt)
+				continue
+			}
+		}
+		code = append(code[:len(code):len(code)], stmt)
+		entered = append(entered, stmt)
+	}
+
+	info, errs := s.check(s.types, decls, code)
+	if errs.HasErrors() {
+		return "", errs
+	}
+	result, errs := evaluator.Evaluate(s.program(s.types, decls, code), nil, nil)
+	if errs.HasErrors() {
+		return "", errs
+	}
+	s.decls, s.code = decls, code
+
+	var b strings.Builder
+	for _, stmt := range entered {
+		exp, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			continue
+		}
+		if set, ok := exp.Expr.(*ast.SetExpression); ok {
+			name := set.Ident.Value
+			fmt.Fprintf(&b, "%s = %s (%s)\n", name, result.Outputs[name].Inspect(), info.Lookup(name).Type)
+		} else if value, ok := result.Values[exp.Expr]; ok {
+			fmt.Fprintf(&b, "%s (%s)\n", value.Inspect(), typeName(info.Exprs[exp.Expr], value))
+		}
+	}
+	return b.String(), nil
+}
+
+// check checks the types of the program of a session. Variables are declared
+// as outputs, which are never reported as unset since the program is only
+// checked for errors.
+func (s *Session) check(named, decls, code []ast.Stmt) (*types.Info, util.ErrorList) {
+	info, errs := types.Check(s.program(named, decls, code))
+	var errors util.ErrorList
+	for _, err := range errs {
+		if err.Severity == util.SeverityError {
+			errors = append(errors, err)
+		}
+	}
+	return info, errors
+}
+
+// program builds the program of a session, where the variables are outputs
+// so that their values are part of the result.
+func (s *Session) program(named, decls, code []ast.Stmt) *ast.Program {
+	program := &ast.Program{Stmts: append([]ast.Stmt(nil), named...)}
+	program.Stmts = append(program.Stmts,
+		&ast.BlockStatement{Token: token.Token{Type: token.OUTPUTS, Literal: string(token.OUTPUTS)}, Stmts: decls},
+		&ast.BlockStatement{Token: token.Token{Type: token.CODE, Literal: string(token.CODE)}, Stmts: code},
+	)
+	return program
+}
+
+// typeName returns the name of the type of an expression, or of its value if
+// the type of the expression is not known.
+func typeName(t types.Type, value object.Object) string {
+	if t == nil {
+		return string(value.Type())
+	}
+	return t.String()
+}
+
+// Run reads entries from in until it ends or ":quit" is entered, and writes
+// their results to out and their errors with printer. An entry whose first
+// line ends with ":", such as an if statement, continues until an empty line.
+func Run(s *Session, in io.Reader, out io.Writer, printer *diagnostic.Printer) error {
+	lines := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, Prompt)
+		if !lines.Scan() {
+			fmt.Fprintln(out)
+			return lines.Err()
+		}
+		entry := lines.Text()
+		switch strings.TrimSpace(entry) {
+		case "":
+			continue
+		case ":quit", ":q":
+			return nil
+		}
+
+		if strings.HasSuffix(strings.TrimSpace(entry), ":") {
+			for {
+				fmt.Fprint(out, PromptContinue)
+				if !lines.Scan() || strings.TrimSpace(lines.Text()) == "" {
+					break
+				}
+				entry += "\n" + lines.Text()
+			}
+		}
+
+		result, errs := s.Eval(entry)
+		fmt.Fprint(out, result)
+		if len(errs) > 0 {
+			if err := printer.Print(out, errs); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package repl_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/diagnostic"
+	"github.com/policyscript/policyscript/repl"
+	"github.com/policyscript/policyscript/util"
+)
+
+var _ = Describe("Repl", func() {
+	util.Each("prints the value and type of an expression", [][2]string{
+		{"|2020/01/01| + 2 years", "|2022/01/01| (date)\n"},
+		{"$250_000 * 15%", "$37_500.00 (money)\n"},
+		{"$250,000 * 15%", "$37_500.00 (money)\n"},
+		{"1 + 2 * 3", "7 (integer)\n"},
+		{"`a` = `b`", "false (condition)\n"},
+		{"|10:30| < |11:00|", "true (condition)\n"},
+		{"price: money", ""},
+	}, func(input, expects string) {
+		result, errs := repl.New(util.NewFileSet()).Eval(input)
+		Expect(errs).To(BeEmpty())
+		Expect(result).To(Equal(expects))
+	})
+
+	It("keeps variables across entries", func() {
+		s := repl.New(util.NewFileSet())
+		Expect(s.Eval("price: money\nrate: percent")).To(BeEmpty())
+		Expect(s.Eval("set price to $100\nset rate to 10%")).To(Equal("price = $100.00 (money)\nrate = 10% (percent)\n"))
+		Expect(s.Eval("if price > $50:\n  set price to price * 2")).To(BeEmpty())
+		Expect(s.Eval("price * rate")).To(Equal("$20.00 (money)\n"))
+	})
+
+	It("forgets entries with errors", func() {
+		s := repl.New(util.NewFileSet())
+		Expect(s.Eval("count: integer")).To(BeEmpty())
+
+		_, errs := s.Eval("set count to `many`")
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Code).To(Equal(util.CodeMismatchedType))
+
+		_, errs = s.Eval("total: integer\nset total to count")
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Msg).To(Equal("count has not been set"))
+
+		// The declaration of total was forgotten with the error.
+		_, errs = s.Eval("set total to 1")
+		Expect(errs[0].Msg).To(Equal("total is not declared"))
+		Expect(errs[0].Rng.Start.Filename).To(Equal("<entry 4>"))
+	})
+
+	It("loads the types of files", func() {
+		files := util.NewFileSet()
+		s := repl.New(files)
+		Expect(s.Load(files.AddFile("a.law", []byte(
+			"@enum Age {\n  - young\n  - old\n}\n\n@define Person {\n  age: Age\n}\n\n@inputs {\n  person: Person\n}\n",
+		)))).To(BeEmpty())
+
+		Expect(s.Eval("age: Age\nset age to Age.old")).To(Equal("age = Age.old (Age)\n"))
+		Expect(s.Eval("age = Age.young")).To(Equal("false (condition)\n"))
+		_, errs := s.Eval("person.age")
+		Expect(errs[0].Msg).To(Equal("person is not declared"))
+	})
+
+	It("reads entries until the input ends", func() {
+		files := util.NewFileSet()
+		in := strings.NewReader("n: integer\n\nset n to 2\nfor i in n:\n  set n to i\n\n1 +\nn * 2\n:quit\nn\n")
+		var out strings.Builder
+		Expect(repl.Run(repl.New(files), in, &out, &diagnostic.Printer{Format: diagnostic.Plain, Files: files})).
+			To(Succeed())
+		Expect(out.String()).To(Equal("> > > n = 2 (integer)\n> ... ... " +
+			"<entry 3>:1:10: error[PS4009]: can only loop over a list, got integer\n" +
+			"> <entry 4>:1:4: error[PS2009]: no prefix parse function for EOF\n" +
+			"> 4 (integer)\n> "))
+	})
+})
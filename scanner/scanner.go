@@ -2,38 +2,46 @@ package scanner
 
 import (
 	"bytes"
+	"unicode/utf8"
 
 	"github.com/policyscript/policyscript/token"
 
 	"github.com/policyscript/policyscript/util"
 )
 
-// Scanner lexes tokens from an input string.
+// Scanner lexes tokens from a source file.
 type Scanner struct {
+	file  *util.File
 	input []rune
 	err   ErrorHandler
 
 	// Position
-	ch     rune
-	line   int
-	column int
-	offset int
+	ch         rune
+	line       int
+	column     int
+	offset     int
+	byteOffset int
 
 	// Block
 	blockInit bool
 	block     bool
 	addSemi   bool
 
+	// Whether the file is the inside of a block, which ends with the file.
+	fragment bool
+
 	ErrorCount int
 }
 
 // An ErrorHandler is called with each error the scanner finds.
-type ErrorHandler func(msg string, rng util.Range)
+type ErrorHandler func(err *util.Error)
 
-// New initializes a new Scanner.
-func New(input []byte, err ErrorHandler) *Scanner {
+// New initializes a new Scanner for a file. The positions of the tokens and
+// errors it reports are in the file.
+func New(file *util.File, err ErrorHandler) *Scanner {
 	l := &Scanner{
-		input: bytes.Runes(input),
+		file:  file,
+		input: bytes.Runes(file.Source()),
 		err:   err,
 
 		// ch will be obtained by calling `next`
@@ -49,6 +57,16 @@ func New(input []byte, err ErrorHandler) *Scanner {
 	return l
 }
 
+// NewBlock initializes a new Scanner for a file which holds the inside of a
+// block without its braces, such as a line typed into a REPL. The tokens are
+// those of a @code block, which ends at the end of the file.
+func NewBlock(file *util.File, err ErrorHandler) *Scanner {
+	s := New(file, err)
+	s.block = true
+	s.fragment = true
+	return s
+}
+
 // Scan the input into a list of tokens.
 func (s *Scanner) Scan() []token.Token {
 	var tokens []token.Token
@@ -121,17 +139,20 @@ func (s *Scanner) NextToken() *token.Token {
 	return s.readParagraph(start)
 }
 
-func (s *Scanner) error(msg string, rng *util.Range) {
+// error reports an error. If a closing delimiter is missing, the fix inserts
+// it at the current position; otherwise missing is empty and there is no fix.
+func (s *Scanner) error(msg string, code util.Code, start *util.Position, end *util.Position,
+	missing string) {
+	err := &util.Error{Msg: msg, Rng: util.Range{Start: *start, End: *end}, Code: code}
+	if missing != "" {
+		err.WithFix("Insert "+missing, util.Insert(*s.getPosition(), missing))
+	}
 	if s.err != nil {
-		s.err(msg, *rng)
+		s.err(err)
 	}
 	s.ErrorCount++
 }
 
-func (s *Scanner) errorPos(msg string, start *util.Position, end *util.Position) {
-	s.error(msg, &util.Range{Start: *start, End: *end})
-}
-
 func (s *Scanner) nextBlockToken() *token.Token {
 	line := s.line
 	start := s.getPosition()
@@ -144,13 +165,18 @@ func (s *Scanner) nextBlockToken() *token.Token {
 	}
 
 	s.addSemi = false
+	start = s.getPosition()
 
 	switch s.ch {
 	case 0:
+		position := s.getPosition()
+		if s.fragment {
+			return makeToken(token.EOF, nil, position, position)
+		}
+
 		// End block.
 		s.block = false
-		position := s.getPosition()
-		s.errorPos("block does not have closing \"}\"", position, position)
+		s.error("block does not have closing \"}\"", util.CodeUnclosedBlock, position, position, "}")
 		return makeToken(token.EOF, nil, position, position)
 	case '}':
 		// End block.
@@ -172,6 +198,8 @@ func (s *Scanner) nextBlockToken() *token.Token {
 		return s.makeSingleRuneToken(token.RPAREN)
 	case ':':
 		return s.makeSingleRuneToken(token.COLON)
+	case '.':
+		return s.makeSingleRuneToken(token.DOT)
 	case '=':
 		return s.makeSingleRuneToken(token.EQ)
 	case '!':
@@ -236,7 +264,7 @@ func (s *Scanner) readText() *token.Token {
 	literal := s.input[textStart.Offset:s.offset]
 
 	if s.isAtEnd() {
-		s.errorPos("text does not have closing \"`\"", start, textStart)
+		s.error("text does not have closing \"`\"", util.CodeUnclosedText, start, textStart, "`")
 	} else {
 		s.next()
 	}
@@ -254,8 +282,9 @@ func (s *Scanner) readIdentifier() *token.Token {
 		literal              = s.input[start.Offset:end.Offset]
 		tokenType, isKeyword = token.LookupIdent(literal)
 	)
-	// Don't end line after a keyword.
-	if !isKeyword {
+	// Don't end line after a keyword, unless it can end a statement.
+	if !isKeyword || tokenType == token.TRUE || tokenType == token.FALSE ||
+		tokenType == token.LIST || tokenType == token.UNKNOWN {
 		s.addSemi = true
 	}
 	return makeToken(tokenType, literal, start, end)
@@ -269,11 +298,27 @@ func (s *Scanner) readNumber(tokenType *token.Type) *token.Token {
 	// Eat first char, already scanned.
 	s.next()
 
-	// Before decimal point can have arbitrary underscores.
-	for isNumericUnderscore(s.ch) {
+	// Before decimal point can have arbitrary underscores, and money can
+	// also group its digits in threes with commas, as in $250,000.
+	isMoney := tokenType != nil && *tokenType == token.MONEY
+	for isNumericUnderscore(s.ch) || (isMoney && s.isDigitGroup()) {
 		s.next()
 	}
 
+	// Any other comma between digits is read as part of the number, so that
+	// there is one error rather than an illegal token.
+	if s.ch == ',' && isNumeric(s.peek()) {
+		groupStart := s.getPosition()
+		for s.ch == ',' || isNumericUnderscore(s.ch) {
+			s.next()
+		}
+		msg := "use \"_\" to group the digits of a number"
+		if isMoney {
+			msg = "\",\" must group the digits of money in threes"
+		}
+		s.error(msg, util.CodeDigitGroup, groupStart, s.getPosition(), "")
+	}
+
 	// If decimal point and next is numeric, parse up until numeric ends.
 	if s.ch == '.' && isNumeric(s.peek()) {
 		isInteger = false
@@ -324,10 +369,13 @@ func (s *Scanner) readDate(start *util.Position) {
 	for isNumeric(s.ch) || s.ch == '/' {
 		s.next()
 	}
-	if s.ch == '|' {
+	switch {
+	case s.ch == '|':
 		s.next()
-	} else {
-		s.errorPos("invalid date", start, s.getPosition())
+	case s.isUnclosed():
+		s.error("date does not have closing \"|\"", util.CodeInvalidDate, start, s.getPosition(), "|")
+	default:
+		s.error("invalid date", util.CodeInvalidDate, start, s.getPosition(), "")
 	}
 }
 
@@ -335,27 +383,37 @@ func (s *Scanner) readTime(start *util.Position) {
 	for isNumeric(s.ch) || s.ch == ':' {
 		s.next()
 	}
-	if s.ch == '|' {
+	switch {
+	case s.ch == '|':
 		s.next()
-	} else {
-		s.errorPos("invalid time", start, s.getPosition())
+	case s.isUnclosed():
+		s.error("time does not have closing \"|\"", util.CodeInvalidTime, start, s.getPosition(), "|")
+	default:
+		s.error("invalid time", util.CodeInvalidTime, start, s.getPosition(), "")
 	}
 }
 
+// isUnclosed reports whether a date or time ends where the current character
+// is, without its closing "|", rather than having a character it can not hold.
+func (s *Scanner) isUnclosed() bool {
+	return s.ch == 0 || s.ch == '\n' || isWhitespace(s.ch)
+}
+
 // This will return false and reset position if no match.
 func (s *Scanner) checkPeriod() bool {
 	// Memoize values.
 	var (
-		ch     = s.ch
-		line   = s.line
-		column = s.column
-		offset = s.offset
+		ch         = s.ch
+		line       = s.line
+		column     = s.column
+		offset     = s.offset
+		byteOffset = s.byteOffset
 	)
 
 	s.skipWhitespace()
 
 	if !isAlpha(s.ch) {
-		s.resetPosition(ch, line, column, offset)
+		s.resetPosition(ch, line, column, offset, byteOffset)
 		return false
 	}
 
@@ -369,7 +427,7 @@ func (s *Scanner) checkPeriod() bool {
 	if ok := token.LookupPeriodKeyword(literal); ok {
 		return true
 	}
-	s.resetPosition(ch, line, column, offset)
+	s.resetPosition(ch, line, column, offset, byteOffset)
 	return false
 }
 
@@ -494,10 +552,11 @@ func (s *Scanner) readUntilDoubleLineBreak(tokenType token.Type, prevStart *util
 func (s *Scanner) consumeWhitespaceTillChar(char rune) bool {
 	// Memoize values.
 	var (
-		ch     = s.ch
-		line   = s.line
-		column = s.column
-		offset = s.offset
+		ch         = s.ch
+		line       = s.line
+		column     = s.column
+		offset     = s.offset
+		byteOffset = s.byteOffset
 	)
 
 	s.skipWhitespace()
@@ -507,25 +566,22 @@ func (s *Scanner) consumeWhitespaceTillChar(char rune) bool {
 	}
 
 	// Else, reset position and return false.
-	s.resetPosition(ch, line, column, offset)
+	s.resetPosition(ch, line, column, offset, byteOffset)
 	return false
 }
 
-func (s *Scanner) resetPosition(ch rune, line int, column int, offset int) {
+func (s *Scanner) resetPosition(ch rune, line int, column int, offset int, byteOffset int) {
 	s.ch = ch
 	s.line = line
 	s.column = column
 	s.offset = offset
+	s.byteOffset = byteOffset
 }
 
 // This will skip through empty lines, but will return the position at the start
 // of the first non-empty line.
 func (s *Scanner) skipWhitespaceAndBreaks() *util.Position {
-	position := &util.Position{
-		Line:   s.line,
-		Column: s.column,
-		Offset: s.offset,
-	}
+	position := s.getPosition()
 
 	for {
 		s.skipWhitespace()
@@ -533,9 +589,7 @@ func (s *Scanner) skipWhitespaceAndBreaks() *util.Position {
 		if s.ch == '\n' {
 			// If new line, consume then store current values.
 			s.next()
-			position.Line = s.line
-			position.Column = s.column
-			position.Offset = s.offset
+			position = s.getPosition()
 		} else {
 			return position
 		}
@@ -554,6 +608,13 @@ func (s *Scanner) next() {
 		s.column = -1
 	}
 
+	// The size is that of the source, since an invalid byte is read as
+	// utf8.RuneError, which is longer.
+	if s.offset >= 0 && s.offset < len(s.input) {
+		_, size := utf8.DecodeRune(s.file.Source()[s.byteOffset:])
+		s.byteOffset += size
+	}
+
 	if s.offset+1 >= len(s.input) {
 		s.ch = 0
 	} else {
@@ -574,8 +635,10 @@ func (s *Scanner) eatBlockKeyword(start *util.Position) *token.Token {
 
 	keyword := s.input[start.Offset:s.offset]
 
+	// An unknown keyword is read again as the start of a paragraph.
 	tokenType, ok := token.LookupBlockKeyword(keyword)
 	if !ok {
+		s.reset(start)
 		return nil
 	}
 
@@ -584,6 +647,14 @@ func (s *Scanner) eatBlockKeyword(start *util.Position) *token.Token {
 	return makeToken(tokenType, keyword, start, s.getPosition())
 }
 
+// reset moves back to an earlier position on the current line.
+func (s *Scanner) reset(position *util.Position) {
+	s.column = position.Column
+	s.offset = position.Offset
+	s.byteOffset = position.ByteOffset
+	s.ch = s.input[s.offset]
+}
+
 func (s *Scanner) peek() rune {
 	if s.offset+1 >= len(s.input) {
 		return 0
@@ -591,15 +662,31 @@ func (s *Scanner) peek() rune {
 	return s.input[s.offset+1]
 }
 
+// isDigitGroup reports whether the current comma is followed by exactly three
+// digits, as in $1,000 but not $1,00 or $1,0000.
+func (s *Scanner) isDigitGroup() bool {
+	if s.ch != ',' || s.offset+4 > len(s.input) {
+		return false
+	}
+	for _, ch := range s.input[s.offset+1 : s.offset+4] {
+		if !isNumeric(ch) {
+			return false
+		}
+	}
+	return s.offset+4 == len(s.input) || !isNumericUnderscore(s.input[s.offset+4])
+}
+
 func (s *Scanner) isAtEnd() bool {
 	return s.offset >= len(s.input)
 }
 
 func (s *Scanner) getPosition() *util.Position {
 	return &util.Position{
-		Line:   s.line,
-		Column: s.column,
-		Offset: s.offset,
+		Filename:   s.file.Name(),
+		Line:       s.line,
+		Column:     s.column,
+		Offset:     s.offset,
+		ByteOffset: s.byteOffset,
 	}
 }
 
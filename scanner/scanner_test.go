@@ -2,6 +2,7 @@ package scanner_test
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo"
@@ -29,7 +30,7 @@ var _ = Describe("Scanner", func() {
 		{"\n_ A", "_ A"},
 		{"\n_ _A", "_ _A"},
 	}, func(input, expects string) {
-		l := scanner.New([]byte(input), nil)
+		l := scanner.New(util.NewFile("", []byte(input)), nil)
 		tokens := l.Scan()
 		t := tokens[0]
 
@@ -51,8 +52,11 @@ var _ = Describe("Scanner", func() {
 		{"\nA\nB\n\nC", "A\nB"},
 		{"\nA\n\tB", "A\n\tB"},
 		{"\nA\n  B", "A\n  B"},
+		{"\n@todo", "@todo"},
+		{"\n@todo\nCheck the rates.\n", "@todo\nCheck the rates."},
+		{"\n@todo later", "@todo later"},
 	}, func(input, expects string) {
-		l := scanner.New([]byte(input), nil)
+		l := scanner.New(util.NewFile("", []byte(input)), nil)
 		tokens := l.Scan()
 		t := tokens[0]
 
@@ -77,7 +81,7 @@ var _ = Describe("Scanner", func() {
 		{"\n # A\n # B\n", " A\n B"},
 		{"\n\t# A\n\t# B\n", " A\n B"},
 	}, func(input, expects string) {
-		l := scanner.New([]byte(input), nil)
+		l := scanner.New(util.NewFile("", []byte(input)), nil)
 		tokens := l.Scan()
 		t := tokens[0]
 
@@ -85,45 +89,165 @@ var _ = Describe("Scanner", func() {
 		Expect(t.Literal).To(Equal(expects))
 	})
 
+	It("reports the file of tokens and errors", func() {
+		var errs util.ErrorList
+		file := util.NewFileSet().AddFile("a.law", []byte("@code {\n  `open\n}"))
+		tokens := scanner.New(file, func(err *util.Error) {
+			errs = append(errs, err)
+		}).Scan()
+
+		for _, t := range tokens {
+			Expect(t.Range.Start.Filename).To(Equal("a.law"))
+			Expect(t.Range.End.Filename).To(Equal("a.law"))
+		}
+		Expect(errs).NotTo(BeEmpty())
+		Expect(errs[0].Error()).To(HavePrefix("a.law:2:"))
+	})
+
+	util.Each("fixes only missing closing delimiters", [][2]string{
+		{"@code {\n  set a to `open\n}", "Insert `"},
+		{"@code {\n  set a to |2021/01/15\n}", "Insert |"},
+		{"@code {\n  set a to |12:00 and b\n}", "Insert |"},
+		{"@code {\n  set a to 1", "Insert }"},
+		{"@code {\n  set a to |2021-01-15|\n}", ""},
+		{"@code {\n  set a to |12:00am|\n}", ""},
+	}, func(input, expects string) {
+		var errs util.ErrorList
+		scanner.New(util.NewFile("", []byte(input)), func(err *util.Error) {
+			errs = append(errs, err)
+		}).Scan()
+		Expect(errs).NotTo(BeEmpty())
+		if expects == "" {
+			Expect(errs[0].Fixes).To(BeEmpty())
+		} else {
+			Expect(errs[0].Fixes).To(HaveLen(1))
+			Expect(errs[0].Fixes[0].Title).To(Equal(expects))
+		}
+	})
+
+	It("scans the inside of a block", func() {
+		var errs util.ErrorList
+		tokens := scanner.NewBlock(util.NewFile("", []byte("set a to 1\nb")), func(err *util.Error) {
+			errs = append(errs, err)
+		}).Scan()
+
+		var types []token.Type
+		for _, t := range tokens {
+			types = append(types, t.Type)
+		}
+		Expect(types).To(Equal([]token.Type{
+			token.SET, token.IDENT, token.TO, token.INTEGER, token.SEMI, token.IDENT, token.EOF,
+		}))
+		Expect(errs).To(BeEmpty())
+	})
+
+	util.Each("groups the digits of money with commas", [][2]string{
+		{"$250,000", "money($250,000)"},
+		{"$1,000,000.50", "money($1,000,000.50)"},
+		{"$1_000,000", "money($1_000,000)"},
+		{"$1, 2", "money($1) illegal(,) integer(2)"},
+	}, func(input, expects string) {
+		var errs util.ErrorList
+		tokens := scanner.NewBlock(util.NewFile("", []byte(input)), func(err *util.Error) {
+			errs = append(errs, err)
+		}).Scan()
+
+		var scanned []string
+		for _, t := range tokens[:len(tokens)-1] {
+			if t.Literal == string(t.Type) {
+				scanned = append(scanned, t.Literal)
+			} else {
+				scanned = append(scanned, fmt.Sprintf("%s(%s)", t.Type, t.Literal))
+			}
+		}
+		Expect(strings.Join(scanned, " ")).To(Equal(expects))
+		Expect(errs).To(BeEmpty())
+	})
+
+	util.Each("reports digits grouped other than in threes of money", [][2]string{
+		{"$1,00", "money($1,00): \",\" must group the digits of money in threes"},
+		{"$1,0000", "money($1,0000): \",\" must group the digits of money in threes"},
+		{"$1,000,00", "money($1,000,00): \",\" must group the digits of money in threes"},
+		{"1,000", "integer(1,000): use \"_\" to group the digits of a number"},
+		{"1,000.5", "decimal(1,000.5): use \"_\" to group the digits of a number"},
+	}, func(input, expects string) {
+		var errs util.ErrorList
+		tokens := scanner.NewBlock(util.NewFile("", []byte(input)), func(err *util.Error) {
+			errs = append(errs, err)
+		}).Scan()
+
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Code).To(Equal(util.CodeDigitGroup))
+		Expect(fmt.Sprintf("%s(%s): %s", tokens[0].Type, tokens[0].Literal, errs[0].Msg)).To(Equal(expects))
+	})
+
 	eachTokens("can scan program", []inputAndTokens{
 		{input: "_ Heading", expects: []token.Token{
 			{Type: token.HEADING, Literal: "_ Heading", Range: util.Range{
-				Start: util.Position{Line: 1, Column: 0, Offset: 0},
-				End:   util.Position{Line: 1, Column: 9, Offset: 9},
+				Start: util.Position{Line: 1, Column: 0, Offset: 0, ByteOffset: 0},
+				End:   util.Position{Line: 1, Column: 9, Offset: 9, ByteOffset: 9},
 			}},
 			{Type: token.EOF, Literal: "", Range: util.Range{
-				Start: util.Position{Line: 1, Column: 9, Offset: 9},
-				End:   util.Position{Line: 1, Column: 9, Offset: 9},
+				Start: util.Position{Line: 1, Column: 9, Offset: 9, ByteOffset: 9},
+				End:   util.Position{Line: 1, Column: 9, Offset: 9, ByteOffset: 9},
 			}},
 		}},
 		{input: "_ Heading\n # Comment", expects: []token.Token{
 			{Type: token.HEADING, Literal: "_ Heading", Range: util.Range{
-				Start: util.Position{Line: 1, Column: 0, Offset: 0},
-				End:   util.Position{Line: 1, Column: 9, Offset: 9},
+				Start: util.Position{Line: 1, Column: 0, Offset: 0, ByteOffset: 0},
+				End:   util.Position{Line: 1, Column: 9, Offset: 9, ByteOffset: 9},
 			}},
 			{Type: token.COMMENT, Literal: " Comment", Range: util.Range{
-				Start: util.Position{Line: 2, Column: 1, Offset: 11},
-				End:   util.Position{Line: 2, Column: 10, Offset: 20},
+				Start: util.Position{Line: 2, Column: 1, Offset: 11, ByteOffset: 11},
+				End:   util.Position{Line: 2, Column: 10, Offset: 20, ByteOffset: 20},
 			}},
 			{Type: token.EOF, Literal: "", Range: util.Range{
-				Start: util.Position{Line: 2, Column: 10, Offset: 20},
-				End:   util.Position{Line: 2, Column: 10, Offset: 20},
+				Start: util.Position{Line: 2, Column: 10, Offset: 20, ByteOffset: 20},
+				End:   util.Position{Line: 2, Column: 10, Offset: 20, ByteOffset: 20},
 			}},
 		}},
 		{input: "_ Heading\n\n  Paragraph\n continued", expects: []token.Token{
 			{Type: token.HEADING, Literal: "_ Heading", Range: util.Range{
-				Start: util.Position{Line: 1, Column: 0, Offset: 0},
-				End:   util.Position{Line: 1, Column: 9, Offset: 9},
+				Start: util.Position{Line: 1, Column: 0, Offset: 0, ByteOffset: 0},
+				End:   util.Position{Line: 1, Column: 9, Offset: 9, ByteOffset: 9},
 			}},
 			{Type: token.PARAGRAPH, Literal: "  Paragraph\n continued", Range: util.Range{
-				Start: util.Position{Line: 3, Column: 0, Offset: 11},
-				End:   util.Position{Line: 4, Column: 10, Offset: 33},
+				Start: util.Position{Line: 3, Column: 0, Offset: 11, ByteOffset: 11},
+				End:   util.Position{Line: 4, Column: 10, Offset: 33, ByteOffset: 33},
 			}},
 			{Type: token.EOF, Literal: "", Range: util.Range{
-				Start: util.Position{Line: 4, Column: 10, Offset: 33},
-				End:   util.Position{Line: 4, Column: 10, Offset: 33},
+				Start: util.Position{Line: 4, Column: 10, Offset: 33, ByteOffset: 33},
+				End:   util.Position{Line: 4, Column: 10, Offset: 33, ByteOffset: 33},
 			}},
 		}},
+		{input: "_ Prix €\n # 元", expects: []token.Token{
+			{Type: token.HEADING, Literal: "_ Prix €", Range: util.Range{
+				Start: util.Position{Line: 1, Column: 0, Offset: 0, ByteOffset: 0},
+				End:   util.Position{Line: 1, Column: 8, Offset: 8, ByteOffset: 10},
+			}},
+			{Type: token.COMMENT, Literal: " 元", Range: util.Range{
+				Start: util.Position{Line: 2, Column: 1, Offset: 10, ByteOffset: 12},
+				End:   util.Position{Line: 2, Column: 4, Offset: 13, ByteOffset: 17},
+			}},
+			{Type: token.EOF, Literal: "", Range: util.Range{
+				Start: util.Position{Line: 2, Column: 4, Offset: 13, ByteOffset: 17},
+				End:   util.Position{Line: 2, Column: 4, Offset: 13, ByteOffset: 17},
+			}},
+		}},
+	})
+})
+
+var _ = Describe("Scanner with invalid UTF-8", func() {
+	It("counts each invalid byte as one byte, as the line index does", func() {
+		file := util.NewFile("", []byte("_ a\xff\xfeb\n # c"))
+		tokens := scanner.New(file, nil).Scan()
+		Expect(tokens).To(HaveLen(3))
+		Expect(tokens[0].Literal).To(Equal("_ a\ufffd\ufffdb"))
+		Expect(tokens[1].Range.Start).To(Equal(util.Position{Line: 2, Column: 1, Offset: 8, ByteOffset: 8}))
+		for _, tok := range tokens {
+			Expect(file.Lines().AtByte(tok.Range.Start.ByteOffset)).To(Equal(tok.Range.Start))
+			Expect(file.Lines().AtByte(tok.Range.End.ByteOffset)).To(Equal(tok.Range.End))
+		}
 	})
 })
 
@@ -142,7 +266,7 @@ func eachTokens(title string, items []inputAndTokens) {
 				title, i, input, expects)
 		)
 		ginkgo.It(text, func() {
-			l := scanner.New([]byte(input), nil)
+			l := scanner.New(util.NewFile("", []byte(input)), nil)
 			tokens := l.Scan()
 
 			Expect(len(tokens)).To(Equal(len(expects)), "number of tokens incorrect")
@@ -2,6 +2,7 @@ package token
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/policyscript/policyscript/util"
 )
@@ -15,13 +16,13 @@ type (
 	Token struct {
 
 		// Type is the Type.
-		Type Type
+		Type Type `json:"type"`
 
 		// Literal is the string literal of the token.
-		Literal string
+		Literal string `json:"literal"`
 
 		// Range is the positional range of the token within the source.
-		Range util.Range
+		Range util.Range `json:"range"`
 	}
 )
 
@@ -74,19 +75,21 @@ const (
 	LBRACE Type = "{"
 	RBRACE Type = "}"
 	COLON  Type = ":"
+	DOT    Type = "."
 
 	// Keywords.
-	IF    Type = "if"
-	ELSE  Type = "else"
-	FOR   Type = "for"
-	IN    Type = "in"
-	SET   Type = "set"
-	TO    Type = "to"
-	TRUE  Type = "true"
-	FALSE Type = "false"
-	AND   Type = "and"
-	OR    Type = "or"
-	LIST  Type = "list"
+	IF      Type = "if"
+	ELSE    Type = "else"
+	FOR     Type = "for"
+	IN      Type = "in"
+	SET     Type = "set"
+	TO      Type = "to"
+	TRUE    Type = "true"
+	FALSE   Type = "false"
+	AND     Type = "and"
+	OR      Type = "or"
+	LIST    Type = "list"
+	UNKNOWN Type = "unknown"
 
 	// Block keywords.
 
@@ -122,6 +125,11 @@ func LookupBlockKeyword(input []rune) (Type, bool) {
 	return ILLEGAL, false
 }
 
+// BlockKeywords returns every block keyword in alphabetical order.
+func BlockKeywords() []string {
+	return sortedKeys(blockKeywords)
+}
+
 // Valid periods
 var validPeriods = map[string]bool{
 	"year":    true,
@@ -146,18 +154,29 @@ func LookupPeriodKeyword(input []rune) bool {
 	return false
 }
 
+// PeriodKeywords returns every period keyword in alphabetical order.
+func PeriodKeywords() []string {
+	periods := make([]string, 0, len(validPeriods))
+	for period := range validPeriods {
+		periods = append(periods, period)
+	}
+	sort.Strings(periods)
+	return periods
+}
+
 var keywords = map[string]Type{
-	"if":    IF,
-	"else":  ELSE,
-	"for":   FOR,
-	"in":    IN,
-	"set":   SET,
-	"to":    TO,
-	"true":  TRUE,
-	"false": FALSE,
-	"and":   AND,
-	"or":    OR,
-	"list":  LIST,
+	"if":      IF,
+	"else":    ELSE,
+	"for":     FOR,
+	"in":      IN,
+	"set":     SET,
+	"to":      TO,
+	"true":    TRUE,
+	"false":   FALSE,
+	"and":     AND,
+	"or":      OR,
+	"list":    LIST,
+	"unknown": UNKNOWN,
 }
 
 // LookupIdent will return the keyword, or IDENT which is any other alpha-
@@ -169,6 +188,11 @@ func LookupIdent(input []rune) (Type, bool) {
 	return IDENT, false
 }
 
+// Keywords returns every keyword in alphabetical order.
+func Keywords() []string {
+	return sortedKeys(keywords)
+}
+
 // https://en.wikipedia.org/wiki/Currency_symbol
 var validMoney = map[rune]bool{
 	'؋': true,
@@ -225,3 +249,12 @@ func LookupMoney(input rune) bool {
 	_, ok := validMoney[input]
 	return ok
 }
+
+func sortedKeys(m map[string]Type) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,632 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Info is what the checker learns about a program.
+type Info struct {
+
+	// Named are the types declared with @define and @enum, by name.
+	Named map[string]Type
+
+	// Inputs, Outputs and Locals are the declared fields, in source order.
+	Inputs  []*Field
+	Outputs []*Field
+	Locals  []*Field
+
+	// Exprs are the types of the expressions in @code blocks. Expressions
+	// whose type could not be found are not included.
+	Exprs map[ast.Expr]Type
+
+	// Currency is the symbol of the first money literal, or "$" if there is
+	// none. Money inputs must be in this currency.
+	Currency string
+}
+
+// Lookup returns the input, output or local with the given name, or nil if
+// not found.
+func (info *Info) Lookup(name string) *Field {
+	for _, fields := range [][]*Field{info.Inputs, info.Outputs, info.Locals} {
+		for _, field := range fields {
+			if field.Name == name {
+				return field
+			}
+		}
+	}
+	return nil
+}
+
+type checker struct {
+	info   *Info
+	errs   util.ErrorList
+	inputs map[string]bool
+
+	// Names which are set somewhere in a @code block.
+	assigned map[string]bool
+
+	// Loop variables, innermost last.
+	scopes []map[string]Type
+
+	// Whether the default of an input is being checked, which can only use
+	// literals and members of enums.
+	constant bool
+}
+
+// Check resolves the declared types of a program, and checks that every
+// expression in its @code blocks is used with values of the right type.
+func Check(program *ast.Program) (*Info, util.ErrorList) {
+	c := &checker{
+		info: &Info{
+			Named: map[string]Type{},
+			Exprs: map[ast.Expr]Type{},
+		},
+		inputs:   map[string]bool{},
+		assigned: map[string]bool{},
+	}
+
+	blocks := map[token.Type][]*ast.BlockStatement{}
+	for _, stmt := range program.Stmts {
+		if block, ok := stmt.(*ast.BlockStatement); ok {
+			blocks[block.Token.Type] = append(blocks[block.Token.Type], block)
+
+			// Types are named before any are resolved, so they may be used
+			// before they are declared.
+			c.name(block)
+		}
+	}
+	for _, block := range blocks[token.ENUM] {
+		c.enum(block)
+	}
+	for _, block := range blocks[token.DEFINE] {
+		c.group(block)
+	}
+
+	// Declarations are resolved in source order, so that the first of two
+	// fields with the same name is kept.
+	seen := map[string]*Field{}
+	for _, stmt := range program.Stmts {
+		block, ok := stmt.(*ast.BlockStatement)
+		if !ok {
+			continue
+		}
+		var fields *[]*Field
+		switch block.Token.Type {
+		case token.INPUTS:
+			fields = &c.info.Inputs
+		case token.OUTPUTS:
+			fields = &c.info.Outputs
+		case token.LOCALS:
+			fields = &c.info.Locals
+		default:
+			continue
+		}
+		for _, field := range c.fields(block) {
+			if first, ok := seen[field.Name]; ok {
+				c.errs.Add(fmt.Sprintf("%s is declared more than once", field.Name), &field.Range).
+					WithCode(util.CodeDuplicateField).
+					WithRelated("first declared here", &first.Range)
+				continue
+			}
+			seen[field.Name] = field
+			if block.Token.Type == token.INPUTS {
+				c.inputs[field.Name] = true
+			}
+			*fields = append(*fields, field)
+		}
+	}
+
+	for _, block := range blocks[token.CODE] {
+		c.stmts(block.Stmts)
+	}
+
+	// Outputs are only reported as never set in programs with code, so that
+	// a program can be written declarations first.
+	if len(blocks[token.CODE]) > 0 {
+		for _, field := range c.info.Outputs {
+			if !c.assigned[field.Name] {
+				c.errs.Add(fmt.Sprintf("output %s is never set", field.Name), &field.Range).
+					WithCode(util.CodeNeverSet).WithSeverity(util.SeverityWarning)
+			}
+		}
+	}
+
+	if c.info.Currency == "" {
+		c.info.Currency = "$"
+	}
+	return c.info, c.errs
+}
+
+func (c *checker) name(block *ast.BlockStatement) {
+	if block.Ident == nil || block.Token.Type != token.ENUM && block.Token.Type != token.DEFINE {
+		return
+	}
+	name := block.Ident.Value
+	if _, ok := LookupBasic(name); ok {
+		c.errs.Add(fmt.Sprintf("%s is a built-in type", name), block.Ident.Range()).
+			WithCode(util.CodeBuiltinType)
+		return
+	}
+	if first, ok := c.info.Named[name]; ok {
+		c.errs.Add(fmt.Sprintf("type %s is declared more than once", name), block.Ident.Range()).
+			WithCode(util.CodeDuplicateType).
+			WithRelated("first declared here", namedRange(first))
+		return
+	}
+	if block.Token.Type == token.ENUM {
+		c.info.Named[name] = &Enum{Name: name, Range: *block.Range(), Doc: block.Doc}
+	} else {
+		c.info.Named[name] = &Group{Name: name, Range: *block.Range(), Doc: block.Doc}
+	}
+}
+
+func (c *checker) enum(block *ast.BlockStatement) {
+	if block.Ident == nil {
+		return
+	}
+	enum, ok := c.info.Named[block.Ident.Value].(*Enum)
+	if !ok || enum.Range != *block.Range() {
+		return
+	}
+
+	members := map[string]*ast.Identifier{}
+	for _, stmt := range block.Stmts {
+		exp, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			continue
+		}
+		prefix, ok := exp.Expr.(*ast.PrefixExpression)
+		if !ok || prefix.Operator != "-" {
+			c.errs.Add("expected a member, ex: - value", exp.Range()).WithCode(util.CodeExpected)
+			continue
+		}
+		ident, ok := prefix.Right.(*ast.Identifier)
+		if !ok {
+			c.errs.Add("expected a member, ex: - value", exp.Range()).WithCode(util.CodeExpected)
+			continue
+		}
+		if first, ok := members[ident.Value]; ok {
+			c.errs.Add(fmt.Sprintf("%s has member %s more than once", enum.Name, ident.Value),
+				ident.Range()).WithCode(util.CodeDuplicateMember).
+				WithRelated("first declared here", first.Range())
+			continue
+		}
+		members[ident.Value] = ident
+		enum.Members = append(enum.Members, ident.Value)
+	}
+}
+
+func (c *checker) group(block *ast.BlockStatement) {
+	if block.Ident == nil {
+		return
+	}
+	group, ok := c.info.Named[block.Ident.Value].(*Group)
+	if !ok || group.Range != *block.Range() {
+		return
+	}
+
+	for _, field := range c.fields(block) {
+		if first := group.Field(field.Name); first != nil {
+			c.errs.Add(fmt.Sprintf("%s has field %s more than once", group.Name, field.Name),
+				&field.Range).WithCode(util.CodeDuplicateMember).
+				WithRelated("first declared here", &first.Range)
+			continue
+		}
+		group.Fields = append(group.Fields, field)
+	}
+}
+
+// fields resolves the declarations of a block. Declarations of unknown types
+// are reported and left out.
+func (c *checker) fields(block *ast.BlockStatement) []*Field {
+	var fields []*Field
+	for _, stmt := range block.Stmts {
+		exp, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			continue
+		}
+		decl, ok := exp.Expr.(*ast.DeclareExpression)
+		if !ok {
+			c.errs.Add("expected a declaration, ex: name: text", exp.Range()).
+				WithCode(util.CodeExpected)
+			continue
+		}
+		t := c.typeOf(decl.Value)
+		if t == nil {
+			continue
+		}
+		field := &Field{Name: decl.Ident.Value, Type: t, Range: *decl.Range(), Doc: exp.Doc}
+		switch {
+		case decl.Default == nil:
+		case block.Token.Type != token.INPUTS:
+			c.errs.Add("only inputs can have a default", decl.Default.Range()).
+				WithCode(util.CodeInvalidDefault)
+		case c.defaultOf(field, decl.Default):
+			field.Default = decl.Default
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// defaultOf checks the default of an input, and reports whether it is valid.
+func (c *checker) defaultOf(field *Field, exp ast.Expr) bool {
+	c.constant = true
+	t := c.expr(exp)
+	c.constant = false
+	if t == nil {
+		return false
+	}
+	if !AssignableTo(t, field.Type) {
+		c.errs.Add(fmt.Sprintf("can not default %s of type %s to %s", field.Name, field.Type, t),
+			exp.Range()).WithCode(util.CodeMismatchedType)
+		return false
+	}
+	return true
+}
+
+// typeOf resolves a type expression, ex: "text list".
+func (c *checker) typeOf(exp ast.Expr) Type {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if t, ok := LookupBasic(exp.Value); ok {
+			return t
+		}
+		if t, ok := c.info.Named[exp.Value]; ok {
+			return t
+		}
+		c.suggest(c.errs.Add(fmt.Sprintf("unknown type %s", exp.Value), exp.Range()).
+			WithCode(util.CodeUnknownType), exp, c.typeNames())
+		return nil
+	case *ast.ListType:
+		if elem := c.typeOf(exp.Elem); elem != nil {
+			return &List{Elem: elem}
+		}
+		return nil
+	}
+	c.errs.Add("expected a type, ex: text", exp.Range()).WithCode(util.CodeExpected)
+	return nil
+}
+
+func (c *checker) stmts(stmts []ast.Stmt) {
+	// Whether the previous statement was part of an if and else chain.
+	chain := false
+
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.CommentStatement:
+			// Comments do not break an if and else chain.
+		case *ast.IfStatement:
+			c.condition(stmt.Condition)
+			c.stmts(stmt.Block.Stmts)
+			chain = true
+		case *ast.ElseStatement:
+			if !chain {
+				c.errs.Add("else must follow if", &stmt.Token.Range).
+					WithCode(util.CodeElseWithoutIf)
+			}
+			if stmt.Condition != nil {
+				c.condition(stmt.Condition)
+			}
+			c.stmts(stmt.Block.Stmts)
+			chain = stmt.Condition != nil
+		case *ast.ForStatement:
+			chain = false
+			c.loop(stmt)
+		case *ast.ExpressionStatement:
+			chain = false
+			if set, ok := stmt.Expr.(*ast.SetExpression); ok {
+				c.set(set)
+			} else {
+				c.expr(stmt.Expr)
+			}
+		default:
+			chain = false
+		}
+	}
+}
+
+func (c *checker) condition(exp ast.Expr) {
+	if t := c.expr(exp); t != nil && t != Condition && t != Unknown {
+		c.errs.Add(fmt.Sprintf("expected a condition, got %s", t), exp.Range()).
+			WithCode(util.CodeNotCondition)
+	}
+}
+
+func (c *checker) loop(stmt *ast.ForStatement) {
+	var elem Type
+	if t := c.expr(stmt.Iter); t != nil {
+		if list, ok := t.(*List); ok {
+			elem = list.Elem
+		} else {
+			c.errs.Add(fmt.Sprintf("can only loop over a list, got %s", t), stmt.Iter.Range()).
+				WithCode(util.CodeNotList)
+		}
+	}
+
+	name := stmt.Ident.Value
+	if c.info.Lookup(name) != nil || c.loopVariable(name) != nil {
+		err := c.errs.Add(fmt.Sprintf("%s is already declared", name), stmt.Ident.Range()).
+			WithCode(util.CodeRedeclared)
+		if field := c.info.Lookup(name); field != nil {
+			err.WithRelated("declared here", &field.Range)
+		}
+	}
+
+	c.scopes = append(c.scopes, map[string]Type{name: elem})
+	c.stmts(stmt.Block.Stmts)
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *checker) set(set *ast.SetExpression) {
+	value := c.expr(set.Value)
+
+	name := set.Ident.Value
+	field := c.info.Lookup(name)
+	switch {
+	case c.inputs[name]:
+		c.errs.Add(fmt.Sprintf("can not set input %s", name), set.Ident.Range()).
+			WithCode(util.CodeReadOnly).WithRelated("declared here", &field.Range)
+	case field != nil:
+		c.assigned[name] = true
+		if value != nil && !AssignableTo(value, field.Type) {
+			c.errs.Add(fmt.Sprintf("can not set %s of type %s to %s", name, field.Type, value),
+				set.Value.Range()).WithCode(util.CodeMismatchedType).
+				WithRelated("declared here", &field.Range)
+		}
+	case c.loopVariable(name) != nil:
+		c.errs.Add(fmt.Sprintf("can not set loop variable %s", name), set.Ident.Range()).
+			WithCode(util.CodeReadOnly)
+	default:
+		c.suggest(c.errs.Add(fmt.Sprintf("%s is not declared", name), set.Ident.Range()).
+			WithCode(util.CodeUndeclared), set.Ident, c.settableNames())
+	}
+}
+
+// expr returns the type of an expression, or nil if it has an error. Errors
+// are only reported where they are found, so nil is not reported again.
+func (c *checker) expr(exp ast.Expr) Type {
+	t := c.exprType(exp)
+	if t != nil {
+		c.info.Exprs[exp] = t
+	}
+	return t
+}
+
+func (c *checker) exprType(exp ast.Expr) Type {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return c.identifier(exp)
+	case *ast.MemberExpression:
+		return c.member(exp)
+	case *ast.PrefixExpression:
+		right := c.expr(exp.Right)
+		if right == nil {
+			return nil
+		}
+		switch right {
+		case Integer, Decimal, Money, Percent, Period, Unknown:
+			return right
+		}
+		c.errs.Add(fmt.Sprintf("operator %s is not defined for %s", exp.Operator, right),
+			exp.Range()).WithCode(util.CodeInvalidOperator)
+		return nil
+	case *ast.InfixExpression:
+		return c.infix(exp)
+	case *ast.TextLiteral:
+		return Text
+	case *ast.IntegerLiteral:
+		return Integer
+	case *ast.DecimalLiteral:
+		return Decimal
+	case *ast.MoneyLiteral:
+		if c.info.Currency == "" {
+			c.info.Currency = exp.Symbol
+		}
+		return Money
+	case *ast.PercentLiteral:
+		return Percent
+	case *ast.PeriodLiteral:
+		return Period
+	case *ast.DateLiteral:
+		return Date
+	case *ast.TimeLiteral:
+		return Time
+	case *ast.Condition:
+		return Condition
+	case *ast.Unknown:
+		return Unknown
+	}
+	c.errs.Add("expected a value", exp.Range()).WithCode(util.CodeExpected)
+	return nil
+}
+
+func (c *checker) identifier(ident *ast.Identifier) Type {
+	name := ident.Value
+	if c.constant {
+		c.errs.Add(fmt.Sprintf("a default can not use %s", name), ident.Range()).
+			WithCode(util.CodeInvalidDefault)
+		return nil
+	}
+	if t := c.loopVariable(name); t != nil {
+		return t
+	}
+	if field := c.info.Lookup(name); field != nil {
+		return field.Type
+	}
+	if _, ok := c.info.Named[name].(*Enum); ok {
+		c.errs.Add(fmt.Sprintf("%s must be followed by a member, ex: %s.value", name, name),
+			ident.Range()).WithCode(util.CodeEnumValue)
+		return nil
+	}
+	if c.loopScoped(name) {
+		// The loop variable of a list with an error.
+		return nil
+	}
+	c.suggest(c.errs.Add(fmt.Sprintf("%s is not declared", name), ident.Range()).
+		WithCode(util.CodeUndeclared), ident, c.valueNames())
+	return nil
+}
+
+func (c *checker) member(exp *ast.MemberExpression) Type {
+	if ident, ok := exp.Left.(*ast.Identifier); ok && c.loopVariable(ident.Value) == nil &&
+		c.info.Lookup(ident.Value) == nil {
+		if enum, ok := c.info.Named[ident.Value].(*Enum); ok {
+			if !enum.Has(exp.Ident.Value) {
+				c.suggest(c.errs.Add(fmt.Sprintf("%s has no member %s", enum.Name,
+					exp.Ident.Value), exp.Ident.Range()).WithCode(util.CodeUnknownMember),
+					exp.Ident, enum.Members)
+				return nil
+			}
+			return enum
+		}
+	}
+
+	left := c.expr(exp.Left)
+	if left == nil {
+		return nil
+	}
+	group, ok := left.(*Group)
+	if !ok {
+		c.errs.Add(fmt.Sprintf("%s has no fields", left), exp.Range()).
+			WithCode(util.CodeUnknownField)
+		return nil
+	}
+	field := group.Field(exp.Ident.Value)
+	if field == nil {
+		names := make([]string, len(group.Fields))
+		for i, field := range group.Fields {
+			names[i] = field.Name
+		}
+		c.suggest(c.errs.Add(fmt.Sprintf("%s has no field %s", group.Name, exp.Ident.Value),
+			exp.Ident.Range()).WithCode(util.CodeUnknownField), exp.Ident, names)
+		return nil
+	}
+	return field.Type
+}
+
+func (c *checker) infix(exp *ast.InfixExpression) Type {
+	switch exp.Operator {
+	case "and", "or":
+		c.condition(exp.Left)
+		c.condition(exp.Right)
+		return Condition
+	}
+
+	left, right := c.expr(exp.Left), c.expr(exp.Right)
+	if left == nil || right == nil {
+		return nil
+	}
+
+	// An operation on an unknown value is unknown, and a comparison is an
+	// unknown condition.
+	if left == Unknown || right == Unknown {
+		switch exp.Operator {
+		case "=", "!=", "<", ">", "<=", ">=":
+			return Condition
+		}
+		return Unknown
+	}
+
+	var t Type
+	switch exp.Operator {
+	case "=", "!=":
+		if comparable(left, right) {
+			t = Condition
+		}
+	case "<", ">", "<=", ">=":
+		if ordered(left, right) {
+			t = Condition
+		}
+	default:
+		t = arithmetic(exp.Operator, left, right)
+	}
+	if t == nil {
+		c.errs.Add(fmt.Sprintf("operator %s is not defined for %s and %s",
+			exp.Operator, left, right), exp.Range()).WithCode(util.CodeInvalidOperator)
+	}
+	return t
+}
+
+func (c *checker) loopVariable(name string) Type {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if t, ok := c.scopes[i][name]; ok {
+			return t
+		}
+	}
+	return nil
+}
+
+// loopScoped reports whether name is a loop variable, even one whose type is
+// not known.
+func (c *checker) loopScoped(name string) bool {
+	for _, scope := range c.scopes {
+		if _, ok := scope[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest adds a fix to an error which replaces a misspelled name with the
+// closest of names, if any is close enough.
+func (c *checker) suggest(err *util.Error, ident *ast.Identifier, names []string) {
+	if name, ok := util.Closest(ident.Value, names); ok {
+		err.WithFix("Change to "+name, util.Replace(ident.Range(), name))
+	}
+}
+
+// typeNames returns the names of the basic and declared types, sorted.
+func (c *checker) typeNames() []string {
+	var names []string
+	for name := range basics {
+		names = append(names, name)
+	}
+	for name := range c.info.Named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// settableNames returns the names of the outputs and locals.
+func (c *checker) settableNames() []string {
+	var names []string
+	for _, fields := range [][]*Field{c.info.Outputs, c.info.Locals} {
+		for _, field := range fields {
+			names = append(names, field.Name)
+		}
+	}
+	return names
+}
+
+// valueNames returns the names which have a value: loop variables, innermost
+// first, then inputs, outputs and locals.
+func (c *checker) valueNames() []string {
+	var names []string
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		for name := range c.scopes[i] {
+			names = append(names, name)
+		}
+	}
+	for _, field := range c.info.Inputs {
+		names = append(names, field.Name)
+	}
+	return append(names, c.settableNames()...)
+}
+
+// namedRange returns the range of the block declaring a type.
+func namedRange(t Type) *util.Range {
+	switch t := t.(type) {
+	case *Enum:
+		return &t.Range
+	case *Group:
+		return &t.Range
+	}
+	return &util.Range{}
+}
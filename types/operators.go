@@ -0,0 +1,114 @@
+package types
+
+// The operators follow the rules of the evaluator, written in terms of types.
+
+// comparable reports whether values of two types can be compared with = and
+// !=. Integers and decimals can be compared with each other.
+func comparable(left, right Type) bool {
+	return Identical(left, right) || numeric(left) && numeric(right)
+}
+
+// ordered reports whether values of two types can be compared with <, >, <=
+// and >=.
+func ordered(left, right Type) bool {
+	if numeric(left) || numeric(right) {
+		return numeric(left) && numeric(right)
+	}
+	if left != right {
+		return false
+	}
+	switch left {
+	case Money, Percent, Period, Date, Time:
+		return true
+	}
+	return false
+}
+
+// arithmetic returns the type of +, -, * or / applied to values of two types,
+// or nil if the operator is not defined for them.
+func arithmetic(operator string, left, right Type) Type {
+	switch {
+	case left == Integer && right == Integer:
+		if operator == "/" {
+			// Integers that do not divide evenly give a decimal.
+			return Decimal
+		}
+		return Integer
+	case left == Text:
+		if right == Text && operator == "+" {
+			return Text
+		}
+		return nil
+	case left == Money:
+		return moneyArithmetic(operator, right)
+	case left == Date:
+		switch {
+		case right == Period && (operator == "+" || operator == "-"):
+			return Date
+		case right == Date && operator == "-":
+			return Period
+		}
+		return nil
+	case left == Period:
+		switch {
+		case right == Period && (operator == "+" || operator == "-"):
+			return Period
+		case right == Date && operator == "+":
+			return Date
+		case right == Integer && operator == "*":
+			return Period
+		}
+		return nil
+	case left == Percent && right == Percent:
+		switch operator {
+		case "+", "-", "*":
+			return Percent
+		}
+		return nil
+	case right == Money:
+		if operator == "*" && fraction(left) {
+			return Money
+		}
+		return nil
+	case right == Period:
+		if operator == "*" && left == Integer {
+			return Period
+		}
+		return nil
+	}
+
+	// The remaining operations are on integers, decimals and percentages,
+	// where a percentage can only be multiplied or divided.
+	if !fraction(left) || !fraction(right) {
+		return nil
+	}
+	if (left == Percent || right == Percent) && operator != "*" && operator != "/" {
+		return nil
+	}
+	return Decimal
+}
+
+func moneyArithmetic(operator string, right Type) Type {
+	if right == Money {
+		switch operator {
+		case "+", "-":
+			return Money
+		case "/":
+			return Decimal
+		}
+		return nil
+	}
+	if fraction(right) && (operator == "*" || operator == "/") {
+		return Money
+	}
+	return nil
+}
+
+func numeric(t Type) bool {
+	return t == Integer || t == Decimal
+}
+
+// fraction reports whether a value of type t can be used as a fraction.
+func fraction(t Type) bool {
+	return numeric(t) || t == Percent
+}
@@ -0,0 +1,144 @@
+// Package types declares the types of PolicyScript and checks programs
+// against them.
+package types
+
+import (
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/object"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Type is implemented by all types.
+type Type interface {
+
+	// Kind is the type of the objects of this type.
+	Kind() object.Type
+
+	// String returns the type as written in PolicyScript, ex: "text list".
+	String() string
+}
+
+// Basic is a built-in type.
+type Basic struct {
+	kind object.Type
+}
+
+func (t *Basic) Kind() object.Type { return t.kind }
+func (t *Basic) String() string    { return string(t.kind) }
+
+// The built-in types.
+var (
+	Text      = &Basic{kind: object.TEXT}
+	Integer   = &Basic{kind: object.INTEGER}
+	Decimal   = &Basic{kind: object.DECIMAL}
+	Money     = &Basic{kind: object.MONEY}
+	Percent   = &Basic{kind: object.PERCENT}
+	Period    = &Basic{kind: object.PERIOD}
+	Date      = &Basic{kind: object.DATE}
+	Time      = &Basic{kind: object.TIME}
+	Condition = &Basic{kind: object.CONDITION}
+
+	// Unknown is the type of "unknown", which can be used as a value of any
+	// type. It can not be declared.
+	Unknown = &Basic{kind: object.UNKNOWN}
+)
+
+var basics = map[string]*Basic{
+	"text":      Text,
+	"integer":   Integer,
+	"decimal":   Decimal,
+	"money":     Money,
+	"percent":   Percent,
+	"period":    Period,
+	"date":      Date,
+	"time":      Time,
+	"condition": Condition,
+}
+
+// LookupBasic will return the built-in type and true, or nil and false if not
+// found.
+func LookupBasic(name string) (*Basic, bool) {
+	t, ok := basics[name]
+	return t, ok
+}
+
+// List is a list of elements of the same type.
+type List struct {
+	Elem Type
+}
+
+func (t *List) Kind() object.Type { return object.LIST }
+func (t *List) String() string    { return t.Elem.String() + " list" }
+
+// Group is a type declared with @define.
+type Group struct {
+	Name   string
+	Fields []*Field
+	Range  util.Range
+	Doc    string // documentation of the @define block
+}
+
+func (t *Group) Kind() object.Type { return object.GROUP }
+func (t *Group) String() string    { return t.Name }
+
+// Field returns the field with the given name, or nil if not found.
+func (t *Group) Field(name string) *Field {
+	for _, field := range t.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// Enum is a type declared with @enum.
+type Enum struct {
+	Name    string
+	Members []string
+	Range   util.Range
+	Doc     string // documentation of the @enum block
+}
+
+func (t *Enum) Kind() object.Type { return object.ENUM }
+func (t *Enum) String() string    { return t.Name }
+
+// Has reports whether the enum has a member.
+func (t *Enum) Has(member string) bool {
+	for _, m := range t.Members {
+		if m == member {
+			return true
+		}
+	}
+	return false
+}
+
+// Field is a named value of a group, or a name declared in @inputs, @outputs
+// or @locals.
+type Field struct {
+	Name  string
+	Type  Type
+	Range util.Range
+	Doc   string // documentation of the declaration
+
+	// Default is the value of an optional input which is not given, ex: 15%
+	// or unknown, or nil if the input is required.
+	Default ast.Expr
+}
+
+// Identical reports whether two types are the same.
+func Identical(a, b Type) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a, ok := a.(*List); ok {
+		b, ok := b.(*List)
+		return ok && Identical(a.Elem, b.Elem)
+	}
+	return a == b
+}
+
+// AssignableTo reports whether a value of type v can be stored in a field of
+// type t. Integers can be stored as decimals, and unknown as anything.
+func AssignableTo(v, t Type) bool {
+	return Identical(v, t) || v == Integer && t == Decimal || v == Unknown
+}
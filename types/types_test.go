@@ -0,0 +1,344 @@
+package types_test
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/object"
+	"github.com/policyscript/policyscript/parser"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/types"
+	"github.com/policyscript/policyscript/util"
+)
+
+const declarations = `@enum Age {
+  - young
+  - old
+}
+
+@define Person {
+  age: Age
+  born: date
+  income: money
+  countries_lived_in: text list
+}
+
+@inputs {
+  person: Person
+  rate: percent
+  count: integer
+}
+
+@outputs {
+  result: condition
+  amount: money
+  total: decimal
+}
+
+`
+
+var _ = Describe("Types", func() {
+	It("resolves declared types", func() {
+		info, errs := check(declarations)
+		Expect(errs).To(BeEmpty())
+
+		person := info.Named["Person"].(*types.Group)
+		Expect(person.Field("age").Type).To(Equal(info.Named["Age"]))
+		Expect(person.Field("countries_lived_in").Type.String()).To(Equal("text list"))
+		Expect(info.Named["Age"].(*types.Enum).Members).To(Equal([]string{"young", "old"}))
+		Expect(info.Lookup("rate").Type).To(Equal(types.Percent))
+		Expect(info.Outputs).To(HaveLen(3))
+	})
+
+	It("documents declarations with the comments above them", func() {
+		info, errs := check("# How old.\n@enum Age {\n  - young\n}\n\n@define Person {\n  # Their age.\n  age: Age\n}\n\n" +
+			"@inputs {\n  # The person\n  #   applying.\n  person: Person\n\n  # Not above.\n\n  count: integer\n}\n")
+		Expect(errs).To(BeEmpty())
+		Expect(info.Named["Age"].(*types.Enum).Doc).To(Equal("How old."))
+		Expect(info.Named["Person"].(*types.Group).Doc).To(BeEmpty())
+		Expect(info.Named["Person"].(*types.Group).Field("age").Doc).To(Equal("Their age."))
+		Expect(info.Lookup("person").Doc).To(Equal("The person\napplying."))
+		Expect(info.Lookup("count").Doc).To(BeEmpty())
+	})
+
+	util.Each("reports declaration errors", [][2]string{
+		{"@inputs {\n  a: number\n}", "2:5-2:11: unknown type number [PS4006]"},
+		{"@define text {\n  a: text\n}", "1:8-1:12: text is a built-in type [PS4001]"},
+		{"@enum A {\n  - a\n}\n\n@enum A {\n  - b\n}", "5:6-5:7: type A is declared more than once [PS4002]"},
+		{"@enum A {\n  - a\n  - a\n}", "3:4-3:5: A has member a more than once [PS4004]"},
+		{"@define A {\n  a: text\n  a: date\n}", "3:2-3:9: A has field a more than once [PS4004]"},
+		{"@inputs {\n  a: text\n}\n\n@outputs {\n  a: text\n}", "6:2-6:9: a is declared more than once [PS4003]"},
+		{"@inputs {\n  a: date or 5\n}", "2:13-2:14: can not default a of type date to integer [PS4012]"},
+		{"@outputs {\n  a: text or `b`\n}", "2:13-2:16: only inputs can have a default [PS4018]"},
+		{"@inputs {\n  a: integer\n  b: integer or a + 1\n}", "3:16-3:17: a default can not use a [PS4018]"},
+	}, func(input, expects string) {
+		_, errs := check(input)
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Error()).To(Equal(expects))
+	})
+
+	util.Each("infers the types of expressions", [][2]string{
+		{"person.age = Age.old", "condition"},
+		{"person.income * rate", "money"},
+		{"person.income / $2", "decimal"},
+		{"count / 2", "decimal"},
+		{"count * 2 + 1", "integer"},
+		{"count * rate", "decimal"},
+		{"person.born + 18 years", "date"},
+		{"|2021/01/15| - person.born", "period"},
+		{"2 * 1 month", "period"},
+		{"rate + 5%", "percent"},
+		{"`a` + `b`", "text"},
+		{"-person.income", "money"},
+		{"count > 2.5 and rate < 50%", "condition"},
+		{"unknown", "unknown"},
+		{"count > unknown", "condition"},
+		{"unknown or rate < 50%", "condition"},
+		{"person.income * unknown", "unknown"},
+	}, func(input, expects string) {
+		info, errs := check(declarations + "@code {\n  " + input + "\n}")
+		Expect(errs.HasErrors()).To(BeFalse())
+		Expect(info.Exprs[code(info, input)].String()).To(Equal(expects))
+	})
+
+	util.Each("reports code errors", [][2]string{
+		{"set amount to 5", "can not set amount of type money to integer"},
+		{"set total to count", ""},
+		{"set person to person", "can not set input person"},
+		{"set missing to 1", "missing is not declared"},
+		{"if count:\n    set result to true", "expected a condition, got integer"},
+		{"else:\n    set result to true", "else must follow if"},
+		{"for c in count:\n    set result to true", "can only loop over a list, got integer"},
+		{"for count in person.countries_lived_in:\n    set result to true", "count is already declared"},
+		{"for c in person.countries_lived_in:\n    set c to `a`", "can not set loop variable c"},
+		{"set result to person.age = Age.middle", "Age has no member middle"},
+		{"set result to Age", "Age must be followed by a member, ex: Age.value"},
+		{"set result to person.height > 2", "Person has no field height"},
+		{"set result to count.value", "integer has no fields"},
+		{"set amount to person.income + 5", "operator + is not defined for money and integer"},
+		{"set result to `a` < `b`", "operator < is not defined for text and text"},
+		{"set result to person.age = `old`", "operator = is not defined for Age and text"},
+		{"set amount to -person.born", "operator - is not defined for date"},
+		{"set amount to unknown", ""},
+		{"if unknown and count > 1:\n    set result to true", ""},
+		{"for c in unknown:\n    set result to true", "can only loop over a list, got unknown"},
+	}, func(input, expects string) {
+		_, errs := check(declarations + "@code {\n  " + input + "\n}")
+		var msgs []string
+		for _, err := range errs {
+			if err.Severity == util.SeverityError {
+				msgs = append(msgs, err.Msg)
+			}
+		}
+		Expect(strings.Join(msgs, "; ")).To(Equal(expects))
+	})
+
+	It("resolves the defaults of optional inputs", func() {
+		info, errs := check("@enum Age {\n  - young\n}\n\n@inputs {\n  age: Age or Age.young\n" +
+			"  rate: decimal or 5\n  sold: date or unknown\n  count: integer\n}\n")
+		Expect(errs).To(BeEmpty())
+		Expect(info.Lookup("age").Default).To(BeAssignableToTypeOf(&ast.MemberExpression{}))
+		Expect(info.Lookup("rate").Default).To(BeAssignableToTypeOf(&ast.IntegerLiteral{}))
+		Expect(info.Lookup("sold").Default).To(BeAssignableToTypeOf(&ast.Unknown{}))
+		Expect(info.Lookup("count").Default).To(BeNil())
+	})
+
+	It("warns of outputs which are never set", func() {
+		_, errs := check(declarations + "@code {\n  if count > 1:\n    set result to true\n}")
+		Expect(errs.HasErrors()).To(BeFalse())
+
+		var msgs []string
+		for _, err := range errs {
+			Expect(err.Severity).To(Equal(util.SeverityWarning))
+			Expect(err.Code).To(Equal(util.CodeNeverSet))
+			msgs = append(msgs, err.Msg)
+		}
+		Expect(msgs).To(Equal([]string{"output amount is never set", "output total is never set"}))
+	})
+
+	It("relates duplicates to the first declaration", func() {
+		_, errs := check("@inputs {\n  a: text\n}\n\n@outputs {\n  a: text\n}")
+		Expect(errs).To(HaveLen(1))
+		Expect(errs[0].Related).To(HaveLen(1))
+		Expect(errs[0].Related[0].Msg).To(Equal("first declared here"))
+		Expect(errs[0].Related[0].Rng.String()).To(Equal("2:2-2:9"))
+	})
+
+	util.Each("suggests fixes for misspelled names", [][2]string{
+		{"@inputs {\n  a: integr\n}", "Change to integer 2:5-2:11"},
+		{"@define Person {\n  a: text\n}\n\n@inputs {\n  b: Persn\n}", "Change to Person 6:5-6:10"},
+		{declarations + "@code {\n  set reslt to true\n}", "Change to result 26:6-26:11"},
+		{declarations + "@code {\n  set result to cont > 1\n}", "Change to count 26:16-26:20"},
+		{declarations + "@code {\n  set result to person.age = Age.olt\n}", "Change to old 26:33-26:36"},
+		{declarations + "@code {\n  set result to person.brn > |2000/01/01|\n}", "Change to born 26:23-26:26"},
+		{"@inputs {\n  a: xyz\n}", ""},
+	}, func(input, expects string) {
+		_, errs := check(input)
+		Expect(errs.HasErrors()).To(BeTrue())
+
+		var fixes []string
+		for _, err := range errs {
+			for _, fix := range err.Fixes {
+				edit := fix.Edits[0]
+				fixes = append(fixes, fix.Title+" "+edit.Rng.String())
+				Expect(fix.Title).To(HaveSuffix(edit.NewText))
+			}
+		}
+		Expect(strings.Join(fixes, "; ")).To(Equal(expects))
+	})
+
+	util.Each("decodes and encodes values", [][2]string{
+		{`{"age": "old", "born": "2001-02-03", "income": "$1,000.50", "countries_lived_in": ["Canada"]}`,
+			`{"age":"old","born":"2001-02-03","countries_lived_in":["Canada"],"income":"$1000.50"}`},
+		{`{"age": "young", "born": "2001/02/03", "income": 250000, "countries_lived_in": []}`,
+			`{"age":"young","born":"2001-02-03","countries_lived_in":[],"income":"$250000.00"}`},
+		{`{"age": "middle", "born": "2001-02-03", "income": 1, "countries_lived_in": []}`,
+			`/age: expected one of young, old, got "middle"`},
+		{`{"age": "old", "born": "yesterday", "income": 1, "countries_lived_in": []}`,
+			`/born: expected date, got "yesterday"`},
+		{`{"age": "old", "born": "2001-02-03", "income": 1, "countries_lived_in": [1]}`,
+			`/countries_lived_in/0: expected text, got 1`},
+		{`{"age": "old", "born": "2001-02-03", "income": 1}`,
+			`missing field countries_lived_in`},
+		{`{"age": "old", "born": "2001-02-03", "income": 1, "countries_lived_in": [], "height": 2}`,
+			`Person has no field height`},
+	}, func(input, expects string) {
+		info, errs := check(declarations)
+		Expect(errs).To(BeEmpty())
+
+		var value interface{}
+		Expect(json.Unmarshal([]byte(input), &value)).To(Succeed())
+		obj, err := types.Decode(info.Named["Person"], value)
+		if err != nil {
+			Expect(err.Error()).To(Equal(expects))
+			return
+		}
+		encoded, err := json.Marshal(types.Encode(obj))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(encoded)).To(Equal(expects))
+	})
+
+	util.Each("decodes basic values", [][2]string{
+		{"percent 15", "15%"},
+		{"percent \"7.5%\"", "7.5%"},
+		{"period \"1 year, 2 months and 3 days\"", "1 year 2 months 3 days"},
+		{"time \"23:59\"", "23:59:00"},
+		{"money \"-€20\"", "-€20.00"},
+		{"integer 2.5", "expected integer, got 2.5"},
+		{"integer 1e300", "1e+300 is out of range for integer"},
+		{"money 1e300", "1e+300 is out of range for money"},
+		{"money \"$1e300\"", "expected money, got \"$1e300\""},
+		{"money \"$1e3\"", "expected money, got \"$1e3\""},
+		{"money \"$NaN\"", "expected money, got \"$NaN\""},
+		{"money \"$Inf\"", "expected money, got \"$Inf\""},
+		{"money \"$1,00\"", "expected money, got \"$1,00\""},
+		{"money \"$1,0000\"", "expected money, got \"$1,0000\""},
+		{"money \"$-5\"", "expected money, got \"$-5\""},
+		{"money \"$1_000,000.50\"", "$1000000.50"},
+		{"money \"-250,000\"", "-$250000.00"},
+		{"money \"$100000000000000000000\"", "\"$100000000000000000000\" is out of range for money"},
+		{"percent \"NaN%\"", "expected percent, got \"NaN%\""},
+	}, func(input, expects string) {
+		parts := strings.SplitN(input, " ", 2)
+		t, _ := types.LookupBasic(parts[0])
+
+		var value interface{}
+		Expect(json.Unmarshal([]byte(parts[1]), &value)).To(Succeed())
+		obj, err := types.Decode(t, value)
+		if err != nil {
+			Expect(err.Error()).To(Equal(expects))
+			return
+		}
+		Expect(types.Encode(obj)).To(Equal(expects))
+	})
+
+	util.Each("validates inputs", [][2]string{
+		{`{"person": {"age": "old", "born": "2001-02-03", "income": 1, "countries_lived_in": []}, ` +
+			`"rate": "15%", "count": 2}`, ``},
+		{`{"person": {"age": "middle", "born": "2001-13-03", "income": "$1.000.50", ` +
+			`"countries_lived_in": ["Canada", 2, null]}, "rate": true, "count": 2.5, "extra": 1}`,
+			`/person/age: expected one of young, old, got "middle"; ` +
+				`/person/born: expected date, got "2001-13-03"; ` +
+				`/person/income: expected money, got "$1.000.50"; ` +
+				`/person/countries_lived_in/1: expected text, got 2; ` +
+				`/person/countries_lived_in/2: expected text, got null; ` +
+				`/rate: expected percent, got true; ` +
+				`/count: expected integer, got 2.5; ` +
+				`unknown input extra`},
+		{`{"person": {"born": "2001-02-03", "income": 1, "countries_lived_in": [], "height": 2}, "rate": 1}`,
+			`/person: missing field age; /person: Person has no field height; missing input count`},
+	}, func(input, expects string) {
+		info, errs := check(declarations)
+		Expect(errs).To(BeEmpty())
+
+		var values map[string]interface{}
+		Expect(json.Unmarshal([]byte(input), &values)).To(Succeed())
+		var problems []string
+		for _, problem := range types.Validate(info, values) {
+			problems = append(problems, problem.Error())
+		}
+		Expect(strings.Join(problems, "; ")).To(Equal(expects))
+	})
+
+	It("does not decode numbers which are not finite", func() {
+		_, err := types.Decode(types.Decimal, math.NaN())
+		Expect(err).To(MatchError("expected decimal, got NaN"))
+		_, err = types.Decode(types.Money, math.Inf(1))
+		Expect(err).To(MatchError("expected money, got +Inf"))
+		_, err = types.Decode(types.Integer, math.Inf(-1))
+		Expect(err).To(MatchError("expected integer, got -Inf"))
+	})
+
+	It("validates money in the currency of the policy", func() {
+		info, errs := check("@inputs {\n  price: money\n}\n\n@outputs {\n  total: money\n}\n\n" +
+			"@code {\n  set total to price + €1\n}\n")
+		Expect(errs).To(BeEmpty())
+		Expect(info.Currency).To(Equal("€"))
+
+		problems := types.Validate(info, map[string]interface{}{"price": "$5"})
+		Expect(problems).To(HaveLen(1))
+		Expect(problems[0].Error()).To(Equal(`/price: expected money in €, got "$5"`))
+
+		for _, price := range []interface{}{"€5", "5", 5.0} {
+			inputs, errs := types.DecodeInputs(info, map[string]interface{}{"price": price}, nil)
+			Expect(errs).To(BeEmpty())
+			Expect(inputs["price"].Inspect()).To(Equal("€5.00"))
+		}
+
+		info, _ = check("@inputs {\n  price: money\n}\n")
+		Expect(info.Currency).To(Equal("$"))
+	})
+
+	It("decodes optional inputs which are missing or null", func() {
+		info, errs := check("@inputs {\n  rate: percent or 5%\n  sold: date or unknown\n}\n")
+		Expect(errs).To(BeEmpty())
+		Expect(types.Validate(info, map[string]interface{}{})).To(BeEmpty())
+
+		inputs, errs := types.DecodeInputs(info, map[string]interface{}{"rate": nil, "sold": "2021-01-15"}, nil)
+		Expect(errs).To(BeEmpty())
+		Expect(inputs["rate"]).To(Equal(&object.Unknown{Needs: []string{"rate"}}))
+		Expect(inputs["sold"].Inspect()).To(Equal("|2021/01/15|"))
+	})
+})
+
+func check(input string) (*types.Info, util.ErrorList) {
+	p := parser.New(*scanner.New(util.NewFile("", []byte(input)), nil))
+	program := p.ParseProgram()
+	Expect(p.Errors()).To(BeEmpty())
+	return types.Check(program)
+}
+
+// code returns the expression of the only statement in the @code block.
+func code(info *types.Info, source string) ast.Expr {
+	for exp := range info.Exprs {
+		rng := exp.Range()
+		if rng.Start.Column == 2 && rng.End.Column == 2+len([]rune(source)) {
+			return exp
+		}
+	}
+	return nil
+}
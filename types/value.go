@@ -0,0 +1,448 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/policyscript/policyscript/object"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Decode converts a value decoded from JSON or YAML into an object of type t.
+// Money, percentages, periods, dates and times are written as text, ex:
+// "$1,000.50", "15%", "2 years 3 months", "2021-01-15" and "23:59:59", where
+// the digits of money are grouped as in money literals. Money and percentages
+// may also be plain numbers, where money is in dollars. Numbers which are not
+// finite, or too large for their type, do not fit. If the value does not fit
+// t, the error is the first *Problem.
+func Decode(t Type, value interface{}) (object.Object, error) {
+	d := &decoder{}
+	obj := d.decode(t, value, "")
+	if len(d.problems) > 0 {
+		return nil, d.problems[0]
+	}
+	return obj, nil
+}
+
+// Problem is a value which does not fit its declared type.
+type Problem struct {
+
+	// Path is a JSON pointer to the value, ex: "/taxpayer/occupancies/2/end_date",
+	// or to the object a field is missing from.
+	Path string
+
+	Msg string
+}
+
+func (p *Problem) Error() string {
+	if p.Path == "" {
+		return p.Msg
+	}
+	return p.Path + ": " + p.Msg
+}
+
+// Validate returns every problem with the values of inputs, by name: inputs
+// which are missing or not declared, and values which do not fit their
+// declared types. Optional inputs may be missing, which gives them their
+// defaults, or null, which makes them unknown. Money must be in the currency
+// of the policy, and plain numbers are in it. The paths of values start with
+// the names of their inputs, ex: "/person/born".
+func Validate(info *Info, values map[string]interface{}) []*Problem {
+	var problems []*Problem
+	decodeInputs(info, values, func(field *Field, problem *Problem, code util.Code) {
+		problems = append(problems, problem)
+	})
+	return problems
+}
+
+// pointer appends a key to a JSON pointer, escaping it.
+func pointer(path, key string) string {
+	return path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// decoder decodes values, and records every problem with them.
+type decoder struct {
+	problems []*Problem
+
+	// The symbol of the currency money must be in, or empty if money may be
+	// in any currency.
+	currency string
+}
+
+func (d *decoder) fail(path, format string, args ...interface{}) object.Object {
+	d.problems = append(d.problems, &Problem{Path: path, Msg: fmt.Sprintf(format, args...)})
+	return nil
+}
+
+// decode returns the object of a value at path, or nil if it has problems.
+func (d *decoder) decode(t Type, value interface{}, path string) object.Object {
+	expected := func() object.Object {
+		return d.fail(path, "expected %s, got %s", t, describeValue(value))
+	}
+
+	switch t := t.(type) {
+	case *List:
+		elems, ok := value.([]interface{})
+		if !ok {
+			return expected()
+		}
+		list := &object.List{Elems: make([]object.Object, len(elems))}
+		ok = true
+		for i, elem := range elems {
+			list.Elems[i] = d.decode(t.Elem, elem, pointer(path, strconv.Itoa(i)))
+			ok = ok && list.Elems[i] != nil
+		}
+		if !ok {
+			return nil
+		}
+		return list
+
+	case *Group:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return expected()
+		}
+		group := &object.Group{Name: t.Name, Fields: map[string]object.Object{}}
+		before := len(d.problems)
+		for _, field := range t.Fields {
+			value, ok := fields[field.Name]
+			if !ok {
+				d.fail(path, "missing field %s", field.Name)
+				continue
+			}
+			group.Fields[field.Name] = d.decode(field.Type, value, pointer(path, field.Name))
+		}
+		for _, name := range sortedKeys(fields) {
+			if t.Field(name) == nil {
+				d.fail(path, "%s has no field %s", t.Name, name)
+			}
+		}
+		if len(d.problems) > before {
+			return nil
+		}
+		return group
+
+	case *Enum:
+		member, ok := value.(string)
+		if !ok {
+			return expected()
+		}
+		if !t.Has(member) {
+			return d.fail(path, "expected one of %s, got %q", strings.Join(t.Members, ", "), member)
+		}
+		return &object.Enum{Name: t.Name, Value: member}
+	}
+
+	switch t {
+	case Text:
+		if s, ok := value.(string); ok {
+			return &object.Text{Value: s}
+		}
+	case Integer:
+		if n, ok := toFloat(value); ok && n == math.Trunc(n) {
+			// An int holds from -2^(size-1) up to, but not including, 2^(size-1).
+			if limit := math.Ldexp(1, strconv.IntSize-1); n < -limit || n >= limit {
+				return d.fail(path, "%s is out of range for %s", describeValue(value), t)
+			}
+			return &object.Integer{Value: int(n)}
+		}
+	case Decimal:
+		if n, ok := toFloat(value); ok {
+			return &object.Decimal{Value: n}
+		}
+	case Condition:
+		if b, ok := value.(bool); ok {
+			return object.NativeCondition(b)
+		}
+	case Money:
+		if n, ok := toFloat(value); ok {
+			return d.money(n, "", value, path)
+		}
+		if s, ok := value.(string); ok {
+			if amount, symbol, ok := parseMoney(s); ok {
+				return d.money(amount, symbol, value, path)
+			}
+		}
+	case Percent:
+		if n, ok := toFloat(value); ok {
+			return &object.Percent{Value: n}
+		}
+		if s, ok := value.(string); ok {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+			if err == nil && isFinite(n) {
+				return &object.Percent{Value: n}
+			}
+		}
+	case Period:
+		if s, ok := value.(string); ok {
+			if period, ok := parsePeriod(s); ok {
+				return period
+			}
+		}
+	case Date:
+		switch value := value.(type) {
+		case time.Time:
+			return object.NewDate(value)
+		case string:
+			for _, layout := range []string{"2006-01-02", "2006/01/02"} {
+				if parsed, err := time.Parse(layout, value); err == nil {
+					return object.NewDate(parsed)
+				}
+			}
+		}
+	case Time:
+		if s, ok := value.(string); ok {
+			for _, layout := range []string{"15:04:05", "15:04"} {
+				if parsed, err := time.Parse(layout, s); err == nil {
+					return &object.Time{Hours: parsed.Hour(), Minutes: parsed.Minute(), Seconds: parsed.Second()}
+				}
+			}
+		}
+	}
+	return expected()
+}
+
+// money returns an amount of money, in the currency of the decoder if symbol
+// is empty, or nil if it is in another currency or its cents do not fit in an
+// int64.
+func (d *decoder) money(amount float64, symbol string, value interface{}, path string) object.Object {
+	switch {
+	case symbol == "" && d.currency == "":
+		symbol = "$"
+	case symbol == "":
+		symbol = d.currency
+	case d.currency != "" && symbol != d.currency:
+		return d.fail(path, "expected money in %s, got %s", d.currency, describeValue(value))
+	}
+	if math.Abs(amount*100) >= math.Ldexp(1, 63) {
+		return d.fail(path, "%s is out of range for money", describeValue(value))
+	}
+	return object.NewMoney(amount, symbol)
+}
+
+// DecodeInputs converts the values of inputs, by name, to objects of their
+// declared types, and reports every problem with them as in Validate. Unknown
+// inputs are reported at rng, and other problems at the declaration of the
+// input, ex: "input /person/born: expected date, got "yesterday"".
+func DecodeInputs(info *Info, values map[string]interface{}, rng *util.Range) (
+	map[string]object.Object, util.ErrorList) {
+	var errs util.ErrorList
+	inputs := decodeInputs(info, values, func(field *Field, problem *Problem, code util.Code) {
+		switch {
+		case field == nil:
+			errs.Add(problem.Msg, rng).WithCode(code)
+		case code == util.CodeInvalidInput:
+			errs.Add("input "+problem.Error(), &field.Range).WithCode(code)
+		default:
+			errs.Add(problem.Msg, &field.Range).WithCode(code)
+		}
+	})
+	return inputs, errs
+}
+
+// decodeInputs decodes the values of inputs, and reports each problem with
+// the input it is about, which is nil if the input is not declared.
+func decodeInputs(info *Info, values map[string]interface{},
+	report func(field *Field, problem *Problem, code util.Code)) map[string]object.Object {
+	inputs := map[string]object.Object{}
+	for _, field := range info.Inputs {
+		value, ok := values[field.Name]
+		switch {
+		case !ok && field.Default != nil:
+			// The evaluator gives optional inputs their defaults.
+			continue
+		case !ok:
+			report(field, &Problem{Msg: fmt.Sprintf("missing input %s", field.Name)}, util.CodeMissingInput)
+			continue
+		case value == nil && field.Default != nil:
+			inputs[field.Name] = &object.Unknown{Needs: []string{field.Name}}
+			continue
+		}
+		d := &decoder{currency: info.Currency}
+		if obj := d.decode(field.Type, value, pointer("", field.Name)); obj != nil {
+			inputs[field.Name] = obj
+		}
+		for _, problem := range d.problems {
+			report(field, problem, util.CodeInvalidInput)
+		}
+	}
+	for _, name := range sortedKeys(values) {
+		if input(info, name) == nil {
+			report(nil, &Problem{Msg: fmt.Sprintf("unknown input %s", name)}, util.CodeUnknownInput)
+		}
+	}
+	return inputs
+}
+
+func input(info *Info, name string) *Field {
+	for _, field := range info.Inputs {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// Encode converts an object into a value which can be written as JSON or
+// YAML, in the form read by Decode. Unknown values are nil.
+func Encode(o object.Object) interface{} {
+	switch o := o.(type) {
+	case *object.Text:
+		return o.Value
+	case *object.Integer:
+		return o.Value
+	case *object.Decimal:
+		return o.Value
+	case *object.Money:
+		amount := strconv.FormatFloat(o.Amount(), 'f', 2, 64)
+		if o.Cents < 0 {
+			return "-" + o.Symbol + amount[1:]
+		}
+		return o.Symbol + amount
+	case *object.Percent:
+		return strconv.FormatFloat(o.Value, 'f', -1, 64) + "%"
+	case *object.Period:
+		return o.Inspect()
+	case *object.Date:
+		return o.Time().Format("2006-01-02")
+	case *object.Time:
+		return fmt.Sprintf("%02d:%02d:%02d", o.Hours, o.Minutes, o.Seconds)
+	case *object.Condition:
+		return o.Value
+	case *object.List:
+		elems := make([]interface{}, len(o.Elems))
+		for i, elem := range o.Elems {
+			elems[i] = Encode(elem)
+		}
+		return elems
+	case *object.Group:
+		fields := map[string]interface{}{}
+		for name, value := range o.Fields {
+			fields[name] = Encode(value)
+		}
+		return fields
+	case *object.Enum:
+		return o.Value
+	}
+	return nil
+}
+
+// toFloat returns a number as a float64, unless it is not a number or is not
+// finite.
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, isFinite(n)
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func isFinite(n float64) bool {
+	return !math.IsNaN(n) && !math.IsInf(n, 0)
+}
+
+// parseMoney parses an amount with an optional leading symbol, ex: "$1,000.50".
+// The symbol is empty if there is none.
+func parseMoney(s string) (amount float64, symbol string, ok bool) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if r := []rune(s); len(r) > 0 && token.LookupMoney(r[0]) {
+		symbol, s = string(r[0]), string(r[1:])
+	}
+	if !isAmount(s) {
+		return 0, "", false
+	}
+	amount, err := strconv.ParseFloat(strings.NewReplacer(",", "", "_", "").Replace(s), 64)
+	if err != nil {
+		return 0, "", false
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, symbol, true
+}
+
+// isAmount reports whether s is written as the amount of a money literal:
+// digits, which may be grouped by "_" or by "," in threes, and then
+// optionally "." and more digits, ex: "1_000,000.50".
+func isAmount(s string) bool {
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+		if fraction == "" || strings.Trim(fraction, digits) != "" {
+			return false
+		}
+	}
+	for i, group := range strings.Split(whole, ",") {
+		switch {
+		case i == 0 && (group == "" || strings.IndexByte(digits, group[0]) < 0 ||
+			strings.Trim(group, digits+"_") != ""):
+			return false
+		case i > 0 && (len(group) != 3 || strings.Trim(group, digits) != ""):
+			return false
+		}
+	}
+	return true
+}
+
+const digits = "0123456789"
+
+// parsePeriod parses a sum of periods, ex: "1 year, 2 months and 3 days".
+func parsePeriod(s string) (*object.Period, bool) {
+	words := strings.Fields(strings.NewReplacer(",", " ", " and ", " ").Replace(s))
+	if len(words) == 0 || len(words)%2 != 0 {
+		return nil, false
+	}
+
+	sum := &object.Period{}
+	for i := 0; i < len(words); i += 2 {
+		value, err := strconv.Atoi(words[i])
+		if err != nil {
+			return nil, false
+		}
+		period, ok := object.NewPeriod(value, words[i+1])
+		if !ok {
+			return nil, false
+		}
+		sum.Years += period.Years
+		sum.Months += period.Months
+		sum.Days += period.Days
+		sum.Seconds += period.Seconds
+	}
+	return sum, true
+}
+
+func describeValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(value)
+	case bool:
+		return strconv.FormatBool(value)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprint(value)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,84 @@
+package util
+
+// Code identifies a kind of diagnostic, ex: "PS4006". Codes are stable, so
+// they can be searched for and used to filter diagnostics in CI. A code is
+// never reused once removed.
+//
+// The first digit tells where a diagnostic is found: 1 by the scanner, 2 by
+// the parser, 3 by citations, 4 by the type checker, 5 while running a
+// program and 6 by the formatter. The second digit is 0 for errors and 1 for
+// warnings.
+type Code string
+
+// Scanner.
+const (
+	CodeUnclosedBlock Code = "PS1001" // a block has no closing "}"
+	CodeUnclosedText  Code = "PS1002" // a text has no closing "`"
+	CodeInvalidDate   Code = "PS1003" // a date has no closing "|"
+	CodeInvalidTime   Code = "PS1004" // a time has no closing "|"
+	CodeDigitGroup    Code = "PS1005" // digits grouped with "," other than in money
+)
+
+// Parser.
+const (
+	CodeUnnamedType      Code = "PS2001" // @define or @enum without a name
+	CodeNamedBlock       Code = "PS2002" // a name after another block keyword
+	CodeEmptyScope       Code = "PS2003" // if, else or for without a statement
+	CodeInvalidNumber    Code = "PS2004" // a number which can not be read
+	CodeInvalidPeriod    Code = "PS2005" // a period which is not "<n> <unit>"
+	CodeInvalidDateValue Code = "PS2006" // a date which is malformed or does not exist
+	CodeInvalidTimeValue Code = "PS2007" // a time which is malformed or does not exist
+	CodeInvalidDeclare   Code = "PS2008" // ":" after something other than a name
+	CodeUnexpectedToken  Code = "PS2009" // a token which can not start an expression
+	CodeUnexpectedEnd    Code = "PS2010" // more after the end of a statement
+	CodeExpectedToken    Code = "PS2011" // a missing token, ex: "{"
+)
+
+// Citations.
+const (
+	CodeInvalidMeta      Code = "PS3001" // @meta path or numbering is not text
+	CodeUnknownNumbering Code = "PS3002" // @meta numbering is not a known scheme
+	CodeLabelMismatch    Code = "PS3003" // a heading label does not match the scheme
+	CodeNumberingDepth   Code = "PS3004" // a heading deeper than the scheme allows
+	CodeDuplicateCite    Code = "PS3005" // two headings with the same path
+	CodeUnknownSection   Code = "PS3006" // a reference to a path which does not exist
+)
+
+// Type checker.
+const (
+	CodeBuiltinType     Code = "PS4001" // a type named like a built-in type
+	CodeDuplicateType   Code = "PS4002" // a type declared twice
+	CodeDuplicateField  Code = "PS4003" // an input, output or local declared twice
+	CodeDuplicateMember Code = "PS4004" // a member or field declared twice in a type
+	CodeExpected        Code = "PS4005" // a statement of the wrong form for its block
+	CodeUnknownType     Code = "PS4006" // a type which is not declared
+	CodeElseWithoutIf   Code = "PS4007" // else which does not follow if
+	CodeNotCondition    Code = "PS4008" // a condition which is not a condition
+	CodeNotList         Code = "PS4009" // a loop over something other than a list
+	CodeRedeclared      Code = "PS4010" // a loop variable named like another name
+	CodeReadOnly        Code = "PS4011" // set of an input or loop variable
+	CodeMismatchedType  Code = "PS4012" // set to a value of the wrong type
+	CodeUndeclared      Code = "PS4013" // a name which is not declared
+	CodeEnumValue       Code = "PS4014" // an enum used without a member
+	CodeUnknownMember   Code = "PS4015" // a member which is not in an enum
+	CodeUnknownField    Code = "PS4016" // a field which is not in a type
+	CodeInvalidOperator Code = "PS4017" // an operator used with the wrong types
+	CodeInvalidDefault  Code = "PS4018" // a default outside of @inputs, or which uses a name
+
+	CodeNeverSet Code = "PS4101" // an output which is never set
+)
+
+// Running.
+const (
+	CodeMissingInput Code = "PS5001" // an input without a value
+	CodeUnknownInput Code = "PS5002" // a value for an input which is not declared
+	CodeRuntime      Code = "PS5003" // an error while running, ex: division by zero
+	CodeInvalidInput Code = "PS5004" // a value of the wrong type for an input
+	CodeCanceled     Code = "PS5005" // a program stopped before it finished
+	CodeUndetermined Code = "PS5006" // an outcome which depends on an unknown value
+)
+
+// Formatting.
+const (
+	CodeUnformattable Code = "PS6001" // text which would be read differently once formatted
+)
@@ -1,19 +1,203 @@
 package util
 
-// Error describes an error and the position of the error.
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity is how serious a diagnostic is. The zero Severity is an error.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+	SeverityHint
+)
+
+var severities = [...]string{"error", "warning", "info", "hint"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severities) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severities[s]
+}
+
+// MarshalText writes a severity as its name, ex: "warning".
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText reads a severity written by MarshalText.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for i, name := range severities {
+		if name == string(text) {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+// Related is a secondary range of a diagnostic, such as where a name was
+// first declared.
+type Related struct {
+	Msg string `json:"message"`
+	Rng Range  `json:"range"`
+}
+
+// Edit replaces the text of a range with new text. An empty range inserts.
+type Edit struct {
+	Rng     Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// Fix is a change which resolves a diagnostic, made of edits to apply
+// together.
+type Fix struct {
+	Title string `json:"title"`
+	Edits []Edit `json:"edits"`
+}
+
+// Error describes a diagnostic and the position of the diagnostic. Despite
+// its name it may be a warning or a note, as told by its Severity.
 type Error struct {
-	Msg string
-	Rng Range
+	Msg      string    `json:"message"`
+	Rng      Range     `json:"range"`
+	Severity Severity  `json:"severity"`
+	Code     Code      `json:"code,omitempty"`
+	Related  []Related `json:"related,omitempty"`
+	Fixes    []Fix     `json:"fixes,omitempty"`
 }
 
+// Error returns the diagnostic as "range: msg", with the severity before the
+// message unless it is an error and the code after it, if any.
 func (e Error) Error() string {
-	return e.Rng.String() + ": " + e.Msg
+	s := e.Rng.String() + ": "
+	if e.Severity != SeverityError {
+		s += e.Severity.String() + ": "
+	}
+	s += e.Msg
+	if e.Code != "" {
+		s += " [" + string(e.Code) + "]"
+	}
+	return s
+}
+
+// WithCode sets the code of the diagnostic and returns it.
+func (e *Error) WithCode(code Code) *Error {
+	e.Code = code
+	return e
+}
+
+// WithSeverity sets the severity of the diagnostic and returns it.
+func (e *Error) WithSeverity(severity Severity) *Error {
+	e.Severity = severity
+	return e
+}
+
+// WithRelated adds a secondary range to the diagnostic and returns it.
+func (e *Error) WithRelated(msg string, rng *Range) *Error {
+	e.Related = append(e.Related, Related{Msg: msg, Rng: *rng})
+	return e
+}
+
+// WithFix adds a suggested fix to the diagnostic and returns it.
+func (e *Error) WithFix(title string, edits ...Edit) *Error {
+	e.Fixes = append(e.Fixes, Fix{Title: title, Edits: edits})
+	return e
+}
+
+// Replace returns an edit which replaces the text of a range.
+func Replace(rng *Range, text string) Edit {
+	return Edit{Rng: *rng, NewText: text}
+}
+
+// Insert returns an edit which inserts text at a position.
+func Insert(pos Position, text string) Edit {
+	return Edit{Rng: Range{Start: pos, End: pos}, NewText: text}
 }
 
 // ErrorList is a list of pointers to errors.
 type ErrorList []*Error
 
-// Add appends an error to the error list.
-func (p *ErrorList) Add(msg string, rng *Range) {
-	*p = append(*p, &Error{Msg: msg, Rng: *rng})
+// Add appends an error to the error list. The error is returned so that a
+// code, related ranges or fixes can be added, ex:
+//
+//	errs.Add("unknown type numbr", rng).WithCode(CodeUnknownType)
+func (p *ErrorList) Add(msg string, rng *Range) *Error {
+	err := &Error{Msg: msg, Rng: *rng}
+	*p = append(*p, err)
+	return err
+}
+
+// HasErrors reports whether the list has a diagnostic of severity error.
+// Warnings and notes alone do not stop a program from running.
+func (p ErrorList) HasErrors() bool {
+	for _, err := range p {
+		if err.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (p ErrorList) Len() int      { return len(p) }
+func (p ErrorList) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// Less orders diagnostics by file, position, severity, code and message.
+func (p ErrorList) Less(i, j int) bool {
+	a, b := p[i], p[j]
+	if c := comparePositions(a.Rng.Start, b.Rng.Start); c != 0 {
+		return c < 0
+	}
+	if c := comparePositions(a.Rng.End, b.Rng.End); c != 0 {
+		return c < 0
+	}
+	if a.Severity != b.Severity {
+		return a.Severity < b.Severity
+	}
+	if a.Code != b.Code {
+		return a.Code < b.Code
+	}
+	return a.Msg < b.Msg
+}
+
+// Sort sorts the list by position. The order of diagnostics at the same
+// position is kept.
+func (p ErrorList) Sort() {
+	sort.Stable(p)
+}
+
+// Dedupe sorts the list and removes diagnostics with the same range, severity,
+// code and message as another, which can happen when a statement is checked
+// more than once.
+func (p *ErrorList) Dedupe() {
+	p.Sort()
+	list := (*p)[:0]
+	for i, err := range *p {
+		if i > 0 {
+			prev := list[len(list)-1]
+			if prev.Rng == err.Rng && prev.Severity == err.Severity &&
+				prev.Code == err.Code && prev.Msg == err.Msg {
+				continue
+			}
+		}
+		list = append(list, err)
+	}
+	*p = list
+}
+
+func comparePositions(a, b Position) int {
+	switch {
+	case a.Filename != b.Filename:
+		return strings.Compare(a.Filename, b.Filename)
+	case a.Line != b.Line:
+		return a.Line - b.Line
+	case a.Column != b.Column:
+		return a.Column - b.Column
+	}
+	return 0
 }
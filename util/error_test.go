@@ -0,0 +1,78 @@
+package util_test
+
+import (
+	"encoding/json"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/util"
+)
+
+func at(file string, line, column int) *util.Range {
+	pos := util.Position{Filename: file, Line: line, Column: column}
+	return &util.Range{Start: pos, End: pos}
+}
+
+var _ = Describe("ErrorList", func() {
+	It("sorts and removes duplicates by position", func() {
+		var errs util.ErrorList
+		errs.Add("c", at("b.law", 1, 0))
+		errs.Add("b", at("a.law", 2, 4))
+		errs.Add("a", at("a.law", 2, 0))
+		errs.Add("b", at("a.law", 2, 4))
+		errs.Add("b", at("a.law", 2, 4)).WithCode(util.CodeUndeclared)
+		errs.Dedupe()
+
+		var lines []string
+		for _, err := range errs {
+			lines = append(lines, err.Error())
+		}
+		Expect(strings.Join(lines, "\n")).To(Equal(strings.Join([]string{
+			"a.law:2:0-2:0: a",
+			"a.law:2:4-2:4: b",
+			"a.law:2:4-2:4: b [PS4013]",
+			"b.law:1:0-1:0: c",
+		}, "\n")))
+	})
+
+	It("only counts errors as errors", func() {
+		var errs util.ErrorList
+		errs.Add("unused", at("", 1, 0)).WithSeverity(util.SeverityWarning)
+		Expect(errs.HasErrors()).To(BeFalse())
+		Expect(errs[0].Error()).To(Equal("1:0-1:0: warning: unused"))
+
+		errs.Add("broken", at("", 1, 0))
+		Expect(errs.HasErrors()).To(BeTrue())
+	})
+
+	It("writes severities, related ranges and fixes as JSON", func() {
+		var errs util.ErrorList
+		errs.Add("x is declared more than once", at("", 2, 0)).
+			WithCode(util.CodeDuplicateField).
+			WithSeverity(util.SeverityHint).
+			WithRelated("first declared here", at("", 1, 0)).
+			WithFix("Remove x", util.Replace(at("", 2, 0), ""))
+
+		data, err := json.Marshal(errs[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"severity":"hint","code":"PS4003"`))
+		Expect(string(data)).To(ContainSubstring(`"related":[{"message":"first declared here"`))
+		Expect(string(data)).To(ContainSubstring(`"fixes":[{"title":"Remove x","edits":[{"range"`))
+
+		var decoded util.Error
+		Expect(json.Unmarshal(data, &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(*errs[0]))
+	})
+
+	util.Each("suggests the closest name", [][2]string{
+		{"integr", "integer"},
+		{"Persn", "Person"},
+		{"ag", "age"},
+		{"xyz", ""},
+		{"age", ""},
+	}, func(input, expects string) {
+		name, _ := util.Closest(input, []string{"integer", "Person", "age", "decimal"})
+		Expect(name).To(Equal(expects))
+	})
+})
@@ -0,0 +1,169 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Pos is a compact position within a FileSet: the base of a file plus a byte
+// offset within it. The zero Pos is NoPos and is not within any file.
+type Pos int
+
+// NoPos is the position of nothing.
+const NoPos Pos = 0
+
+// IsValid reports whether a Pos is within a file.
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
+// File is a source file of a FileSet.
+type File struct {
+	name string
+	base int
+	src  []byte
+
+	once  sync.Once
+	lines *LineIndex
+}
+
+// Name returns the name of the file, as given to AddFile unless another file
+// of the set already had that name.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Base returns the Pos of the start of the file.
+func (f *File) Base() int {
+	return f.base
+}
+
+// Size returns the size of the file in bytes.
+func (f *File) Size() int {
+	return len(f.src)
+}
+
+// Source returns the contents of the file.
+func (f *File) Source() []byte {
+	return f.src
+}
+
+// Lines returns the line index of the file.
+func (f *File) Lines() *LineIndex {
+	f.once.Do(func() {
+		f.lines = NewLineIndex(f.src)
+	})
+	return f.lines
+}
+
+// Pos returns the Pos of a byte offset within the file. The end of the file is
+// a valid offset.
+func (f *File) Pos(offset int) Pos {
+	if offset < 0 || offset > len(f.src) {
+		panic(fmt.Sprintf("offset %d is outside of file %s of size %d", offset, f.name, len(f.src)))
+	}
+	return Pos(f.base + offset)
+}
+
+// Offset returns the byte offset of a Pos within the file.
+func (f *File) Offset(p Pos) int {
+	if int(p) < f.base || int(p) > f.base+len(f.src) {
+		panic(fmt.Sprintf("pos %d is outside of file %s", p, f.name))
+	}
+	return int(p) - f.base
+}
+
+// Position returns the full position of a Pos within the file.
+func (f *File) Position(p Pos) Position {
+	pos := f.Lines().AtByte(f.Offset(p))
+	pos.Filename = f.name
+	return pos
+}
+
+// FileSet is a set of source files, such as the files of a project, in the
+// order they are read. Each file is given a range of Pos values which does not
+// overlap with the others, so a Pos alone names both a file and a position
+// within it. The names of the files are unique too, so that a Position names
+// its file by its Filename, which is looked up in the set to show the source
+// of a diagnostic. A FileSet is safe to use from several goroutines.
+type FileSet struct {
+	mu    sync.RWMutex
+	base  int
+	files []*File
+}
+
+// NewFileSet returns an empty FileSet.
+func NewFileSet() *FileSet {
+	return &FileSet{base: 1}
+}
+
+// NewFile returns a file in a FileSet of its own, for sources which are not
+// part of a project.
+func NewFile(name string, src []byte) *File {
+	return NewFileSet().AddFile(name, src)
+}
+
+// AddFile adds a file to the set. A name which is already in the set is made
+// unique by adding the number of the file with that name, ex: "a.law (2)", so
+// that positions in the two files can be told apart.
+func (s *FileSet) AddFile(name string, src []byte) *File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unique := name
+	for n := 2; s.lookup(unique) != nil; n++ {
+		unique = fmt.Sprintf("%s (%d)", name, n)
+	}
+
+	f := &File{name: unique, base: s.base, src: src}
+	s.files = append(s.files, f)
+
+	// The end of a file is a valid Pos, so the next file starts after it.
+	s.base += len(src) + 1
+	return f
+}
+
+// Files returns the files of the set, in the order they were added.
+func (s *FileSet) Files() []*File {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*File(nil), s.files...)
+}
+
+// File returns the file containing a Pos, or nil if there is none.
+func (s *FileSet) File(p Pos) *File {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].base > int(p) }) - 1
+	if i < 0 || int(p) > s.files[i].base+len(s.files[i].src) {
+		return nil
+	}
+	return s.files[i]
+}
+
+// Lookup returns the file with a name, or nil if there is none.
+func (s *FileSet) Lookup(name string) *File {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lookup(name)
+}
+
+func (s *FileSet) lookup(name string) *File {
+	for _, f := range s.files {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Position returns the full position of a Pos, or the zero Position if it is
+// not within a file of the set.
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+	return Position{}
+}
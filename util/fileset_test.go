@@ -0,0 +1,65 @@
+package util_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/util"
+)
+
+var _ = Describe("FileSet", func() {
+	var (
+		fset *util.FileSet
+		a, b *util.File
+	)
+
+	BeforeEach(func() {
+		fset = util.NewFileSet()
+		a = fset.AddFile("a.law", []byte("ab\ncd"))
+		b = fset.AddFile("b.law", []byte("€\nx"))
+	})
+
+	It("gives each file its own range of positions", func() {
+		Expect(a.Base()).To(Equal(1))
+		Expect(b.Base()).To(Equal(a.Base() + a.Size() + 1))
+		Expect(fset.Files()).To(Equal([]*util.File{a, b}))
+
+		Expect(fset.File(util.NoPos)).To(BeNil())
+		Expect(fset.File(a.Pos(0))).To(Equal(a))
+		Expect(fset.File(a.Pos(a.Size()))).To(Equal(a))
+		Expect(fset.File(b.Pos(0))).To(Equal(b))
+		Expect(fset.File(b.Pos(b.Size()) + 1)).To(BeNil())
+		Expect(fset.Lookup("b.law")).To(Equal(b))
+		Expect(fset.Lookup("c.law")).To(BeNil())
+	})
+
+	It("converts positions", func() {
+		Expect(fset.Position(a.Pos(4))).To(Equal(util.Position{
+			Filename: "a.law", Line: 2, Column: 1, Offset: 4, ByteOffset: 4,
+		}))
+		Expect(fset.Position(b.Pos(4))).To(Equal(util.Position{
+			Filename: "b.law", Line: 2, Column: 0, Offset: 2, ByteOffset: 4,
+		}))
+		Expect(fset.Position(util.NoPos)).To(Equal(util.Position{}))
+		Expect(b.Offset(b.Pos(3))).To(Equal(3))
+		Expect(b.Lines().AtByte(4)).To(Equal(util.Position{Line: 2, Column: 0, Offset: 2, ByteOffset: 4}))
+	})
+
+	It("gives files with the same name unique names", func() {
+		again := fset.AddFile("a.law", []byte("ef"))
+		third := fset.AddFile("a.law", []byte("gh"))
+		Expect(again.Name()).To(Equal("a.law (2)"))
+		Expect(third.Name()).To(Equal("a.law (3)"))
+
+		Expect(fset.Lookup("a.law")).To(Equal(a))
+		Expect(fset.Lookup("a.law (2)")).To(Equal(again))
+		Expect(fset.Position(again.Pos(1)).Filename).To(Equal("a.law (2)"))
+	})
+
+	It("writes the file of a range once", func() {
+		rng := util.Range{Start: fset.Position(a.Pos(0)), End: fset.Position(a.Pos(4))}
+		Expect(rng.String()).To(Equal("a.law:1:0-2:1"))
+
+		rng.End = fset.Position(b.Pos(0))
+		Expect(rng.String()).To(Equal("a.law:1:0-b.law:1:0"))
+	})
+})
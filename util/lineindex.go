@@ -0,0 +1,179 @@
+package util
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// LineIndex converts positions within a file between rune, byte and UTF-16
+// offsets. The scanner counts runes, Go tooling counts bytes and editors
+// speaking the Language Server Protocol count UTF-16 code units.
+type LineIndex struct {
+	src []byte
+
+	// Offsets of the start of each line, in bytes and in runes.
+	bytes []int
+	runes []int
+
+	// Number of runes in the file.
+	size int
+}
+
+// UTF16Position is a position whose column is counted in UTF-16 code units.
+type UTF16Position struct {
+
+	// Line is a 1-indexed line number.
+	Line int `json:"line"`
+
+	// Column is a 0-indexed column number, counted in UTF-16 code units.
+	Column int `json:"column"`
+}
+
+// UTF16Range is a range of two UTF-16 positions.
+type UTF16Range struct {
+	Start UTF16Position `json:"start"`
+	End   UTF16Position `json:"end"`
+}
+
+// NewLineIndex indexes the lines of a file. Invalid UTF-8 counts as one rune
+// per byte, as it does for the scanner.
+func NewLineIndex(src []byte) *LineIndex {
+	x := &LineIndex{src: src, bytes: []int{0}, runes: []int{0}}
+	for i := 0; i < len(src); {
+		ch, size := utf8.DecodeRune(src[i:])
+		i += size
+		x.size++
+		if ch == '\n' {
+			x.bytes = append(x.bytes, i)
+			x.runes = append(x.runes, x.size)
+		}
+	}
+	return x
+}
+
+// Lines returns the number of lines in the file.
+func (x *LineIndex) Lines() int {
+	return len(x.bytes)
+}
+
+// Line returns the text of a 1-indexed line, without its line break, or an
+// empty string if there is no such line.
+func (x *LineIndex) Line(line int) string {
+	if line < 1 || line > len(x.bytes) {
+		return ""
+	}
+	start, end := x.bytes[line-1], len(x.src)
+	if line < len(x.bytes) {
+		end = x.bytes[line] - 1
+	}
+	return strings.TrimSuffix(string(x.src[start:end]), "\r")
+}
+
+// AtRune returns the position at a rune offset. Offsets outside of the file
+// are moved to its start or end.
+func (x *LineIndex) AtRune(offset int) Position {
+	offset = clamp(offset, 0, x.size)
+	line := sort.Search(len(x.runes), func(i int) bool { return x.runes[i] > offset }) - 1
+
+	pos := Position{Line: line + 1, Offset: x.runes[line], ByteOffset: x.bytes[line]}
+	for pos.Offset < offset {
+		x.step(&pos)
+	}
+	return pos
+}
+
+// AtByte returns the position at a byte offset. An offset inside of a rune is
+// moved to the start of the rune.
+func (x *LineIndex) AtByte(offset int) Position {
+	offset = clamp(offset, 0, len(x.src))
+	line := sort.Search(len(x.bytes), func(i int) bool { return x.bytes[i] > offset }) - 1
+
+	pos := Position{Line: line + 1, Offset: x.runes[line], ByteOffset: x.bytes[line]}
+	for pos.ByteOffset < offset {
+		_, size := utf8.DecodeRune(x.src[pos.ByteOffset:])
+		if pos.ByteOffset+size > offset {
+			break
+		}
+		x.step(&pos)
+	}
+	return pos
+}
+
+// AtUTF16 returns the position at a UTF-16 position. Columns past the end of
+// the line are moved to its end, and a column inside of a surrogate pair to
+// the start of the rune.
+func (x *LineIndex) AtUTF16(p UTF16Position) Position {
+	line := clamp(p.Line-1, 0, len(x.bytes)-1)
+
+	pos := Position{Line: line + 1, Offset: x.runes[line], ByteOffset: x.bytes[line]}
+	for units := 0; pos.ByteOffset < len(x.src); {
+		ch, _ := utf8.DecodeRune(x.src[pos.ByteOffset:])
+		if ch == '\n' || units+utf16Len(ch) > p.Column {
+			break
+		}
+		units += utf16Len(ch)
+		x.step(&pos)
+	}
+	return pos
+}
+
+// UTF16 returns the UTF-16 position of a position. Only the rune offset of the
+// position is used, so positions made by the scanner can be converted.
+func (x *LineIndex) UTF16(pos Position) UTF16Position {
+	end := x.AtRune(pos.Offset)
+	units := 0
+	for i := x.bytes[end.Line-1]; i < end.ByteOffset; {
+		ch, size := utf8.DecodeRune(x.src[i:])
+		units += utf16Len(ch)
+		i += size
+	}
+	return UTF16Position{Line: end.Line, Column: units}
+}
+
+// UTF16Range returns the UTF-16 range of a range.
+func (x *LineIndex) UTF16Range(rng Range) UTF16Range {
+	return UTF16Range{Start: x.UTF16(rng.Start), End: x.UTF16(rng.End)}
+}
+
+// FromUTF16Range returns the range of a UTF-16 range.
+func (x *LineIndex) FromUTF16Range(rng UTF16Range) Range {
+	return Range{Start: x.AtUTF16(rng.Start), End: x.AtUTF16(rng.End)}
+}
+
+// ByteColumn returns the column of a position counted in bytes.
+func (x *LineIndex) ByteColumn(pos Position) int {
+	end := x.AtRune(pos.Offset)
+	return end.ByteOffset - x.bytes[end.Line-1]
+}
+
+// ByteRange returns the byte offsets of the start and end of a range.
+func (x *LineIndex) ByteRange(rng Range) (start, end int) {
+	return x.AtRune(rng.Start.Offset).ByteOffset, x.AtRune(rng.End.Offset).ByteOffset
+}
+
+// step moves a position past the rune at it, which is not a line break.
+func (x *LineIndex) step(pos *Position) {
+	_, size := utf8.DecodeRune(x.src[pos.ByteOffset:])
+	pos.ByteOffset += size
+	pos.Offset++
+	pos.Column++
+}
+
+// utf16Len returns the number of UTF-16 code units needed for a rune.
+func utf16Len(ch rune) int {
+	if ch >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func clamp(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
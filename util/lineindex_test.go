@@ -0,0 +1,86 @@
+package util_test
+
+import (
+	"fmt"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Each line mixes runes of one, three and four bytes, the last of which needs
+// a surrogate pair in UTF-16.
+const source = "a€\n😀b\n元"
+
+// describe formats a position as "line:column byteOffset utf16Column".
+func describe(x *util.LineIndex, pos util.Position) string {
+	return fmt.Sprintf("%d:%d %d %d", pos.Line, pos.Column, pos.ByteOffset, x.UTF16(pos).Column)
+}
+
+var _ = Describe("LineIndex", func() {
+	x := util.NewLineIndex([]byte(source))
+
+	util.Each("converts rune offsets", [][2]string{
+		{"0", "1:0 0 0"},
+		{"1", "1:1 1 1"},
+		{"2", "1:2 4 2"},
+		{"3", "2:0 5 0"},
+		{"4", "2:1 9 2"},
+		{"5", "2:2 10 3"},
+		{"6", "3:0 11 0"},
+		{"7", "3:1 14 1"},
+		{"-1", "1:0 0 0"},
+		{"99", "3:1 14 1"},
+	}, func(input, expects string) {
+		offset, _ := strconv.Atoi(input)
+		Expect(describe(x, x.AtRune(offset))).To(Equal(expects))
+	})
+
+	util.Each("converts byte offsets", [][2]string{
+		{"1", "1:1 1 1"},
+		{"2", "1:1 1 1"},
+		{"4", "1:2 4 2"},
+		{"7", "2:0 5 0"},
+		{"9", "2:1 9 2"},
+		{"14", "3:1 14 1"},
+	}, func(input, expects string) {
+		offset, _ := strconv.Atoi(input)
+		Expect(describe(x, x.AtByte(offset))).To(Equal(expects))
+	})
+
+	util.Each("converts UTF-16 positions", [][2]string{
+		{"1:1", "1:1 1 1"},
+		{"1:10", "1:2 4 2"},
+		{"2:1", "2:0 5 0"},
+		{"2:2", "2:1 9 2"},
+		{"2:3", "2:2 10 3"},
+		{"5:0", "3:0 11 0"},
+	}, func(input, expects string) {
+		var p util.UTF16Position
+		fmt.Sscanf(input, "%d:%d", &p.Line, &p.Column)
+		Expect(describe(x, x.AtUTF16(p))).To(Equal(expects))
+	})
+
+	It("converts ranges", func() {
+		rng := util.Range{Start: x.AtRune(1), End: x.AtRune(5)}
+
+		utf16 := x.UTF16Range(rng)
+		Expect(utf16).To(Equal(util.UTF16Range{
+			Start: util.UTF16Position{Line: 1, Column: 1},
+			End:   util.UTF16Position{Line: 2, Column: 3},
+		}))
+		Expect(x.FromUTF16Range(utf16)).To(Equal(rng))
+
+		start, end := x.ByteRange(rng)
+		Expect([]int{start, end}).To(Equal([]int{1, 10}))
+		Expect(x.ByteColumn(rng.End)).To(Equal(5))
+	})
+
+	It("counts lines", func() {
+		Expect(x.Lines()).To(Equal(3))
+		Expect([]string{x.Line(0), x.Line(1), x.Line(2), x.Line(3), x.Line(4)}).To(
+			Equal([]string{"", "a€", "😀b", "元", ""}))
+		Expect(util.NewLineIndex(nil).Lines()).To(Equal(1))
+	})
+})
@@ -6,16 +6,20 @@ import "fmt"
 type Position struct {
 
 	// Filename where the token is located.
-	Filename string
+	Filename string `json:"filename,omitempty"`
 
 	// Line is a 1-indexed line number.
-	Line int
+	Line int `json:"line"`
 
-	// Column is a 0-indexed column number.
-	Column int
+	// Column is a 0-indexed column number, counted in runes.
+	Column int `json:"column"`
 
-	// Offset is a 0-indexed position in the file string.
-	Offset int
+	// Offset is a 0-indexed position in the file string, counted in runes.
+	Offset int `json:"offset"`
+
+	// ByteOffset is a 0-indexed position in the file, counted in bytes of
+	// UTF-8.
+	ByteOffset int `json:"byteOffset"`
 }
 
 func (pos Position) String() string {
@@ -33,12 +37,18 @@ func (pos Position) String() string {
 type Range struct {
 
 	// Start is the beginning position.
-	Start Position
+	Start Position `json:"start"`
 
 	// End is the end position.
-	End Position
+	End Position `json:"end"`
 }
 
+// String returns the range as "file:line:column-line:column". The file is
+// only written once if both positions are in the same file.
 func (r Range) String() string {
-	return r.Start.String() + "-" + r.End.String()
+	end := r.End
+	if end.Filename == r.Start.Filename {
+		end.Filename = ""
+	}
+	return r.Start.String() + "-" + end.String()
 }
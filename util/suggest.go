@@ -0,0 +1,55 @@
+package util
+
+import "strings"
+
+// Closest returns the candidate most like name, for "did you mean" fixes of
+// misspelled names. It returns false if no candidate is close enough, which
+// is at most one edit for every three letters of name.
+func Closest(name string, candidates []string) (string, bool) {
+	var (
+		best  string
+		found bool
+		limit = (len([]rune(name)) + 2) / 3
+	)
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+		if d := distance(strings.ToLower(name), strings.ToLower(candidate)); d <= limit {
+			best, found, limit = candidate, true, d-1
+		}
+	}
+	return best, found
+}
+
+// distance returns the Levenshtein distance between two strings.
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
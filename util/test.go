@@ -1,3 +1,8 @@
+//go:build !(js && wasm)
+// +build !js !wasm
+
+// Each is only used by tests, and ginkgo does not build for WebAssembly.
+
 package util
 
 import (
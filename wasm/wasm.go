@@ -0,0 +1,176 @@
+// Package wasm is the API of the WebAssembly build of PolicyScript, which
+// cmd/policyscript-wasm exports to JavaScript. Each function takes a request
+// and returns a response as JSON, so that they can be called across the
+// boundary between Go and JavaScript without sharing types.
+//
+// A request is an object with the source of a file, ex:
+//
+//	{"filename": "demo.law", "source": "@inputs {...}", "inputs": {"age": 3}}
+//
+// and a response is an object with the result of the function and the
+// diagnostics of the file, in the form written by "policyscript -json".
+package wasm
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/policyscript/policyscript/ast"
+	"github.com/policyscript/policyscript/citation"
+	"github.com/policyscript/policyscript/evaluator"
+	"github.com/policyscript/policyscript/render"
+	"github.com/policyscript/policyscript/scanner"
+	"github.com/policyscript/policyscript/token"
+	"github.com/policyscript/policyscript/types"
+	"github.com/policyscript/policyscript/util"
+)
+
+// Request is the request of every function.
+type Request struct {
+
+	// Filename is the name of the file, which is given in diagnostics.
+	Filename string `json:"filename"`
+
+	// Source is the source of the file.
+	Source string `json:"source"`
+
+	// Inputs are the values of the inputs of Evaluate, by name.
+	Inputs map[string]interface{} `json:"inputs"`
+
+	// Trace is whether Evaluate explains how each output was computed.
+	Trace bool `json:"trace"`
+}
+
+// Functions are the functions exported to JavaScript, by name.
+var Functions = map[string]func(request string) string{
+	"scan":     Scan,
+	"parse":    Parse,
+	"check":    Check,
+	"evaluate": Evaluate,
+	"render":   Render,
+}
+
+// Scan returns the tokens of the file, ex: {"tokens": [...]}.
+func Scan(request string) string {
+	return handle(request, func(r *Request, file *util.File) (map[string]interface{}, util.ErrorList) {
+		var errs util.ErrorList
+		tokens := scanner.New(file, func(err *util.Error) {
+			errs = append(errs, err)
+		}).Scan()
+		if tokens == nil {
+			tokens = []token.Token{}
+		}
+		return map[string]interface{}{"tokens": tokens}, errs
+	})
+}
+
+// Parse returns the syntax tree of the file, in the encoding of ast.Marshal,
+// ex: {"program": {"version": 1, "node": {...}}}.
+func Parse(request string) string {
+	return handle(request, func(r *Request, file *util.File) (map[string]interface{}, util.ErrorList) {
+		program, errs := citation.Parse(file)
+		data, err := ast.Marshal(program)
+		if err != nil {
+			errs.Add(err.Error(), program.Range())
+			return nil, errs
+		}
+		return map[string]interface{}{"program": json.RawMessage(data)}, errs
+	})
+}
+
+// Check returns the errors of the file, including type errors, as
+// diagnostics alone.
+func Check(request string) string {
+	return handle(request, func(r *Request, file *util.File) (map[string]interface{}, util.ErrorList) {
+		_, _, errs := check(file)
+		return nil, errs
+	})
+}
+
+// Evaluate runs the file with the inputs of the request, and returns its
+// outputs and the citation of each, ex: {"outputs": {"can_read": true},
+// "citations": {"can_read": "121(a)"}}, and a trace if asked for.
+func Evaluate(request string) string {
+	return handle(request, func(r *Request, file *util.File) (map[string]interface{}, util.ErrorList) {
+		// Warnings do not stop the program from running, and are returned
+		// with its result.
+		program, info, warnings := check(file)
+		if warnings.HasErrors() {
+			return nil, warnings
+		}
+		inputs, errs := types.DecodeInputs(info, r.Inputs, program.Range())
+		if len(errs) > 0 {
+			return nil, append(warnings, errs...)
+		}
+
+		var trace *evaluator.Trace
+		if r.Trace {
+			trace = &evaluator.Trace{}
+		}
+		result, errs := evaluator.Evaluate(program, inputs, trace)
+
+		outputs := map[string]interface{}{}
+		for name, value := range result.Outputs {
+			outputs[name] = types.Encode(value)
+		}
+		response := map[string]interface{}{"outputs": outputs, "citations": result.Citations}
+		if trace != nil {
+			response["trace"] = trace
+		}
+		return response, append(warnings, errs...)
+	})
+}
+
+// Render returns the file as Markdown, with its code explained in plain
+// English, ex: {"markdown": "..."}.
+func Render(request string) string {
+	return handle(request, func(r *Request, file *util.File) (map[string]interface{}, util.ErrorList) {
+		program, errs := citation.Parse(file)
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		return map[string]interface{}{"markdown": render.Prose(program)}, nil
+	})
+}
+
+// handle decodes a request, runs a function with it, and encodes its response
+// with its diagnostics. Requests which can not be decoded are answered with an
+// error instead, ex: {"error": "..."}.
+func handle(request string, f func(r *Request, file *util.File) (map[string]interface{}, util.ErrorList)) string {
+	var r Request
+	if err := json.Unmarshal([]byte(request), &r); err != nil {
+		return encode(map[string]interface{}{"error": fmt.Sprintf("invalid request: %s", err)})
+	}
+	if r.Inputs == nil {
+		r.Inputs = map[string]interface{}{}
+	}
+
+	response, errs := f(&r, util.NewFileSet().AddFile(r.Filename, []byte(r.Source)))
+	if response == nil {
+		response = map[string]interface{}{}
+	}
+	errs.Dedupe()
+	if errs == nil {
+		errs = util.ErrorList{}
+	}
+	response["diagnostics"] = errs
+	return encode(response)
+}
+
+func encode(response map[string]interface{}) string {
+	data, err := json.Marshal(response)
+	if err != nil {
+		data, _ = json.Marshal(map[string]interface{}{"error": err.Error()})
+	}
+	return string(data)
+}
+
+// check parses a file and checks its types.
+func check(file *util.File) (*ast.Program, *types.Info, util.ErrorList) {
+	program, errs := citation.Parse(file)
+	if errs.HasErrors() {
+		return program, nil, errs
+	}
+	info, errs := types.Check(program)
+	return program, info, errs
+}
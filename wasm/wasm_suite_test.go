@@ -0,0 +1,13 @@
+package wasm_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestWasm(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Wasm Suite")
+}
@@ -0,0 +1,74 @@
+package wasm_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/policyscript/policyscript/wasm"
+)
+
+var _ = Describe("Wasm", func() {
+	var demo string
+
+	BeforeEach(func() {
+		source, err := ioutil.ReadFile("../cmd/policyscript/testdata/demo.law")
+		Expect(err).NotTo(HaveOccurred())
+		demo = string(source)
+	})
+
+	It("evaluates files with inputs", func() {
+		response := call(wasm.Evaluate, map[string]interface{}{
+			"filename": "demo.law",
+			"source":   demo,
+			"inputs":   map[string]interface{}{"person": map[string]interface{}{"age": "old", "countries_lived_in": []string{"Canada"}}},
+			"trace":    true,
+		})
+		Expect(response["outputs"]).To(Equal(map[string]interface{}{"can_read": true}))
+		Expect(response["citations"]).To(Equal(map[string]interface{}{"can_read": "121(a)"}))
+		Expect(response["trace"]).To(HaveKey("steps"))
+		Expect(response["diagnostics"]).To(BeEmpty())
+	})
+
+	It("reports the diagnostics of files", func() {
+		response := call(wasm.Check, map[string]interface{}{"filename": "a.law", "source": "@code {\n  set a to\n}\n"})
+		Expect(response).To(HaveLen(1))
+		diagnostics := response["diagnostics"].([]interface{})
+		Expect(diagnostics).To(HaveLen(1))
+		Expect(diagnostics[0]).To(HaveKeyWithValue("code", "PS2009"))
+		Expect(diagnostics[0].(map[string]interface{})["range"]).To(HaveKeyWithValue("start",
+			HaveKeyWithValue("filename", "a.law")))
+
+		response = call(wasm.Evaluate, map[string]interface{}{"source": demo, "inputs": map[string]interface{}{"age": 3}})
+		Expect(response).NotTo(HaveKey("outputs"))
+		Expect(response["diagnostics"]).To(ContainElement(HaveKeyWithValue("message", "unknown input age")))
+	})
+
+	It("scans, parses and renders files", func() {
+		request := map[string]interface{}{"source": "_ A\n\nB."}
+		Expect(call(wasm.Scan, request)["tokens"]).To(HaveLen(3))
+		Expect(call(wasm.Parse, request)["program"]).To(HaveKeyWithValue("version", BeEquivalentTo(2)))
+		Expect(call(wasm.Render, request)).To(Equal(map[string]interface{}{
+			"markdown":    "## A\n\nB.\n",
+			"diagnostics": []interface{}{},
+		}))
+	})
+
+	It("answers invalid requests with an error", func() {
+		for name, f := range wasm.Functions {
+			var response map[string]interface{}
+			Expect(json.Unmarshal([]byte(f("[]")), &response)).To(Succeed(), name)
+			Expect(response["error"]).To(HavePrefix("invalid request: "), name)
+		}
+	})
+})
+
+func call(f func(string) string, request map[string]interface{}) map[string]interface{} {
+	data, err := json.Marshal(request)
+	Expect(err).NotTo(HaveOccurred())
+
+	var response map[string]interface{}
+	Expect(json.Unmarshal([]byte(f(string(data))), &response)).To(Succeed())
+	return response
+}